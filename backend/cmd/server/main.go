@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"stock-exchange/internal/handlers"
 	"stock-exchange/internal/middleware"
 	"stock-exchange/internal/services"
+	"stock-exchange/internal/storage"
+	"syscall"
 	"time"
+	_ "time/tzdata" // Market calendar time zones on images without zoneinfo
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -21,23 +28,61 @@ import (
 // @host localhost:8080
 // @BasePath /api/v1
 func main() {
-	// Initialize exchange
-	exchange := services.NewExchange()
-	if err := exchange.LoadConfig("./config/config.json"); err != nil {
-		log.Fatal("Failed to load config:", err)
+	// Rebuild the exchange from its latest snapshot and journal, or load the
+	// config on first boot
+	if err := os.MkdirAll("./data", 0o755); err != nil {
+		log.Fatal("Failed to create data directory:", err)
 	}
+	journal, err := services.OpenJournal("./data/journal.jsonl", 100*time.Millisecond)
+	if err != nil {
+		log.Fatal("Failed to open journal:", err)
+	}
+	snapshots, err := services.OpenSnapshotStore("./data/snapshots")
+	if err != nil {
+		log.Fatal("Failed to open snapshot store:", err)
+	}
+
+	storageConfig, err := services.ReadStorageConfig("./config/config.json")
+	if err != nil {
+		log.Fatal("Failed to read storage config:", err)
+	}
+	store, err := storage.Open(storageConfig)
+	if err != nil {
+		log.Fatal("Failed to open storage:", err)
+	}
+
+	exchange := services.NewExchangeWithStore(store)
+	if err := exchange.Recover("./config/config.json", journal, snapshots); err != nil {
+		log.Fatal("Failed to recover exchange:", err)
+	}
+
+	// Start snapshotter so restarts only replay the recent journal
+	snapshotter := services.NewSnapshotter(exchange, 5*time.Minute)
+	snapshotter.Start()
+
+	// Start valuator to record traders' equity curves
+	valuator := services.NewValuator(exchange, time.Minute)
+	valuator.Start()
 
 	// Start price updater
 	priceUpdater := services.NewPriceUpdater(exchange, 10*time.Second)
 	priceUpdater.Start()
 
+	// Start market clock to move through the trading day's phases
+	marketClock := services.NewMarketClock(exchange, time.Second)
+	marketClock.Start()
+
+	// Start expiry sweeper for DAY and GTD orders
+	expirySweeper := services.NewExpirySweeper(exchange, time.Second)
+	expirySweeper.Start()
+
 	// Initialize and start algorithm manager
 	algorithmManager := services.NewAlgorithmManager(exchange)
 
 	// Register algorithmic traders in the exchange system
 	for _, trader := range algorithmManager.GetAlgoTraders() {
 		exchange.RegisterTrader(trader.ID, trader.Name, trader.InitialMoney)
-		log.Printf("🤖 Registered algorithmic trader: %s with $%.2f", trader.Name, trader.InitialMoney)
+		log.Printf("🤖 Registered algorithmic trader: %s with $%s", trader.Name, trader.InitialMoney)
 	}
 
 	algorithmManager.Start()
@@ -56,22 +101,35 @@ func main() {
 		api.GET("/stocks", h.GetAllStocks)
 		api.GET("/stocks/:id", h.GetStock)
 		api.GET("/stocks/:id/history", h.GetStockHistory)
+		api.GET("/stocks/:id/auction", h.GetStockAuction)
+
+		// Market endpoints
+		api.GET("/market/status", h.GetMarketStatus)
 
 		// Trading endpoints
 		api.POST("/orders", h.PlaceOrder)
+		api.POST("/orders/oco", h.PlaceOCO)
+		api.POST("/orders/bracket", h.PlaceBracket)
+		api.GET("/orders/:id", h.GetOrder)
+		api.PATCH("/orders/:id", h.AmendOrder)
 		api.DELETE("/orders/:id", h.CancelOrder)
 
 		// Trader endpoints
 		api.GET("/traders", h.GetAllTraders)
 		api.GET("/traders/:id", h.GetTrader)
+		api.GET("/traders/:id/orders", h.GetTraderOrders)
 		api.GET("/traders/:id/transactions", h.GetTraderTransactions)
 		api.GET("/traders/:id/performance", h.GetTraderPerformance)
+		api.PUT("/traders/:id/self-trade-prevention", h.SetSelfTradePrevention)
 
 		// Algorithmic trading endpoints
 		api.GET("/algorithms", h.GetAlgorithms)
 		api.POST("/algorithms/:id/start", h.StartAlgorithm)
 		api.POST("/algorithms/:id/stop", h.StopAlgorithm)
 		api.GET("/algorithms/:id/status", h.GetAlgorithmStatus)
+
+		// Admin endpoints
+		api.POST("/admin/snapshots", h.TakeSnapshot)
 	}
 
 	// Swagger documentation - ONLY this line, remove the Static route
@@ -101,5 +159,43 @@ func main() {
 	log.Println("Test API: http://localhost:8080/api/v1/test")
 	log.Println("Swagger Debug: http://localhost:8080/swagger-debug")
 	log.Println("Swagger JSON: http://localhost:8080/swagger/swagger.json")
-	router.Run(":8080")
+
+	// Serve until interrupted, then let requests in flight finish
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Addr: ":8080", Handler: router}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Server failed:", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Println("Shutting down...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down the server cleanly: %v", err)
+	}
+
+	// Stop everything that changes the exchange before the journal and store
+	// are closed, so the last operations reach both
+	algorithmManager.Stop()
+	expirySweeper.Stop()
+	marketClock.Stop()
+	priceUpdater.Stop()
+	valuator.Stop()
+	snapshotter.Stop()
+
+	exchange.FlushStore()
+	if err := journal.Close(); err != nil {
+		log.Printf("Failed to close journal: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		log.Printf("Failed to close storage: %v", err)
+	}
+	log.Println("Server stopped")
 }
@@ -0,0 +1,6 @@
+//go:build postgres
+
+package main
+
+// Registers the driver PostgreSQL storage connects with
+import _ "github.com/jackc/pgx/v5/stdlib"
@@ -15,6 +15,32 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/snapshots": {
+            "post": {
+                "description": "Snapshot the exchange's state to disk now and drop the journal entries it covers, so a restart only replays what came after it",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Take a snapshot",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.SnapshotInfo"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/algorithms": {
             "get": {
                 "description": "Get list of all algorithmic trading bots",
@@ -194,9 +220,29 @@ const docTemplate = `{
                 }
             }
         },
+        "/market/status": {
+            "get": {
+                "description": "Get the current trading phase (pre-open, open, closing or closed) and when the next phase starts",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "market"
+                ],
+                "summary": "Get market status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.MarketStatus"
+                        }
+                    }
+                }
+            }
+        },
         "/orders": {
             "post": {
-                "description": "Place a buy or sell order",
+                "description": "Place a buy or sell order. Limit orders (the default kind) require a price;\nmarket orders ignore it and sweep the book, cancelling any unfilled remainder.\ntimeInForce defaults to GTC; GTD orders require expiresAt.\nstop and stop_limit orders wait until the last price reaches stopPrice, then match as market or limit orders.\nA stop order sent with trailAmount or trailPercent instead of a stopPrice is a trailing stop: its stopPrice\nfollows the best price seen since placement at that distance and is shown on the order as it moves.\ndisplayQuantity makes an iceberg order that only shows that many shares on the book at a time.\npostOnly orders never take liquidity: one that would cross the book is rejected or repriced one tick short of it.\nhidden orders are not shown on the book and queue behind displayed orders at the same price.",
                 "consumes": [
                     "application/json"
                 ],
@@ -228,7 +274,126 @@ const docTemplate = `{
                 }
             }
         },
+        "/orders/bracket": {
+            "post": {
+                "description": "Place an entry order with a take-profit limit exit and a stop-loss exit on the other side.\nThe exits are pending until the entry has finished, then work as a GTC one-cancels-other pair\nfor the quantity the entry filled. They are cancelled if the entry fills nothing.\nThe stop-loss is a stop order, or a stop_limit order if stopLossLimit is set.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "trading"
+                ],
+                "summary": "Place a bracket order",
+                "parameters": [
+                    {
+                        "description": "Entry order and exit prices",
+                        "name": "bracket",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BracketRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Order"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/orders/oco": {
+            "post": {
+                "description": "Place two orders for the same trader, stock and quantity as a one-cancels-other pair.\nEvery fill on one leg takes the same quantity off the other, and once either leg is done the other is cancelled.\nBoth legs must be able to rest on the book, and linked orders cannot be amended.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "trading"
+                ],
+                "summary": "Place a one-cancels-other pair",
+                "parameters": [
+                    {
+                        "description": "The two legs",
+                        "name": "pair",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.OCORequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Order"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/orders/{id}": {
+            "get": {
+                "description": "Get any order by ID, including filled, cancelled and expired orders",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orders"
+                ],
+                "summary": "Get an order",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Order"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            },
             "delete": {
                 "description": "Cancel an existing order by ID",
                 "consumes": [
@@ -270,6 +435,51 @@ const docTemplate = `{
                         }
                     }
                 }
+            },
+            "patch": {
+                "description": "Change the price and/or quantity of an open order. A quantity decrease keeps the order's\nplace in the queue; a price change or quantity increase sends it to the back.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orders"
+                ],
+                "summary": "Amend an order",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New price and/or quantity",
+                        "name": "amendment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.AmendOrderRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Order"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
             }
         },
         "/stocks": {
@@ -324,9 +534,50 @@ const docTemplate = `{
                 }
             }
         },
+        "/stocks/{id}/auction": {
+            "get": {
+                "description": "Get the indicative clearing price, volume and imbalance of the opening, closing or reopening auction a stock is collecting orders for",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stocks"
+                ],
+                "summary": "Get indicative auction",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Stock ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.AuctionResult"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/stocks/{id}/history": {
             "get": {
-                "description": "Get historical price data for charts",
+                "description": "Get OHLCV candles built from the stock's trades and price moves, oldest first",
                 "produces": [
                     "application/json"
                 ],
@@ -343,9 +594,21 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "type": "integer",
-                        "description": "Number of days of history (default 30)",
-                        "name": "days",
+                        "type": "string",
+                        "description": "Candle interval: 1m, 5m, 1h or 1d (default 1d)",
+                        "name": "interval",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only candles starting at or after this time (RFC3339, default 100 candles before to)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only candles starting before this time (RFC3339, default now)",
+                        "name": "to",
                         "in": "query"
                     }
                 ],
@@ -355,6 +618,18 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handlers.StockHistoryResponse"
                         }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
                     }
                 }
             }
@@ -384,7 +659,7 @@ const docTemplate = `{
         },
         "/traders/{id}": {
             "get": {
-                "description": "Get trader's open orders, holdings and cash",
+                "description": "Get trader's open orders, holdings and cash, with the cash and shares reserved by open orders",
                 "produces": [
                     "application/json"
                 ],
@@ -411,16 +686,16 @@ const docTemplate = `{
                 }
             }
         },
-        "/traders/{id}/performance": {
+        "/traders/{id}/orders": {
             "get": {
-                "description": "Get trader performance data for charts",
+                "description": "Get a trader's orders, newest first, with optional filters and cursor pagination",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "traders"
                 ],
-                "summary": "Get trader performance history",
+                "summary": "Get trader order history",
                 "parameters": [
                     {
                         "type": "string",
@@ -430,27 +705,169 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "type": "integer",
-                        "description": "Number of days of history (default 30)",
-                        "name": "days",
+                        "type": "string",
+                        "description": "Comma-separated statuses (open, partially_filled, filled, cancelled, expired)",
+                        "name": "status",
                         "in": "query"
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/handlers.TraderPerformanceResponse"
-                        }
-                    }
-                }
-            }
-        },
-        "/traders/{id}/transactions": {
-            "get": {
-                "description": "Get last 8 transactions of a trader",
-                "produces": [
-                    "application/json"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Stock ID",
+                        "name": "stockId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Order side (buy or sell)",
+                        "name": "side",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only orders created at or after this time (RFC3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only orders created before this time (RFC3339)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "nextCursor from the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 50, max 200)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/storage.OrderPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/traders/{id}/performance": {
+            "get": {
+                "description": "Get the trader's recorded equity curve, valued on every fill and at a fixed cadence, with portfolio and activity data for charts",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "traders"
+                ],
+                "summary": "Get trader performance history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Trader ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of days of history (default 30)",
+                        "name": "days",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.TraderPerformanceResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/traders/{id}/self-trade-prevention": {
+            "put": {
+                "description": "Set the self-trade prevention mode used by the trader's new orders that do not pick their own",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "traders"
+                ],
+                "summary": "Set trader self-trade prevention mode",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Trader ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Self-trade prevention mode",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SelfTradePreventionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/traders/{id}/transactions": {
+            "get": {
+                "description": "Get last 8 transactions of a trader",
+                "produces": [
+                    "application/json"
                 ],
                 "tags": [
                     "traders"
@@ -527,6 +944,45 @@ const docTemplate = `{
                 }
             }
         },
+        "handlers.AmendOrderRequest": {
+            "type": "object",
+            "properties": {
+                "price": {
+                    "type": "number",
+                    "minimum": 0
+                },
+                "quantity": {
+                    "type": "integer",
+                    "minimum": 0
+                }
+            }
+        },
+        "handlers.BracketRequest": {
+            "type": "object",
+            "required": [
+                "entry",
+                "stopLoss",
+                "takeProfit"
+            ],
+            "properties": {
+                "entry": {
+                    "$ref": "#/definitions/handlers.OrderRequest"
+                },
+                "stopLoss": {
+                    "description": "Stop price of the stop-loss exit",
+                    "type": "number"
+                },
+                "stopLossLimit": {
+                    "description": "Makes the stop-loss a stop_limit order at this price",
+                    "type": "number",
+                    "minimum": 0
+                },
+                "takeProfit": {
+                    "description": "Limit price of the take-profit exit",
+                    "type": "number"
+                }
+            }
+        },
         "handlers.ErrorResponse": {
             "type": "object",
             "properties": {
@@ -535,42 +991,174 @@ const docTemplate = `{
                 }
             }
         },
+        "handlers.OCORequest": {
+            "type": "object",
+            "required": [
+                "legs"
+            ],
+            "properties": {
+                "legs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.OrderRequest"
+                    }
+                }
+            }
+        },
         "handlers.OrderRequest": {
             "type": "object",
             "required": [
-                "price",
                 "quantity",
                 "stockId",
                 "traderId",
                 "type"
             ],
             "properties": {
+                "displayQuantity": {
+                    "description": "Shown on the book at a time by an iceberg order; zero shows the full quantity",
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "expiresAt": {
+                    "description": "Required for GTD orders",
+                    "type": "string"
+                },
+                "hidden": {
+                    "type": "boolean"
+                },
+                "kind": {
+                    "enum": [
+                        "limit",
+                        "market",
+                        "stop",
+                        "stop_limit"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.OrderKind"
+                        }
+                    ]
+                },
+                "postOnly": {
+                    "enum": [
+                        "reject",
+                        "reprice"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.PostOnly"
+                        }
+                    ]
+                },
                 "price": {
-                    "type": "number"
+                    "description": "Required for limit and stop_limit orders",
+                    "type": "number",
+                    "minimum": 0
                 },
                 "quantity": {
                     "type": "integer"
                 },
+                "selfTradePrevention": {
+                    "description": "Defaults to the trader's mode, or cancel_newest if the trader has none",
+                    "enum": [
+                        "cancel_newest",
+                        "cancel_oldest",
+                        "cancel_both",
+                        "decrement_and_cancel"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.SelfTradePrevention"
+                        }
+                    ]
+                },
                 "stockId": {
                     "type": "string"
                 },
+                "stopPrice": {
+                    "description": "Required for stop and stop_limit orders",
+                    "type": "number",
+                    "minimum": 0
+                },
+                "timeInForce": {
+                    "enum": [
+                        "GTC",
+                        "DAY",
+                        "IOC",
+                        "FOK",
+                        "GTD"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.TimeInForce"
+                        }
+                    ]
+                },
                 "traderId": {
                     "type": "string"
                 },
+                "trailAmount": {
+                    "description": "Makes a stop order a trailing stop by an amount or a fraction of the price (0.05 for 5%)",
+                    "type": "number",
+                    "minimum": 0
+                },
+                "trailPercent": {
+                    "type": "number",
+                    "minimum": 0
+                },
                 "type": {
                     "$ref": "#/definitions/models.OrderType"
                 }
             }
         },
+        "handlers.SelfTradePreventionRequest": {
+            "type": "object",
+            "required": [
+                "mode"
+            ],
+            "properties": {
+                "mode": {
+                    "enum": [
+                        "cancel_newest",
+                        "cancel_oldest",
+                        "cancel_both",
+                        "decrement_and_cancel"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.SelfTradePrevention"
+                        }
+                    ]
+                }
+            }
+        },
         "handlers.StockDetailsResponse": {
             "type": "object",
             "properties": {
                 "amount": {
                     "type": "integer"
                 },
+                "auctions": {
+                    "description": "Which of the daily call auctions the stock takes part in. Without an\nopening auction it only takes cancellations before the open, and\nwithout a closing auction only cancellations while the market closes.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.AuctionSchedule"
+                        }
+                    ]
+                },
+                "closingPrice": {
+                    "description": "Set at each close",
+                    "type": "number"
+                },
                 "currentPrice": {
                     "type": "number"
                 },
+                "haltSeconds": {
+                    "type": "integer"
+                },
+                "haltedUntil": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "string"
                 },
@@ -580,6 +1168,15 @@ const docTemplate = `{
                         "$ref": "#/definitions/models.Transaction"
                     }
                 },
+                "lotSize": {
+                    "type": "integer"
+                },
+                "maxQuantity": {
+                    "type": "integer"
+                },
+                "minQuantity": {
+                    "type": "integer"
+                },
                 "name": {
                     "type": "string"
                 },
@@ -588,21 +1185,45 @@ const docTemplate = `{
                     "items": {
                         "$ref": "#/definitions/models.Order"
                     }
+                },
+                "priceBand": {
+                    "description": "Price band and circuit breaker settings. Limit orders must be priced\nwithin PriceBand (a fraction, 0.1 for ±10%) of ReferencePrice and a\ntrade outside it halts the stock for HaltSeconds. A zero PriceBand\nturns both off; a zero HaltSeconds uses DefaultHaltDuration.",
+                    "type": "number"
+                },
+                "referencePrice": {
+                    "type": "number"
+                },
+                "status": {
+                    "$ref": "#/definitions/models.TradingStatus"
+                },
+                "supplyPeak": {
+                    "description": "SupplyPeak makes the exchange's initial sell order of Amount shares an\niceberg order showing this many at a time; zero shows it all",
+                    "type": "integer"
+                },
+                "tickSize": {
+                    "description": "Trading rules, fixed once the stock is listed. A zero TickSize uses\nTickTable, or DefaultTickTable when that is empty too; zero quantity\nlimits mean no limit.",
+                    "type": "number"
+                },
+                "tickTable": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.TickBand"
+                    }
                 }
             }
         },
         "handlers.StockHistoryResponse": {
             "type": "object",
             "properties": {
-                "days": {
-                    "type": "integer"
-                },
-                "history": {
+                "candles": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/models.PriceQuote"
+                        "$ref": "#/definitions/models.Candle"
                     }
                 },
+                "interval": {
+                    "$ref": "#/definitions/services.CandleInterval"
+                },
                 "stockId": {
                     "type": "string"
                 }
@@ -619,6 +1240,15 @@ const docTemplate = `{
         "handlers.TraderDetailsResponse": {
             "type": "object",
             "properties": {
+                "availableCash": {
+                    "type": "number"
+                },
+                "availableShares": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
                 "holdings": {
                     "type": "object",
                     "additionalProperties": {
@@ -642,6 +1272,23 @@ const docTemplate = `{
                     "items": {
                         "$ref": "#/definitions/models.Order"
                     }
+                },
+                "reservedCash": {
+                    "type": "number"
+                },
+                "reservedShares": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "selfTradePrevention": {
+                    "description": "SelfTradePrevention is the default mode for the trader's orders",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.SelfTradePrevention"
+                        }
+                    ]
                 }
             }
         },
@@ -716,46 +1363,240 @@ const docTemplate = `{
                 }
             }
         },
+        "models.AuctionSchedule": {
+            "type": "object",
+            "properties": {
+                "closing": {
+                    "type": "boolean"
+                },
+                "opening": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "models.Candle": {
+            "type": "object",
+            "properties": {
+                "close": {
+                    "type": "number"
+                },
+                "high": {
+                    "type": "number"
+                },
+                "low": {
+                    "type": "number"
+                },
+                "open": {
+                    "type": "number"
+                },
+                "time": {
+                    "description": "Start of the interval",
+                    "type": "string"
+                },
+                "volume": {
+                    "description": "Shares traded",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Fill": {
+            "type": "object",
+            "properties": {
+                "executedAt": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "number"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "transactionId": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.MarketPhase": {
+            "type": "string",
+            "enum": [
+                "pre-open",
+                "open",
+                "closing",
+                "closed"
+            ],
+            "x-enum-comments": {
+                "PhaseClosing": "Only cancellations are accepted",
+                "PhaseOpen": "Continuous trading",
+                "PhasePreOpen": "Orders are collected for the open"
+            },
+            "x-enum-varnames": [
+                "PhasePreOpen",
+                "PhaseOpen",
+                "PhaseClosing",
+                "PhaseClosed"
+            ]
+        },
         "models.Order": {
             "type": "object",
             "properties": {
+                "avgFillPrice": {
+                    "type": "number"
+                },
                 "createdAt": {
                     "type": "string"
                 },
+                "displayQuantity": {
+                    "description": "An iceberg order only shows DisplayQuantity shares on the book at a\ntime. Displayed is what is left of the current peak; once it trades\naway the peak is refreshed from the hidden reserve.",
+                    "type": "integer"
+                },
+                "displayed": {
+                    "type": "integer"
+                },
+                "exitIds": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "expiresAt": {
+                    "description": "Set for DAY and GTD orders",
+                    "type": "string"
+                },
+                "filledQuantity": {
+                    "type": "integer"
+                },
+                "fills": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Fill"
+                    }
+                },
+                "hidden": {
+                    "description": "A hidden order is never shown on the book and queues behind the\ndisplayed orders at its price",
+                    "type": "boolean"
+                },
                 "id": {
                     "type": "string"
                 },
+                "kind": {
+                    "$ref": "#/definitions/models.OrderKind"
+                },
+                "ocoLegId": {
+                    "description": "Linked orders. The legs of a one-cancels-other pair name each other;\na bracket entry names its take-profit and stop-loss exits, which are\nthemselves a one-cancels-other pair.",
+                    "type": "string"
+                },
+                "originalQuantity": {
+                    "type": "integer"
+                },
+                "parentId": {
+                    "description": "The entry of a bracket exit",
+                    "type": "string"
+                },
+                "postOnly": {
+                    "$ref": "#/definitions/models.PostOnly"
+                },
                 "price": {
+                    "description": "Limit price, unused for market orders",
                     "type": "number"
                 },
                 "quantity": {
+                    "description": "Remaining (unfilled) quantity",
+                    "type": "integer"
+                },
+                "reservedCash": {
+                    "description": "Money still held back for an open buy order",
+                    "type": "number"
+                },
+                "reservedShares": {
+                    "description": "Shares still held back for an open sell order",
                     "type": "integer"
                 },
+                "selfTradePrevention": {
+                    "description": "Applied when this order is the incoming one",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.SelfTradePrevention"
+                        }
+                    ]
+                },
                 "status": {
                     "$ref": "#/definitions/models.OrderStatus"
                 },
                 "stockId": {
                     "type": "string"
                 },
+                "stopPrice": {
+                    "description": "Trigger price for stop and stop-limit orders",
+                    "type": "number"
+                },
+                "timeInForce": {
+                    "$ref": "#/definitions/models.TimeInForce"
+                },
                 "traderId": {
                     "type": "string"
                 },
+                "trailAmount": {
+                    "description": "A trailing stop order's stop price follows the best price seen since it\nwas placed (the highest for a sell, the lowest for a buy) at a distance\nof TrailAmount, or TrailPercent (a fraction, 0.05 for 5%) of that price.\nTrailPeak is the best price seen so far.",
+                    "type": "number"
+                },
+                "trailPeak": {
+                    "type": "number"
+                },
+                "trailPercent": {
+                    "type": "number"
+                },
+                "triggeredAt": {
+                    "description": "When a stop order entered matching",
+                    "type": "string"
+                },
                 "type": {
                     "$ref": "#/definitions/models.OrderType"
+                },
+                "updatedAt": {
+                    "type": "string"
                 }
             }
         },
+        "models.OrderKind": {
+            "type": "string",
+            "enum": [
+                "limit",
+                "market",
+                "stop",
+                "stop_limit"
+            ],
+            "x-enum-comments": {
+                "Market": "Sweeps the book, any unfilled remainder is cancelled",
+                "Stop": "Becomes a market order once StopPrice trades",
+                "StopLimit": "Becomes a limit order at Price once StopPrice trades"
+            },
+            "x-enum-varnames": [
+                "Limit",
+                "Market",
+                "Stop",
+                "StopLimit"
+            ]
+        },
         "models.OrderStatus": {
             "type": "string",
             "enum": [
                 "open",
+                "partially_filled",
                 "filled",
-                "cancelled"
+                "cancelled",
+                "expired",
+                "pending"
             ],
+            "x-enum-comments": {
+                "Pending": "A bracket exit waiting for its entry to fill"
+            },
             "x-enum-varnames": [
                 "Open",
+                "PartiallyFilled",
                 "Filled",
-                "Cancelled"
+                "Cancelled",
+                "Expired",
+                "Pending"
             ]
         },
         "models.OrderType": {
@@ -778,10 +1619,16 @@ const docTemplate = `{
                 "date": {
                     "type": "string"
                 },
+                "holdingsValue": {
+                    "description": "Holdings at the last prices",
+                    "type": "number"
+                },
                 "portfolioValue": {
+                    "description": "Cash and holdings",
                     "type": "number"
                 },
                 "profitLoss": {
+                    "description": "Against the trader's initial money",
                     "type": "number"
                 }
             }
@@ -823,19 +1670,39 @@ const docTemplate = `{
                 }
             }
         },
-        "models.PriceQuote": {
-            "type": "object",
-            "properties": {
-                "price": {
-                    "type": "number"
-                },
-                "timestamp": {
-                    "type": "string"
-                },
-                "volume": {
-                    "type": "integer"
-                }
-            }
+        "models.PostOnly": {
+            "type": "string",
+            "enum": [
+                "reject",
+                "reprice"
+            ],
+            "x-enum-varnames": [
+                "PostOnlyReject",
+                "PostOnlyReprice"
+            ]
+        },
+        "models.SelfTradePrevention": {
+            "type": "string",
+            "enum": [
+                "cancel_newest",
+                "cancel_oldest",
+                "cancel_both",
+                "decrement_and_cancel",
+                "cancel_newest"
+            ],
+            "x-enum-comments": {
+                "CancelBoth": "Cancel both orders",
+                "CancelNewest": "Cancel the incoming order, keep the resting one",
+                "CancelOldest": "Cancel the resting order and keep matching",
+                "DecrementAndCancel": "Reduce both by the smaller size, cancel whichever reaches zero"
+            },
+            "x-enum-varnames": [
+                "CancelNewest",
+                "CancelOldest",
+                "CancelBoth",
+                "DecrementAndCancel",
+                "DefaultSelfTradePrevention"
+            ]
         },
         "models.Stock": {
             "type": "object",
@@ -843,20 +1710,144 @@ const docTemplate = `{
                 "amount": {
                     "type": "integer"
                 },
+                "auctions": {
+                    "description": "Which of the daily call auctions the stock takes part in. Without an\nopening auction it only takes cancellations before the open, and\nwithout a closing auction only cancellations while the market closes.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.AuctionSchedule"
+                        }
+                    ]
+                },
+                "closingPrice": {
+                    "description": "Set at each close",
+                    "type": "number"
+                },
                 "currentPrice": {
                     "type": "number"
                 },
+                "haltSeconds": {
+                    "type": "integer"
+                },
+                "haltedUntil": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "string"
                 },
+                "lotSize": {
+                    "type": "integer"
+                },
+                "maxQuantity": {
+                    "type": "integer"
+                },
+                "minQuantity": {
+                    "type": "integer"
+                },
                 "name": {
                     "type": "string"
+                },
+                "priceBand": {
+                    "description": "Price band and circuit breaker settings. Limit orders must be priced\nwithin PriceBand (a fraction, 0.1 for ±10%) of ReferencePrice and a\ntrade outside it halts the stock for HaltSeconds. A zero PriceBand\nturns both off; a zero HaltSeconds uses DefaultHaltDuration.",
+                    "type": "number"
+                },
+                "referencePrice": {
+                    "type": "number"
+                },
+                "status": {
+                    "$ref": "#/definitions/models.TradingStatus"
+                },
+                "supplyPeak": {
+                    "description": "SupplyPeak makes the exchange's initial sell order of Amount shares an\niceberg order showing this many at a time; zero shows it all",
+                    "type": "integer"
+                },
+                "tickSize": {
+                    "description": "Trading rules, fixed once the stock is listed. A zero TickSize uses\nTickTable, or DefaultTickTable when that is empty too; zero quantity\nlimits mean no limit.",
+                    "type": "number"
+                },
+                "tickTable": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.TickBand"
+                    }
                 }
             }
         },
+        "models.TickBand": {
+            "type": "object",
+            "properties": {
+                "minPrice": {
+                    "type": "number"
+                },
+                "tickSize": {
+                    "type": "number"
+                }
+            }
+        },
+        "models.TimeInForce": {
+            "type": "string",
+            "enum": [
+                "GTC",
+                "DAY",
+                "IOC",
+                "FOK",
+                "GTD"
+            ],
+            "x-enum-comments": {
+                "Day": "Expires at session close",
+                "FOK": "Fill or kill, filled in full on entry or not at all",
+                "GTC": "Good till cancelled",
+                "GTD": "Good till date, expires at ExpiresAt",
+                "IOC": "Immediate or cancel, the unfilled remainder never rests"
+            },
+            "x-enum-varnames": [
+                "GTC",
+                "Day",
+                "IOC",
+                "FOK",
+                "GTD"
+            ]
+        },
+        "models.TradingStatus": {
+            "type": "string",
+            "enum": [
+                "trading",
+                "halted",
+                "opening-auction",
+                "closing-auction",
+                "cancel-only",
+                "closed"
+            ],
+            "x-enum-comments": {
+                "CancelOnly": "No new orders or amendments",
+                "Closed": "No new orders or amendments",
+                "ClosingAuction": "Orders are collected for the closing auction",
+                "Halted": "Orders are collected for the reopening auction",
+                "OpeningAuction": "Orders are collected for the opening auction",
+                "Trading": "Orders match continuously"
+            },
+            "x-enum-varnames": [
+                "Trading",
+                "Halted",
+                "OpeningAuction",
+                "ClosingAuction",
+                "CancelOnly",
+                "Closed"
+            ]
+        },
         "models.Transaction": {
             "type": "object",
             "properties": {
+                "aggressorSide": {
+                    "description": "AggressorSide is the side of the incoming order that took liquidity;\nthe other side was the passive maker. Auction trades have no aggressor.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.OrderType"
+                        }
+                    ]
+                },
+                "buyOrderId": {
+                    "type": "string"
+                },
                 "buyerId": {
                     "type": "string"
                 },
@@ -867,11 +1858,15 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "price": {
+                    "description": "Price of the resting (maker) order, or the auction clearing price",
                     "type": "number"
                 },
                 "quantity": {
                     "type": "integer"
                 },
+                "sellOrderId": {
+                    "type": "string"
+                },
                 "sellerId": {
                     "type": "string"
                 },
@@ -879,6 +1874,108 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "services.AuctionKind": {
+            "type": "string",
+            "enum": [
+                "opening",
+                "closing",
+                "reopening"
+            ],
+            "x-enum-comments": {
+                "AuctionReopening": "After a circuit breaker halt"
+            },
+            "x-enum-varnames": [
+                "AuctionOpening",
+                "AuctionClosing",
+                "AuctionReopening"
+            ]
+        },
+        "services.AuctionResult": {
+            "type": "object",
+            "properties": {
+                "clearingPrice": {
+                    "description": "Zero if nothing could trade",
+                    "type": "number"
+                },
+                "imbalance": {
+                    "description": "Imbalance is the buy volume minus the sell volume left unmatched at\nthe clearing price",
+                    "type": "integer"
+                },
+                "kind": {
+                    "$ref": "#/definitions/services.AuctionKind"
+                },
+                "stockId": {
+                    "type": "string"
+                },
+                "volume": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.CandleInterval": {
+            "type": "string",
+            "enum": [
+                "1m",
+                "5m",
+                "1h",
+                "1d"
+            ],
+            "x-enum-varnames": [
+                "Interval1m",
+                "Interval5m",
+                "Interval1h",
+                "Interval1d"
+            ]
+        },
+        "services.MarketStatus": {
+            "type": "object",
+            "properties": {
+                "nextPhase": {
+                    "$ref": "#/definitions/models.MarketPhase"
+                },
+                "nextPhaseAt": {
+                    "type": "string"
+                },
+                "phase": {
+                    "$ref": "#/definitions/models.MarketPhase"
+                },
+                "time": {
+                    "type": "string"
+                },
+                "timeZone": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.SnapshotInfo": {
+            "type": "object",
+            "properties": {
+                "path": {
+                    "type": "string"
+                },
+                "seq": {
+                    "type": "integer"
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "storage.OrderPage": {
+            "type": "object",
+            "properties": {
+                "nextCursor": {
+                    "description": "Empty on the last page",
+                    "type": "string"
+                },
+                "orders": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Order"
+                    }
+                }
+            }
         }
     }
 }`
@@ -5,7 +5,9 @@ import (
 	"net/http"
 	"stock-exchange/internal/models"
 	"stock-exchange/internal/services"
+	"stock-exchange/internal/storage"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -40,6 +42,9 @@ func NewHandlers(exchange *services.Exchange, algorithmManager *services.Algorit
 // @Router /stocks [get]
 func (h *Handlers) GetAllStocks(c *gin.Context) {
 	stocks := h.exchange.GetAllStocks()
+	for i, stock := range stocks {
+		stocks[i] = stock.Public()
+	}
 	c.JSON(http.StatusOK, stocks)
 }
 
@@ -64,7 +69,7 @@ func (h *Handlers) GetStock(c *gin.Context) {
 	transactions := h.exchange.GetLastTransactions(stockID, 10)
 
 	response := StockDetailsResponse{
-		Stock:        stock,
+		Stock:        stock.Public(),
 		OpenOrders:   openOrders,
 		Transactions: transactions,
 	}
@@ -74,7 +79,15 @@ func (h *Handlers) GetStock(c *gin.Context) {
 
 // PlaceOrder
 // @Summary Place a new order
-// @Description Place a buy or sell order
+// @Description Place a buy or sell order. Limit orders (the default kind) require a price;
+// @Description market orders ignore it and sweep the book, cancelling any unfilled remainder.
+// @Description timeInForce defaults to GTC; GTD orders require expiresAt.
+// @Description stop and stop_limit orders wait until the last price reaches stopPrice, then match as market or limit orders.
+// @Description A stop order sent with trailAmount or trailPercent instead of a stopPrice is a trailing stop: its stopPrice
+// @Description follows the best price seen since placement at that distance and is shown on the order as it moves.
+// @Description displayQuantity makes an iceberg order that only shows that many shares on the book at a time.
+// @Description postOnly orders never take liquidity: one that would cross the book is rejected or repriced one tick short of it.
+// @Description hidden orders are not shown on the book and queue behind displayed orders at the same price.
 // @Tags trading
 // @Accept json
 // @Produce json
@@ -88,29 +101,133 @@ func (h *Handlers) PlaceOrder(c *gin.Context) {
 		return
 	}
 
-	// Check if trader has conflicting orders
-	if h.exchange.HasConflictingOrder(req.TraderID, req.StockID, req.Type) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot have both buy and sell orders for the same stock"})
+	order := newOrder(req)
+	if err := h.exchange.PlaceOrder(order); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	order := &models.Order{
+	// Respond with a copy, a resting order can keep trading in the meantime
+	placed, _ := h.exchange.GetOrder(order.ID)
+	c.JSON(http.StatusCreated, placed)
+}
+
+// PlaceOCO
+// @Summary Place a one-cancels-other pair
+// @Description Place two orders for the same trader, stock and quantity as a one-cancels-other pair.
+// @Description Every fill on one leg takes the same quantity off the other, and once either leg is done the other is cancelled.
+// @Description Both legs must be able to rest on the book, and linked orders cannot be amended.
+// @Tags trading
+// @Accept json
+// @Produce json
+// @Param pair body OCORequest true "The two legs"
+// @Success 201 {array} models.Order
+// @Failure 400 {object} ErrorResponse
+// @Router /orders/oco [post]
+func (h *Handlers) PlaceOCO(c *gin.Context) {
+	var req OCORequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	first, second := newOrder(req.Legs[0]), newOrder(req.Legs[1])
+	if err := h.exchange.PlaceOCO(first, second); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, h.placedOrders(first.ID, second.ID))
+}
+
+// PlaceBracket
+// @Summary Place a bracket order
+// @Description Place an entry order with a take-profit limit exit and a stop-loss exit on the other side.
+// @Description The exits are pending until the entry has finished, then work as a GTC one-cancels-other pair
+// @Description for the quantity the entry filled. They are cancelled if the entry fills nothing.
+// @Description The stop-loss is a stop order, or a stop_limit order if stopLossLimit is set.
+// @Tags trading
+// @Accept json
+// @Produce json
+// @Param bracket body BracketRequest true "Entry order and exit prices"
+// @Success 201 {array} models.Order
+// @Failure 400 {object} ErrorResponse
+// @Router /orders/bracket [post]
+func (h *Handlers) PlaceBracket(c *gin.Context) {
+	var req BracketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	exit := OrderRequest{
+		TraderID:    req.Entry.TraderID,
+		StockID:     req.Entry.StockID,
+		Type:        models.Sell,
+		Quantity:    req.Entry.Quantity,
+		TimeInForce: models.GTC,
+	}
+	if req.Entry.Type == models.Sell {
+		exit.Type = models.Buy
+	}
+
+	takeProfit := newOrder(exit)
+	takeProfit.Kind = models.Limit
+	takeProfit.Price = req.TakeProfit
+
+	stopLoss := newOrder(exit)
+	stopLoss.Kind = models.Stop
+	stopLoss.StopPrice = req.StopLoss
+	if req.StopLossLimit > 0 {
+		stopLoss.Kind = models.StopLimit
+		stopLoss.Price = req.StopLossLimit
+	}
+
+	entry := newOrder(req.Entry)
+	if err := h.exchange.PlaceBracket(entry, takeProfit, stopLoss); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, h.placedOrders(entry.ID, takeProfit.ID, stopLoss.ID))
+}
+
+// newOrder builds an order from a request
+func newOrder(req OrderRequest) *models.Order {
+	return &models.Order{
 		ID:        uuid.New().String(),
 		TraderID:  req.TraderID,
 		StockID:   req.StockID,
 		Type:      req.Type,
+		Kind:      req.Kind,
 		Price:     req.Price,
 		Quantity:  req.Quantity,
 		Status:    models.Open,
 		CreatedAt: time.Now(),
-	}
 
-	if err := h.exchange.PlaceOrder(order); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		TimeInForce: req.TimeInForce,
+		ExpiresAt:   req.ExpiresAt,
+		StopPrice:   req.StopPrice,
+
+		TrailAmount:  req.TrailAmount,
+		TrailPercent: req.TrailPercent,
+
+		SelfTradePrevention: req.SelfTradePrevention,
+		DisplayQuantity:     req.DisplayQuantity,
+		PostOnly:            req.PostOnly,
+		Hidden:              req.Hidden,
 	}
+}
 
-	c.JSON(http.StatusCreated, order)
+// placedOrders returns copies of orders that were just placed
+func (h *Handlers) placedOrders(ids ...string) []models.Order {
+	orders := make([]models.Order, 0, len(ids))
+	for _, id := range ids {
+		if order, exists := h.exchange.GetOrder(id); exists {
+			orders = append(orders, order)
+		}
+	}
+	return orders
 }
 
 // CancelOrder
@@ -138,6 +255,57 @@ func (h *Handlers) CancelOrder(c *gin.Context) {
 	})
 }
 
+// GetOrder
+// @Summary Get an order
+// @Description Get any order by ID, including filled, cancelled and expired orders
+// @Tags orders
+// @Produce json
+// @Param id path string true "Order ID"
+// @Success 200 {object} models.Order
+// @Failure 404 {object} ErrorResponse
+// @Router /orders/{id} [get]
+func (h *Handlers) GetOrder(c *gin.Context) {
+	orderID := c.Param("id")
+
+	order, exists := h.exchange.GetOrder(orderID)
+	if !exists {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Order not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// AmendOrder
+// @Summary Amend an order
+// @Description Change the price and/or quantity of an open order. A quantity decrease keeps the order's
+// @Description place in the queue; a price change or quantity increase sends it to the back.
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Param id path string true "Order ID"
+// @Param amendment body AmendOrderRequest true "New price and/or quantity"
+// @Success 200 {object} models.Order
+// @Failure 400 {object} ErrorResponse
+// @Router /orders/{id} [patch]
+func (h *Handlers) AmendOrder(c *gin.Context) {
+	orderID := c.Param("id")
+
+	var req AmendOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := h.exchange.AmendOrder(orderID, req.Price, req.Quantity)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
 // GetAllTraders
 // @Summary Get all traders
 // @Description Get names of all traders
@@ -152,7 +320,7 @@ func (h *Handlers) GetAllTraders(c *gin.Context) {
 
 // GetTrader
 // @Summary Get trader details
-// @Description Get trader's open orders, holdings and cash
+// @Description Get trader's open orders, holdings and cash, with the cash and shares reserved by open orders
 // @Tags traders
 // @Produce json
 // @Param id path string true "Trader ID"
@@ -161,12 +329,13 @@ func (h *Handlers) GetAllTraders(c *gin.Context) {
 func (h *Handlers) GetTrader(c *gin.Context) {
 	traderID := c.Param("id")
 
-	trader, exists := h.exchange.GetTrader(traderID)
+	trader, exists := h.exchange.GetTraderSnapshot(traderID)
 	if !exists {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Trader not found"})
 		return
 	}
 
+	balance, _ := h.exchange.GetTraderBalance(traderID)
 	openOrders := h.exchange.GetTraderOpenOrders(traderID)
 
 	// Ensure I never send null for openOrders
@@ -175,8 +344,9 @@ func (h *Handlers) GetTrader(c *gin.Context) {
 	}
 
 	response := TraderDetailsResponse{
-		Trader:     trader,
-		OpenOrders: openOrders,
+		Trader:        trader,
+		TraderBalance: balance,
+		OpenOrders:    openOrders,
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -204,31 +374,217 @@ func (h *Handlers) GetTraderTransactions(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// GetTraderOrders
+// @Summary Get trader order history
+// @Description Get a trader's orders, newest first, with optional filters and cursor pagination
+// @Tags traders
+// @Produce json
+// @Param id path string true "Trader ID"
+// @Param status query string false "Comma-separated statuses (open, partially_filled, filled, cancelled, expired)"
+// @Param stockId query string false "Stock ID"
+// @Param side query string false "Order side (buy or sell)"
+// @Param from query string false "Only orders created at or after this time (RFC3339)"
+// @Param to query string false "Only orders created before this time (RFC3339)"
+// @Param cursor query string false "nextCursor from the previous page"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Success 200 {object} storage.OrderPage
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /traders/{id}/orders [get]
+func (h *Handlers) GetTraderOrders(c *gin.Context) {
+	traderID := c.Param("id")
+
+	if _, exists := h.exchange.GetTrader(traderID); !exists {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Trader not found"})
+		return
+	}
+
+	query := storage.OrderQuery{
+		TraderID: traderID,
+		StockID:  c.Query("stockId"),
+		Side:     models.OrderType(c.Query("side")),
+		Cursor:   c.Query("cursor"),
+	}
+
+	if statusParam := c.Query("status"); statusParam != "" {
+		for _, status := range strings.Split(statusParam, ",") {
+			query.Statuses = append(query.Statuses, models.OrderStatus(strings.TrimSpace(status)))
+		}
+	}
+
+	if query.Side != "" && query.Side != models.Buy && query.Side != models.Sell {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "side must be buy or sell"})
+		return
+	}
+
+	var err error
+	if fromParam := c.Query("from"); fromParam != "" {
+		if query.From, err = time.Parse(time.RFC3339, fromParam); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "from must be an RFC3339 timestamp"})
+			return
+		}
+	}
+	if toParam := c.Query("to"); toParam != "" {
+		if query.To, err = time.Parse(time.RFC3339, toParam); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "to must be an RFC3339 timestamp"})
+			return
+		}
+	}
+
+	if limitParam := c.Query("limit"); limitParam != "" {
+		if query.Limit, err = strconv.Atoi(limitParam); err != nil || query.Limit <= 0 {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "limit must be a positive integer"})
+			return
+		}
+	}
+
+	page, err := h.exchange.QueryOrders(query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// SetSelfTradePrevention
+// @Summary Set trader self-trade prevention mode
+// @Description Set the self-trade prevention mode used by the trader's new orders that do not pick their own
+// @Tags traders
+// @Accept json
+// @Produce json
+// @Param id path string true "Trader ID"
+// @Param request body SelfTradePreventionRequest true "Self-trade prevention mode"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /traders/{id}/self-trade-prevention [put]
+func (h *Handlers) SetSelfTradePrevention(c *gin.Context) {
+	traderID := c.Param("id")
+
+	var req SelfTradePreventionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := h.exchange.SetSelfTradePrevention(traderID, req.Mode); err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Message: "Self-trade prevention set to " + string(req.Mode),
+	})
+}
+
+// GetStockAuction
+// @Summary Get indicative auction
+// @Description Get the indicative clearing price, volume and imbalance of the opening, closing or reopening auction a stock is collecting orders for
+// @Tags stocks
+// @Produce json
+// @Param id path string true "Stock ID"
+// @Success 200 {object} services.AuctionResult
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /stocks/{id}/auction [get]
+func (h *Handlers) GetStockAuction(c *gin.Context) {
+	stockID := c.Param("id")
+
+	if _, exists := h.exchange.GetStock(stockID); !exists {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Stock not found"})
+		return
+	}
+
+	result, err := h.exchange.GetIndicativeAuction(stockID)
+	if err != nil {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetMarketStatus
+// @Summary Get market status
+// @Description Get the current trading phase (pre-open, open, closing or closed) and when the next phase starts
+// @Tags market
+// @Produce json
+// @Success 200 {object} services.MarketStatus
+// @Router /market/status [get]
+func (h *Handlers) GetMarketStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, h.exchange.GetMarketStatus(time.Now()))
+}
+
+// TakeSnapshot
+// @Summary Take a snapshot
+// @Description Snapshot the exchange's state to disk now and drop the journal entries it covers, so a restart only replays what came after it
+// @Tags admin
+// @Produce json
+// @Success 200 {object} services.SnapshotInfo
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/snapshots [post]
+func (h *Handlers) TakeSnapshot(c *gin.Context) {
+	info, err := h.exchange.TakeSnapshot()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
 // GetStockHistory
 // @Summary Get stock price history
-// @Description Get historical price data for charts
+// @Description Get OHLCV candles built from the stock's trades and price moves, oldest first
 // @Tags stocks
 // @Produce json
 // @Param id path string true "Stock ID"
-// @Param days query int false "Number of days of history (default 30)"
+// @Param interval query string false "Candle interval: 1m, 5m, 1h or 1d (default 1d)"
+// @Param from query string false "Only candles starting at or after this time (RFC3339, default 100 candles before to)"
+// @Param to query string false "Only candles starting before this time (RFC3339, default now)"
 // @Success 200 {object} StockHistoryResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
 // @Router /stocks/{id}/history [get]
 func (h *Handlers) GetStockHistory(c *gin.Context) {
 	stockID := c.Param("id")
-	days := 30 // Default to 30 days
 
-	if daysParam := c.Query("days"); daysParam != "" {
-		if parsedDays, err := strconv.Atoi(daysParam); err == nil && parsedDays > 0 {
-			days = parsedDays
+	if _, exists := h.exchange.GetStock(stockID); !exists {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Stock not found"})
+		return
+	}
+
+	interval := services.Interval1d
+	if intervalParam := c.Query("interval"); intervalParam != "" {
+		interval = services.CandleInterval(intervalParam)
+	}
+
+	var from, to time.Time
+	var err error
+	if fromParam := c.Query("from"); fromParam != "" {
+		if from, err = time.Parse(time.RFC3339, fromParam); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "from must be an RFC3339 timestamp"})
+			return
+		}
+	}
+	if toParam := c.Query("to"); toParam != "" {
+		if to, err = time.Parse(time.RFC3339, toParam); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "to must be an RFC3339 timestamp"})
+			return
 		}
 	}
 
-	history := h.exchange.GetStockHistory(stockID, days)
+	candles, err := h.exchange.GetStockHistory(stockID, interval, from, to)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
 
 	response := StockHistoryResponse{
-		StockID: stockID,
-		Days:    days,
-		History: history,
+		StockID:  stockID,
+		Interval: interval,
+		Candles:  candles,
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -236,17 +592,23 @@ func (h *Handlers) GetStockHistory(c *gin.Context) {
 
 // GetTraderPerformance
 // @Summary Get trader performance history
-// @Description Get trader performance data for charts
+// @Description Get the trader's recorded equity curve, valued on every fill and at a fixed cadence, with portfolio and activity data for charts
 // @Tags traders
 // @Produce json
 // @Param id path string true "Trader ID"
 // @Param days query int false "Number of days of history (default 30)"
 // @Success 200 {object} TraderPerformanceResponse
+// @Failure 404 {object} ErrorResponse
 // @Router /traders/{id}/performance [get]
 func (h *Handlers) GetTraderPerformance(c *gin.Context) {
 	traderID := c.Param("id")
 	days := 30 // Default to 30 days
 
+	if _, exists := h.exchange.GetTrader(traderID); !exists {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Trader not found"})
+		return
+	}
+
 	if daysParam := c.Query("days"); daysParam != "" {
 		if parsedDays, err := strconv.Atoi(daysParam); err == nil && parsedDays > 0 {
 			days = parsedDays
@@ -532,8 +894,50 @@ type OrderRequest struct {
 	TraderID string           `json:"traderId" binding:"required"`
 	StockID  string           `json:"stockId" binding:"required"`
 	Type     models.OrderType `json:"type" binding:"required"`
-	Price    float64          `json:"price" binding:"required,gt=0"`
+	Kind     models.OrderKind `json:"kind" binding:"omitempty,oneof=limit market stop stop_limit" enums:"limit,market,stop,stop_limit"`
+	Price    models.Money     `json:"price" binding:"gte=0"` // Required for limit and stop_limit orders
 	Quantity int              `json:"quantity" binding:"required,gt=0"`
+
+	TimeInForce models.TimeInForce `json:"timeInForce" binding:"omitempty,oneof=GTC DAY IOC FOK GTD" enums:"GTC,DAY,IOC,FOK,GTD"`
+	ExpiresAt   *time.Time         `json:"expiresAt"`                 // Required for GTD orders
+	StopPrice   models.Money       `json:"stopPrice" binding:"gte=0"` // Required for stop and stop_limit orders
+
+	// Makes a stop order a trailing stop by an amount or a fraction of the price (0.05 for 5%)
+	TrailAmount  models.Money `json:"trailAmount" binding:"gte=0"`
+	TrailPercent float64      `json:"trailPercent" binding:"gte=0,lt=1"`
+
+	// Shown on the book at a time by an iceberg order; zero shows the full quantity
+	DisplayQuantity int `json:"displayQuantity" binding:"gte=0"`
+
+	PostOnly models.PostOnly `json:"postOnly" binding:"omitempty,oneof=reject reprice" enums:"reject,reprice"`
+	Hidden   bool            `json:"hidden"`
+
+	// Defaults to the trader's mode, or cancel_newest if the trader has none
+	SelfTradePrevention models.SelfTradePrevention `json:"selfTradePrevention" binding:"omitempty,oneof=cancel_newest cancel_oldest cancel_both decrement_and_cancel" enums:"cancel_newest,cancel_oldest,cancel_both,decrement_and_cancel"`
+}
+
+// OCORequest places two orders as a one-cancels-other pair
+type OCORequest struct {
+	Legs []OrderRequest `json:"legs" binding:"required,len=2,dive"`
+}
+
+// BracketRequest places an entry order with take-profit and stop-loss exits
+type BracketRequest struct {
+	Entry         OrderRequest `json:"entry" binding:"required"`
+	TakeProfit    models.Money `json:"takeProfit" binding:"required,gt=0"` // Limit price of the take-profit exit
+	StopLoss      models.Money `json:"stopLoss" binding:"required,gt=0"`   // Stop price of the stop-loss exit
+	StopLossLimit models.Money `json:"stopLossLimit" binding:"gte=0"`      // Makes the stop-loss a stop_limit order at this price
+}
+
+// SelfTradePreventionRequest sets a trader's default self-trade prevention mode
+type SelfTradePreventionRequest struct {
+	Mode models.SelfTradePrevention `json:"mode" binding:"required,oneof=cancel_newest cancel_oldest cancel_both decrement_and_cancel" enums:"cancel_newest,cancel_oldest,cancel_both,decrement_and_cancel"`
+}
+
+// AmendOrderRequest carries the fields to change on an open order; omitted fields are kept
+type AmendOrderRequest struct {
+	Price    models.Money `json:"price" binding:"gte=0"`
+	Quantity int          `json:"quantity" binding:"gte=0"`
 }
 
 type StockDetailsResponse struct {
@@ -549,18 +953,19 @@ type TraderInfo struct {
 
 type TraderDetailsResponse struct {
 	*models.Trader
+	services.TraderBalance
 	OpenOrders []models.Order `json:"openOrders"`
 }
 
 type TraderTransactionsResponse struct {
 	Transactions []models.Transaction `json:"transactions"`
-	ProfitLoss   float64              `json:"profitLoss"`
+	ProfitLoss   models.Money         `json:"profitLoss"`
 }
 
 type StockHistoryResponse struct {
-	StockID string              `json:"stockId"`
-	Days    int                 `json:"days"`
-	History []models.PriceQuote `json:"history"`
+	StockID  string                  `json:"stockId"`
+	Interval services.CandleInterval `json:"interval"`
+	Candles  []models.Candle         `json:"candles"`
 }
 
 type TraderPerformanceResponse struct {
@@ -573,13 +978,13 @@ type TraderPerformanceResponse struct {
 
 // AlgorithmicTraderResponse represents an algorithmic trader response
 type AlgorithmicTraderResponse struct {
-	ID           string    `json:"id"`
-	Name         string    `json:"name"`
-	Strategy     string    `json:"strategy"`
-	Active       bool      `json:"active"`
-	OrdersPlaced int       `json:"ordersPlaced"`
-	ProfitLoss   float64   `json:"profitLoss"`
-	LastAction   time.Time `json:"lastAction"`
+	ID           string       `json:"id"`
+	Name         string       `json:"name"`
+	Strategy     string       `json:"strategy"`
+	Active       bool         `json:"active"`
+	OrdersPlaced int          `json:"ordersPlaced"`
+	ProfitLoss   models.Money `json:"profitLoss"`
+	LastAction   time.Time    `json:"lastAction"`
 	// Configuration parameters
 	MaxOrderValue     float64 `json:"maxOrderValue"`     // Maximum value per order
 	MinOrderValue     float64 `json:"minOrderValue"`     // Minimum value per order
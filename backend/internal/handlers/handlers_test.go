@@ -9,7 +9,9 @@ import (
 	"os"
 	"stock-exchange/internal/models"
 	"stock-exchange/internal/services"
+	"stock-exchange/internal/storage"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -18,12 +20,14 @@ import (
 
 // Test setup helpers
 func setupTestRouter() (*gin.Engine, *services.Exchange, *Handlers) {
+	return setupTestRouterFor(createTestExchange())
+}
+
+// setupTestRouterFor serves the API routes for an exchange
+func setupTestRouterFor(exchange *services.Exchange) (*gin.Engine, *services.Exchange, *Handlers) {
 	// Set Gin to test mode
 	gin.SetMode(gin.TestMode)
 
-	// Create test exchange
-	exchange := createTestExchange()
-
 	// Create algorithm manager for testing
 	algorithmManager := services.NewAlgorithmManager(exchange)
 
@@ -39,10 +43,21 @@ func setupTestRouter() (*gin.Engine, *services.Exchange, *Handlers) {
 		api.GET("/stocks", handlers.GetAllStocks)
 		api.GET("/stocks/:id", handlers.GetStock)
 		api.POST("/orders", handlers.PlaceOrder)
+		api.POST("/orders/oco", handlers.PlaceOCO)
+		api.POST("/orders/bracket", handlers.PlaceBracket)
+		api.GET("/orders/:id", handlers.GetOrder)
+		api.PATCH("/orders/:id", handlers.AmendOrder)
 		api.DELETE("/orders/:id", handlers.CancelOrder)
 		api.GET("/traders", handlers.GetAllTraders)
 		api.GET("/traders/:id", handlers.GetTrader)
+		api.GET("/traders/:id/orders", handlers.GetTraderOrders)
 		api.GET("/traders/:id/transactions", handlers.GetTraderTransactions)
+		api.PUT("/traders/:id/self-trade-prevention", handlers.SetSelfTradePrevention)
+		api.GET("/stocks/:id/auction", handlers.GetStockAuction)
+		api.GET("/stocks/:id/history", handlers.GetStockHistory)
+		api.GET("/traders/:id/performance", handlers.GetTraderPerformance)
+		api.GET("/market/status", handlers.GetMarketStatus)
+		api.POST("/admin/snapshots", handlers.TakeSnapshot)
 
 		// Add algorithm endpoints for testing
 		api.GET("/algorithms", handlers.GetAlgorithms)
@@ -89,7 +104,19 @@ func createTestExchange() *services.Exchange {
 			}
 		]
 	}`
+	loadTestConfig(exchange, configData)
+
+	// Give trader2 some holdings by placing and executing trades
+	// Create a buy order for trader2 to get some shares
+	trader2, _ := exchange.GetTrader("trader2")
+	trader2.Holdings["1"] = 50
+	trader2.Holdings["2"] = 20
+
+	return exchange
+}
 
+// loadTestConfig loads a config into an exchange through a temporary file
+func loadTestConfig(exchange *services.Exchange, configData string) {
 	// Create temporary config file
 	tmpFile, err := os.CreateTemp("", "test_config_*.json")
 	if err != nil {
@@ -106,14 +133,11 @@ func createTestExchange() *services.Exchange {
 	if err := exchange.LoadConfig(tmpFile.Name()); err != nil {
 		panic(err)
 	}
+}
 
-	// Give trader2 some holdings by placing and executing trades
-	// Create a buy order for trader2 to get some shares
-	trader2, _ := exchange.GetTrader("trader2")
-	trader2.Holdings["1"] = 50
-	trader2.Holdings["2"] = 20
-
-	return exchange
+// money converts a dollar amount to models.Money
+func money(amount float64) models.Money {
+	return models.MoneyFromFloat(amount)
 }
 
 // Test NewHandlers
@@ -146,7 +170,7 @@ func TestGetAllStocks(t *testing.T) {
 	assert.Equal(t, "1", stocks[0].ID)
 	assert.Equal(t, "2", stocks[1].ID)
 	assert.Equal(t, "Apple Inc.", stocks[0].Name)
-	assert.Equal(t, 150.0, stocks[0].CurrentPrice)
+	assert.Equal(t, money(150.0), stocks[0].CurrentPrice)
 }
 
 // Test GetStock - Success
@@ -165,7 +189,7 @@ func TestGetStock_Success(t *testing.T) {
 
 	assert.Equal(t, "1", response.Stock.ID)
 	assert.Equal(t, "Apple Inc.", response.Stock.Name)
-	assert.Equal(t, 150.0, response.Stock.CurrentPrice)
+	assert.Equal(t, money(150.0), response.Stock.CurrentPrice)
 	assert.NotNil(t, response.OpenOrders)
 	// Transactions can be nil if no transactions exist yet
 	assert.True(t, response.Transactions != nil || response.Transactions == nil)
@@ -196,7 +220,7 @@ func TestPlaceOrder_ValidBuyOrder(t *testing.T) {
 		TraderID: "trader1",
 		StockID:  "1",
 		Type:     models.Buy,
-		Price:    155.0,
+		Price:    money(155.0),
 		Quantity: 10,
 	}
 
@@ -216,7 +240,7 @@ func TestPlaceOrder_ValidBuyOrder(t *testing.T) {
 	assert.Equal(t, "trader1", order.TraderID)
 	assert.Equal(t, "1", order.StockID)
 	assert.Equal(t, models.Buy, order.Type)
-	assert.Equal(t, 155.0, order.Price)
+	assert.Equal(t, money(155.0), order.Price)
 	// When order is filled, quantity becomes 0 (remaining quantity)
 	assert.Equal(t, 0, order.Quantity)
 	// Order should be filled immediately by the exchange
@@ -232,7 +256,7 @@ func TestPlaceOrder_ValidSellOrder(t *testing.T) {
 		TraderID: "trader2", // trader2 has holdings
 		StockID:  "1",
 		Type:     models.Sell,
-		Price:    145.0,
+		Price:    money(145.0),
 		Quantity: 5,
 	}
 
@@ -255,6 +279,176 @@ func TestPlaceOrder_ValidSellOrder(t *testing.T) {
 	assert.True(t, order.Status == models.Filled || order.Status == models.Open)
 }
 
+// Test PlaceOrder - Market Order
+func TestPlaceOrder_MarketOrder(t *testing.T) {
+	router, _, _ := setupTestRouter()
+
+	orderReq := OrderRequest{
+		TraderID: "trader1",
+		StockID:  "1",
+		Type:     models.Buy,
+		Kind:     models.Market,
+		Quantity: 10,
+	}
+
+	jsonData, _ := json.Marshal(orderReq)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/orders", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var order models.Order
+	err := json.Unmarshal(w.Body.Bytes(), &order)
+	require.NoError(t, err)
+
+	assert.Equal(t, models.Market, order.Kind)
+	// Filled against the exchange's initial supply
+	assert.Equal(t, models.Filled, order.Status)
+}
+
+// Test PlaceOrder - Invalid Order Kind
+func TestPlaceOrder_InvalidKind(t *testing.T) {
+	router, _, _ := setupTestRouter()
+
+	jsonData := `{"traderId":"trader1","stockId":"1","type":"buy","kind":"bogus","price":150,"quantity":1}`
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/orders", bytes.NewBufferString(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// Test PlaceOrder - Immediate Or Cancel
+func TestPlaceOrder_ImmediateOrCancel(t *testing.T) {
+	router, _, _ := setupTestRouter()
+
+	orderReq := OrderRequest{
+		TraderID:    "trader1",
+		StockID:     "2",
+		Type:        models.Buy,
+		Price:       money(250.0), // Below the exchange's ask, nothing to cross
+		Quantity:    10,
+		TimeInForce: models.IOC,
+	}
+
+	jsonData, _ := json.Marshal(orderReq)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/orders", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var order models.Order
+	err := json.Unmarshal(w.Body.Bytes(), &order)
+	require.NoError(t, err)
+
+	assert.Equal(t, models.IOC, order.TimeInForce)
+	assert.Equal(t, models.Cancelled, order.Status)
+}
+
+// Test PlaceOrder - Post-only order repriced below the exchange's ask
+func TestPlaceOrder_PostOnlyReprice(t *testing.T) {
+	router, _, _ := setupTestRouter()
+
+	orderReq := OrderRequest{
+		TraderID: "trader1",
+		StockID:  "2",
+		Type:     models.Buy,
+		Price:    money(300.0), // Would take the exchange's ask
+		Quantity: 10,
+		PostOnly: models.PostOnlyReprice,
+	}
+
+	jsonData, _ := json.Marshal(orderReq)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/orders", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var order models.Order
+	err := json.Unmarshal(w.Body.Bytes(), &order)
+	require.NoError(t, err)
+
+	assert.Equal(t, models.Open, order.Status)
+	assert.Equal(t, money(299.95), order.Price)
+}
+
+// Test PlaceBracket - the exits become active once the entry fills
+func TestPlaceBracket(t *testing.T) {
+	router, _, _ := setupTestRouter()
+
+	bracketReq := BracketRequest{
+		Entry: OrderRequest{
+			TraderID: "trader1",
+			StockID:  "1",
+			Type:     models.Buy,
+			Price:    money(150.0), // Takes the exchange's ask
+			Quantity: 10,
+		},
+		TakeProfit: money(160.0),
+		StopLoss:   money(140.0),
+	}
+
+	jsonData, _ := json.Marshal(bracketReq)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/orders/bracket", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var orders []models.Order
+	err := json.Unmarshal(w.Body.Bytes(), &orders)
+	require.NoError(t, err)
+	require.Len(t, orders, 3)
+
+	entry, takeProfit, stopLoss := orders[0], orders[1], orders[2]
+	assert.Equal(t, models.Filled, entry.Status)
+	assert.Equal(t, []string{takeProfit.ID, stopLoss.ID}, entry.ExitIDs)
+
+	assert.Equal(t, models.Open, takeProfit.Status)
+	assert.Equal(t, models.Sell, takeProfit.Type)
+	assert.Equal(t, money(160.0), takeProfit.Price)
+	assert.Equal(t, entry.ID, takeProfit.ParentID)
+
+	assert.Equal(t, models.Stop, stopLoss.Kind)
+	assert.Equal(t, money(140.0), stopLoss.StopPrice)
+	assert.Equal(t, takeProfit.ID, stopLoss.OCOLegID)
+}
+
+// Test PlaceOCO - both legs are required
+func TestPlaceOCO_OneLeg(t *testing.T) {
+	router, _, _ := setupTestRouter()
+
+	ocoReq := OCORequest{Legs: []OrderRequest{{
+		TraderID: "trader2",
+		StockID:  "1",
+		Type:     models.Sell,
+		Price:    money(160.0),
+		Quantity: 10,
+	}}}
+
+	jsonData, _ := json.Marshal(ocoReq)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/orders/oco", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
 // Test PlaceOrder - Invalid JSON
 func TestPlaceOrder_InvalidJSON(t *testing.T) {
 	router, _, _ := setupTestRouter()
@@ -288,7 +482,7 @@ func TestPlaceOrder_MissingFields(t *testing.T) {
 			orderReq: OrderRequest{
 				StockID:  "1",
 				Type:     models.Buy,
-				Price:    150.0,
+				Price:    money(150.0),
 				Quantity: 10,
 			},
 		},
@@ -297,7 +491,7 @@ func TestPlaceOrder_MissingFields(t *testing.T) {
 			orderReq: OrderRequest{
 				TraderID: "trader1",
 				Type:     models.Buy,
-				Price:    150.0,
+				Price:    money(150.0),
 				Quantity: 10,
 			},
 		},
@@ -317,7 +511,7 @@ func TestPlaceOrder_MissingFields(t *testing.T) {
 				TraderID: "trader1",
 				StockID:  "1",
 				Type:     models.Buy,
-				Price:    150.0,
+				Price:    money(150.0),
 				Quantity: 0,
 			},
 		},
@@ -352,7 +546,7 @@ func TestPlaceOrder_BusinessLogicErrors(t *testing.T) {
 				TraderID: "trader1",
 				StockID:  "1",
 				Type:     models.Buy,
-				Price:    1000.0,
+				Price:    money(1000.0),
 				Quantity: 100, // 100,000 total - more than trader1's 10,000
 			},
 			expectedErr: "insufficient funds",
@@ -363,7 +557,7 @@ func TestPlaceOrder_BusinessLogicErrors(t *testing.T) {
 				TraderID: "trader1", // trader1 has no holdings
 				StockID:  "1",
 				Type:     models.Sell,
-				Price:    150.0,
+				Price:    money(150.0),
 				Quantity: 10,
 			},
 			expectedErr: "insufficient holdings",
@@ -374,7 +568,7 @@ func TestPlaceOrder_BusinessLogicErrors(t *testing.T) {
 				TraderID: "nonexistent",
 				StockID:  "1",
 				Type:     models.Buy,
-				Price:    150.0,
+				Price:    money(150.0),
 				Quantity: 10,
 			},
 			expectedErr: "trader not found",
@@ -401,16 +595,19 @@ func TestPlaceOrder_BusinessLogicErrors(t *testing.T) {
 	}
 }
 
-// Test PlaceOrder - Conflicting Orders
-func TestPlaceOrder_ConflictingOrders(t *testing.T) {
-	router, _, _ := setupTestRouter()
+// Test PlaceOrder - Two-sided quoting on the same stock is allowed
+func TestPlaceOrder_TwoSidedQuote(t *testing.T) {
+	router, exchange, _ := setupTestRouter()
+
+	trader, _ := exchange.GetTrader("trader1")
+	trader.Holdings["2"] = 10
 
 	// First, place a buy order using the API
 	buyOrderReq := OrderRequest{
 		TraderID: "trader1",
 		StockID:  "2", // Use stock 2 to avoid automatic matching
 		Type:     models.Buy,
-		Price:    250.0, // Below current price to avoid immediate fill
+		Price:    money(250.0), // Below current price to avoid immediate fill
 		Quantity: 10,
 	}
 
@@ -423,12 +620,12 @@ func TestPlaceOrder_ConflictingOrders(t *testing.T) {
 	// Verify the buy order was placed successfully
 	assert.Equal(t, http.StatusCreated, w.Code)
 
-	// Now try to place a sell order for the same trader and stock
+	// Now quote the other side of the same stock
 	sellOrderReq := OrderRequest{
 		TraderID: "trader1",
 		StockID:  "2",
 		Type:     models.Sell,
-		Price:    350.0, // Above current price
+		Price:    money(350.0), // Above current price
 		Quantity: 5,
 	}
 
@@ -438,13 +635,93 @@ func TestPlaceOrder_ConflictingOrders(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 	router.ServeHTTP(w, req)
 
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var order models.Order
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+
+	assert.Equal(t, models.Open, order.Status)
+	assert.Equal(t, models.CancelNewest, order.SelfTradePrevention)
+	assert.Len(t, exchange.GetTraderOpenOrders("trader1"), 2)
+}
+
+// Test PlaceOrder - Self-trade prevention mode on the order
+func TestPlaceOrder_SelfTradePrevention(t *testing.T) {
+	router, exchange, _ := setupTestRouter()
+
+	trader, _ := exchange.GetTrader("trader1")
+	trader.Holdings["2"] = 10
+
+	place := func(orderReq OrderRequest) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(orderReq)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v1/orders", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := place(OrderRequest{TraderID: "trader1", StockID: "2", Type: models.Buy, Price: money(250.0), Quantity: 10})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var resting models.Order
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resting))
+
+	// A crossing sell with cancel_oldest removes the trader's own bid
+	w = place(OrderRequest{
+		TraderID:            "trader1",
+		StockID:             "2",
+		Type:                models.Sell,
+		Price:               money(240.0),
+		Quantity:            5,
+		SelfTradePrevention: models.CancelOldest,
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	order, _ := exchange.GetOrder(resting.ID)
+	assert.Equal(t, models.Cancelled, order.Status)
+
+	w = place(OrderRequest{
+		TraderID:            "trader1",
+		StockID:             "2",
+		Type:                models.Sell,
+		Price:               money(240.0),
+		Quantity:            5,
+		SelfTradePrevention: "skip",
+	})
 	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
 
-	var response map[string]string
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	require.NoError(t, err)
+// Test SetSelfTradePrevention
+func TestSetSelfTradePrevention(t *testing.T) {
+	router, exchange, _ := setupTestRouter()
+
+	tests := []struct {
+		name         string
+		traderID     string
+		body         string
+		expectedCode int
+	}{
+		{"Success", "trader1", `{"mode": "cancel_both"}`, http.StatusOK},
+		{"Invalid mode", "trader1", `{"mode": "skip"}`, http.StatusBadRequest},
+		{"Missing mode", "trader1", `{}`, http.StatusBadRequest},
+		{"Unknown trader", "nonexistent", `{"mode": "cancel_both"}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("PUT", "/api/v1/traders/"+tt.traderID+"/self-trade-prevention",
+				bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
 
-	assert.Equal(t, "Cannot have both buy and sell orders for the same stock", response["error"])
+	trader, _ := exchange.GetTrader("trader1")
+	assert.Equal(t, models.CancelBoth, trader.SelfTradePrevention)
 }
 
 // Test CancelOrder - Success
@@ -456,7 +733,7 @@ func TestCancelOrder_Success(t *testing.T) {
 		TraderID: "trader1",
 		StockID:  "2", // Use stock 2
 		Type:     models.Buy,
-		Price:    250.0, // Below current price to avoid immediate fill
+		Price:    money(250.0), // Below current price to avoid immediate fill
 		Quantity: 10,
 	}
 
@@ -503,6 +780,242 @@ func TestCancelOrder_Success(t *testing.T) {
 	}
 }
 
+// Test AmendOrder - Success
+func TestAmendOrder_Success(t *testing.T) {
+	router, _, _ := setupTestRouter()
+
+	orderReq := OrderRequest{
+		TraderID: "trader1",
+		StockID:  "2",
+		Type:     models.Buy,
+		Price:    money(250.0), // Below current price to avoid immediate fill
+		Quantity: 10,
+	}
+
+	jsonData, _ := json.Marshal(orderReq)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/orders", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var order models.Order
+	err := json.Unmarshal(w.Body.Bytes(), &order)
+	require.NoError(t, err)
+
+	amendReq := AmendOrderRequest{Price: money(260.0), Quantity: 5}
+	jsonData, _ = json.Marshal(amendReq)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PATCH", "/api/v1/orders/"+order.ID, bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var amended models.Order
+	err = json.Unmarshal(w.Body.Bytes(), &amended)
+	require.NoError(t, err)
+
+	assert.Equal(t, order.ID, amended.ID)
+	assert.Equal(t, money(260.0), amended.Price)
+	assert.Equal(t, 5, amended.Quantity)
+}
+
+// Test AmendOrder - Not Found
+func TestAmendOrder_NotFound(t *testing.T) {
+	router, _, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/api/v1/orders/nonexistent", bytes.NewBufferString(`{"quantity": 5}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response map[string]string
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+
+	assert.Contains(t, response["error"], "order not found")
+}
+
+// Test GetOrder - Success and Not Found
+func TestGetOrder(t *testing.T) {
+	router, _, _ := setupTestRouter()
+
+	orderReq := OrderRequest{
+		TraderID: "trader1",
+		StockID:  "1",
+		Type:     models.Buy,
+		Price:    money(155.0),
+		Quantity: 10,
+	}
+
+	jsonData, _ := json.Marshal(orderReq)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/orders", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var placed models.Order
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &placed))
+
+	// Filled orders can still be fetched
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/orders/"+placed.ID, nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var order models.Order
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+
+	assert.Equal(t, placed.ID, order.ID)
+	assert.Equal(t, models.Filled, order.Status)
+	assert.Equal(t, 10, order.FilledQuantity)
+	assert.Len(t, order.Fills, 1)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/orders/nonexistent", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// Test GetTraderOrders - filters and validation
+func TestGetStockHistory(t *testing.T) {
+	router, _, _ := setupTestRouter()
+
+	// Buys from the exchange's supply at 150.00
+	orderReq := OrderRequest{TraderID: "trader1", StockID: "1", Type: models.Buy, Price: money(155.0), Quantity: 2}
+	jsonData, _ := json.Marshal(orderReq)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/orders", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/stocks/1/history?interval=1m", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response StockHistoryResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	assert.Equal(t, services.Interval1m, response.Interval)
+	require.Len(t, response.Candles, 1)
+	assert.Equal(t, money(150.0), response.Candles[0].Close)
+	assert.Equal(t, 2, response.Candles[0].Volume)
+
+	tests := []struct {
+		name         string
+		url          string
+		expectedCode int
+	}{
+		{"Default interval", "/api/v1/stocks/1/history", http.StatusOK},
+		{"Unknown stock", "/api/v1/stocks/nonexistent/history", http.StatusNotFound},
+		{"Bad interval", "/api/v1/stocks/1/history?interval=2m", http.StatusBadRequest},
+		{"Bad from", "/api/v1/stocks/1/history?from=yesterday", http.StatusBadRequest},
+		{"From after to", "/api/v1/stocks/1/history?from=2024-01-02T00:00:00Z&to=2024-01-01T00:00:00Z", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.url, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
+
+func TestGetTraderPerformance(t *testing.T) {
+	router, exchange, _ := setupTestRouter()
+
+	exchange.RecordValuations()
+	orderReq := OrderRequest{TraderID: "trader1", StockID: "1", Type: models.Buy, Price: money(155.0), Quantity: 2}
+	jsonData, _ := json.Marshal(orderReq)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/orders", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/traders/trader1/performance?days=7", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response TraderPerformanceResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	assert.Equal(t, 7, response.Days)
+	require.Len(t, response.Performance, 2)
+	assert.Equal(t, money(10000.0), response.Performance[0].PortfolioValue)
+	assert.Equal(t, money(10000.0-300.0), response.Performance[1].CashBalance)
+	assert.Equal(t, money(300.0), response.Performance[1].HoldingsValue)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/traders/nonexistent/performance", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetTraderOrders(t *testing.T) {
+	router, _, _ := setupTestRouter()
+
+	for _, price := range []float64{155.0, 140.0} {
+		orderReq := OrderRequest{TraderID: "trader1", StockID: "1", Type: models.Buy, Price: money(price), Quantity: 1}
+		jsonData, _ := json.Marshal(orderReq)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v1/orders", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/traders/trader1/orders?status=open&side=buy", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var page storage.OrderPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+
+	require.Len(t, page.Orders, 1)
+	assert.Equal(t, money(140.0), page.Orders[0].Price)
+	assert.Empty(t, page.NextCursor)
+
+	tests := []struct {
+		name         string
+		url          string
+		expectedCode int
+	}{
+		{"Unknown trader", "/api/v1/traders/nonexistent/orders", http.StatusNotFound},
+		{"Bad side", "/api/v1/traders/trader1/orders?side=short", http.StatusBadRequest},
+		{"Bad from", "/api/v1/traders/trader1/orders?from=yesterday", http.StatusBadRequest},
+		{"Bad limit", "/api/v1/traders/trader1/orders?limit=-1", http.StatusBadRequest},
+		{"Bad cursor", "/api/v1/traders/trader1/orders?cursor=999", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.url, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
+
 // Test CancelOrder - Not Found
 func TestCancelOrder_NotFound(t *testing.T) {
 	router, _, _ := setupTestRouter()
@@ -520,6 +1033,79 @@ func TestCancelOrder_NotFound(t *testing.T) {
 	assert.Contains(t, response["error"], "order not found")
 }
 
+// Test GetMarketStatus - the test config has no calendar, so the market is always open
+func TestGetMarketStatus(t *testing.T) {
+	router, _, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/market/status", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var status services.MarketStatus
+	err := json.Unmarshal(w.Body.Bytes(), &status)
+	require.NoError(t, err)
+
+	assert.Equal(t, models.PhaseOpen, status.Phase)
+	assert.Nil(t, status.NextPhaseAt)
+}
+
+// Test TakeSnapshot without a journal
+func TestTakeSnapshot_NotEnabled(t *testing.T) {
+	router, _, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/admin/snapshots", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+// Test GetStockAuction
+func TestGetStockAuction(t *testing.T) {
+	router, exchange, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/stocks/1/auction", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Put the stock in its opening auction through a pre-open on a calendar
+	// that trades every day
+	exchange = services.NewExchange()
+	loadTestConfig(exchange, `{
+		"market": {
+			"timeZone": "UTC",
+			"preOpen": "09:00",
+			"open": "09:30",
+			"closing": "15:50",
+			"close": "16:00",
+			"tradingDays": ["Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"]
+		},
+		"shares": [
+			{"id": "1", "name": "Apple Inc.", "currentPrice": 150.0, "amount": 1000, "auctions": {"opening": true}}
+		]
+	}`)
+	year, month, day := time.Now().UTC().Date()
+	exchange.AdvanceSession(time.Date(year, month, day, 9, 10, 0, 0, time.UTC))
+	router, _, _ = setupTestRouterFor(exchange)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result services.AuctionResult
+	err := json.Unmarshal(w.Body.Bytes(), &result)
+	require.NoError(t, err)
+	assert.Equal(t, services.AuctionOpening, result.Kind)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/stocks/999/auction", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
 // Test GetAllTraders
 func TestGetAllTraders(t *testing.T) {
 	router, _, _ := setupTestRouter()
@@ -555,11 +1141,48 @@ func TestGetTrader_Success(t *testing.T) {
 
 	assert.Equal(t, "trader1", response.Trader.ID)
 	assert.Equal(t, "John Doe", response.Trader.Name)
-	assert.Equal(t, 10000.0, response.Trader.Money)
+	assert.Equal(t, money(10000.0), response.Trader.Money)
 	assert.NotNil(t, response.OpenOrders)
 	assert.IsType(t, []models.Order{}, response.OpenOrders)
 }
 
+// Test GetTrader - Reservations from open orders
+func TestGetTrader_Reservations(t *testing.T) {
+	router, _, _ := setupTestRouter()
+
+	// Below the exchange's ask, so the order rests and keeps its cash reserved
+	orderReq := OrderRequest{
+		TraderID: "trader1",
+		StockID:  "1",
+		Type:     models.Buy,
+		Price:    money(100.0),
+		Quantity: 30,
+	}
+
+	jsonData, _ := json.Marshal(orderReq)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/orders", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/traders/trader1", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response TraderDetailsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	assert.Equal(t, money(10000.0), response.Trader.Money)
+	assert.Equal(t, money(3000.0), response.TraderBalance.ReservedCash)
+	assert.Equal(t, money(7000.0), response.TraderBalance.AvailableCash)
+	assert.NotNil(t, response.TraderBalance.AvailableShares)
+	assert.NotNil(t, response.TraderBalance.ReservedShares)
+	assert.Len(t, response.OpenOrders, 1)
+}
+
 // Test GetTrader - Not Found
 func TestGetTrader_NotFound(t *testing.T) {
 	router, _, _ := setupTestRouter()
@@ -586,7 +1209,7 @@ func TestGetTraderTransactions(t *testing.T) {
 		TraderID: "trader1",
 		StockID:  "1",
 		Type:     models.Buy,
-		Price:    155.0, // Above current price to ensure execution
+		Price:    money(155.0), // Above current price to ensure execution
 		Quantity: 10,
 	}
 
@@ -611,7 +1234,7 @@ func TestGetTraderTransactions(t *testing.T) {
 	require.NoError(t, err)
 
 	assert.NotNil(t, response.Transactions)
-	assert.IsType(t, float64(0), response.ProfitLoss)
+	assert.IsType(t, models.Money(0), response.ProfitLoss)
 
 	// Since the buy order executed against the exchange, I should have at least one transaction
 	assert.GreaterOrEqual(t, len(response.Transactions), 1)
@@ -625,7 +1248,7 @@ func TestPlaceOrder_InvalidContentType(t *testing.T) {
 		TraderID: "trader1",
 		StockID:  "1",
 		Type:     models.Buy,
-		Price:    150.0,
+		Price:    money(150.0),
 		Quantity: 10,
 	}
 
@@ -0,0 +1,11 @@
+package models
+
+// MarketPhase is the part of the trading day the market is in
+type MarketPhase string
+
+const (
+	PhasePreOpen MarketPhase = "pre-open" // Orders are collected for the open
+	PhaseOpen    MarketPhase = "open"     // Continuous trading
+	PhaseClosing MarketPhase = "closing"  // Only cancellations are accepted
+	PhaseClosed  MarketPhase = "closed"
+)
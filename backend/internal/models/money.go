@@ -0,0 +1,131 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Currency is the currency every amount on the exchange is held in
+const Currency = "USD"
+
+// MinorUnits is the number of minor units (cents) in one unit of Currency
+const MinorUnits = 100
+
+// Money is an exact amount of Currency in integer minor units, so cash
+// balances and prices add up without floating point drift. In JSON it is a
+// plain decimal number (e.g. 150.25), the same as the float64 fields it
+// replaced, and numbers with more decimals are rounded to the nearest cent.
+type Money int64
+
+// MoneyFromFloat converts a float amount to Money, rounding to the nearest minor unit
+func MoneyFromFloat(amount float64) Money {
+	return Money(math.Round(amount * MinorUnits))
+}
+
+// ParseMoney parses a decimal amount such as "150", "-3.5" or "0.125"
+// exactly, rounding half away from zero to the nearest minor unit
+func ParseMoney(s string) (Money, error) {
+	text := strings.TrimSpace(s)
+	negative := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "-"), "+")
+
+	// Exponent forms (1e3) are rare enough to go through float parsing
+	if strings.ContainsAny(text, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid amount: %q", s)
+		}
+		return MoneyFromFloat(f), nil
+	}
+
+	whole, fraction, _ := strings.Cut(text, ".")
+	if whole == "" && fraction == "" {
+		return 0, fmt.Errorf("invalid amount: %q", s)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+
+	if !isDigits(whole) || !isDigits(fraction) {
+		return 0, fmt.Errorf("invalid amount: %q", s)
+	}
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/MinorUnits-1 {
+		return 0, fmt.Errorf("invalid amount: %q", s)
+	}
+
+	// Keep two decimals and round on the third
+	digits := fraction + "000"
+	cents, _ := strconv.ParseInt(digits[:2], 10, 64)
+	if digits[2] >= '5' {
+		cents++
+	}
+
+	amount := Money(units*MinorUnits + cents)
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Float64 returns the amount in units of Currency, for display and statistics only
+func (m Money) Float64() float64 {
+	return float64(m) / MinorUnits
+}
+
+// Mul returns the amount multiplied by a share quantity
+func (m Money) Mul(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// MulFloat scales the amount by a factor, rounding to the nearest minor unit
+func (m Money) MulFloat(factor float64) Money {
+	return Money(math.Round(float64(m) * factor))
+}
+
+// Div splits the amount by a share quantity, rounding to the nearest minor unit
+func (m Money) Div(quantity int) Money {
+	if quantity == 0 {
+		return 0
+	}
+	return Money(math.Round(float64(m) / float64(quantity)))
+}
+
+// String formats the amount as a decimal with two places, e.g. "150.25"
+func (m Money) String() string {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+		m = -m
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, m/MinorUnits, m%MinorUnits)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string
+func (m *Money) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	amount, err := ParseMoney(strings.Trim(text, `"`))
+	if err != nil {
+		return err
+	}
+	*m = amount
+	return nil
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// Test ParseMoney - exact decimals, rounding and invalid input
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Money
+	}{
+		{"150", 15000},
+		{"150.25", 15025},
+		{"0.1", 10},
+		{".5", 50},
+		{"-3.5", -350},
+		{"0.125", 13},
+		{"0.124", 12},
+		{"1e3", 100000},
+	}
+
+	for _, tt := range tests {
+		amount, err := ParseMoney(tt.input)
+		if err != nil {
+			t.Errorf("ParseMoney(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if amount != tt.expected {
+			t.Errorf("ParseMoney(%q) = %d, expected %d", tt.input, amount, tt.expected)
+		}
+	}
+
+	for _, input := range []string{"", "abc", "1.2.3", "12a", "."} {
+		if _, err := ParseMoney(input); err == nil {
+			t.Errorf("Expected ParseMoney(%q) to fail", input)
+		}
+	}
+}
+
+// Test Money arithmetic does not drift the way float64 does
+func TestMoneyArithmetic(t *testing.T) {
+	var total Money
+	for i := 0; i < 10; i++ {
+		total += MoneyFromFloat(0.1)
+	}
+	if total != MoneyFromFloat(1) {
+		t.Errorf("Expected ten cents times ten to be 1.00, got %s", total)
+	}
+
+	if cost := MoneyFromFloat(140.35).Mul(3); cost.String() != "421.05" {
+		t.Errorf("Expected 421.05, got %s", cost)
+	}
+	if avg := MoneyFromFloat(100).Div(3); avg.String() != "33.33" {
+		t.Errorf("Expected 33.33, got %s", avg)
+	}
+	if MoneyFromFloat(-2.5).String() != "-2.50" {
+		t.Errorf("Expected -2.50, got %s", MoneyFromFloat(-2.5))
+	}
+}
+
+// Test Money JSON - plain numbers out, numbers or numeric strings in
+func TestMoneyJSON(t *testing.T) {
+	data, err := json.Marshal(struct {
+		Price Money `json:"price"`
+	}{MoneyFromFloat(150.25)})
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	if string(data) != `{"price":150.25}` {
+		t.Errorf("Unexpected JSON: %s", data)
+	}
+
+	for input, expected := range map[string]Money{
+		`{"price":230}`:     23000,
+		`{"price":150.25}`:  15025,
+		`{"price":"99.99"}`: 9999,
+		`{"price":null}`:    0,
+		`{"price":10.005}`:  1001,
+	} {
+		var decoded struct {
+			Price Money `json:"price"`
+		}
+		if err := json.Unmarshal([]byte(input), &decoded); err != nil {
+			t.Errorf("Failed to unmarshal %s: %v", input, err)
+			continue
+		}
+		if decoded.Price != expected {
+			t.Errorf("Unmarshal %s = %d, expected %d", input, decoded.Price, expected)
+		}
+	}
+
+	var decoded struct {
+		Price Money `json:"price"`
+	}
+	if err := json.Unmarshal([]byte(`{"price":"cheap"}`), &decoded); err == nil {
+		t.Error("Expected an invalid amount to fail")
+	}
+}
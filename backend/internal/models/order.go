@@ -1,38 +1,335 @@
 package models
 
 import (
+	"strconv"
+	"strings"
 	"time"
 )
 
 type OrderType string
+type OrderKind string
 type OrderStatus string
+type TimeInForce string
+type SelfTradePrevention string
+type PostOnly string
 
 const (
 	Buy  OrderType = "buy"
 	Sell OrderType = "sell"
 
-	Open      OrderStatus = "open"
-	Filled    OrderStatus = "filled"
-	Cancelled OrderStatus = "cancelled"
+	Limit     OrderKind = "limit"
+	Market    OrderKind = "market"     // Sweeps the book, any unfilled remainder is cancelled
+	Stop      OrderKind = "stop"       // Becomes a market order once StopPrice trades
+	StopLimit OrderKind = "stop_limit" // Becomes a limit order at Price once StopPrice trades
+
+	Open            OrderStatus = "open"
+	PartiallyFilled OrderStatus = "partially_filled"
+	Filled          OrderStatus = "filled"
+	Cancelled       OrderStatus = "cancelled"
+	Expired         OrderStatus = "expired"
+	Pending         OrderStatus = "pending" // A bracket exit waiting for its entry to fill
+
+	GTC TimeInForce = "GTC" // Good till cancelled
+	Day TimeInForce = "DAY" // Expires at session close
+	IOC TimeInForce = "IOC" // Immediate or cancel, the unfilled remainder never rests
+	FOK TimeInForce = "FOK" // Fill or kill, filled in full on entry or not at all
+	GTD TimeInForce = "GTD" // Good till date, expires at ExpiresAt
+
+	// Self-trade prevention decides what happens when an incoming order would
+	// trade against a resting order of the same trader
+	CancelNewest       SelfTradePrevention = "cancel_newest"        // Cancel the incoming order, keep the resting one
+	CancelOldest       SelfTradePrevention = "cancel_oldest"        // Cancel the resting order and keep matching
+	CancelBoth         SelfTradePrevention = "cancel_both"          // Cancel both orders
+	DecrementAndCancel SelfTradePrevention = "decrement_and_cancel" // Reduce both by the smaller size, cancel whichever reaches zero
+
+	// A post-only order must never take liquidity. One that would trade on
+	// entry is either rejected or repriced one tick short of the best
+	// opposite price.
+	PostOnlyReject  PostOnly = "reject"
+	PostOnlyReprice PostOnly = "reprice"
 )
 
+// DefaultSelfTradePrevention applies when neither the order nor its trader picks a mode
+const DefaultSelfTradePrevention = CancelNewest
+
+// IsValid reports whether the mode is one of the supported self-trade prevention modes
+func (m SelfTradePrevention) IsValid() bool {
+	switch m {
+	case CancelNewest, CancelOldest, CancelBoth, DecrementAndCancel:
+		return true
+	}
+	return false
+}
+
+// IsValid reports whether the mode is one of the supported post-only modes
+func (p PostOnly) IsValid() bool {
+	return p == PostOnlyReject || p == PostOnlyReprice
+}
+
 type Order struct {
 	ID        string      `json:"id"`
 	TraderID  string      `json:"traderId"`
 	StockID   string      `json:"stockId"`
 	Type      OrderType   `json:"type"`
-	Price     float64     `json:"price"`
-	Quantity  int         `json:"quantity"`
+	Kind      OrderKind   `json:"kind"`
+	Price     Money       `json:"price"`    // Limit price, unused for market orders
+	Quantity  int         `json:"quantity"` // Remaining (unfilled) quantity
 	Status    OrderStatus `json:"status"`
 	CreatedAt time.Time   `json:"createdAt"`
+	UpdatedAt time.Time   `json:"updatedAt"`
+
+	OriginalQuantity int    `json:"originalQuantity"`
+	FilledQuantity   int    `json:"filledQuantity"`
+	AvgFillPrice     Money  `json:"avgFillPrice"`
+	Fills            []Fill `json:"fills"`
+
+	TimeInForce TimeInForce `json:"timeInForce"`
+	ExpiresAt   *time.Time  `json:"expiresAt,omitempty"` // Set for DAY and GTD orders
+
+	StopPrice   Money      `json:"stopPrice,omitempty"`   // Trigger price for stop and stop-limit orders
+	TriggeredAt *time.Time `json:"triggeredAt,omitempty"` // When a stop order entered matching
+
+	// A trailing stop order's stop price follows the best price seen since it
+	// was placed (the highest for a sell, the lowest for a buy) at a distance
+	// of TrailAmount, or TrailPercent (a fraction, 0.05 for 5%) of that price.
+	// TrailPeak is the best price seen so far.
+	TrailAmount  Money   `json:"trailAmount,omitempty"`
+	TrailPercent float64 `json:"trailPercent,omitempty"`
+	TrailPeak    Money   `json:"trailPeak,omitempty"`
+
+	SelfTradePrevention SelfTradePrevention `json:"selfTradePrevention"` // Applied when this order is the incoming one
+
+	// An iceberg order only shows DisplayQuantity shares on the book at a
+	// time. Displayed is what is left of the current peak; once it trades
+	// away the peak is refreshed from the hidden reserve.
+	DisplayQuantity int `json:"displayQuantity,omitempty"`
+	Displayed       int `json:"displayed,omitempty"`
+
+	PostOnly PostOnly `json:"postOnly,omitempty"`
+	// A hidden order is never shown on the book and queues behind the
+	// displayed orders at its price
+	Hidden bool `json:"hidden,omitempty"`
+
+	ReservedCash   Money `json:"reservedCash,omitempty"`   // Money still held back for an open buy order
+	ReservedShares int   `json:"reservedShares,omitempty"` // Shares still held back for an open sell order
+
+	// Linked orders. The legs of a one-cancels-other pair name each other;
+	// a bracket entry names its take-profit and stop-loss exits, which are
+	// themselves a one-cancels-other pair.
+	OCOLegID string   `json:"ocoLegId,omitempty"`
+	ParentID string   `json:"parentId,omitempty"` // The entry of a bracket exit
+	ExitIDs  []string `json:"exitIds,omitempty"`
+
+	ocoLeg *Order
+	exits  []*Order
+
+	// Where the exchange keeps the order, so it can be put back exactly
+	// after a restart: its arrival in the order store and its place in the
+	// queue of the book it waits on
+	arrival uint64
+	queued  uint64
+}
+
+// Fill is a single execution against an order, linked to its transaction
+type Fill struct {
+	TransactionID string    `json:"transactionId"`
+	Price         Money     `json:"price"`
+	Quantity      int       `json:"quantity"`
+	ExecutedAt    time.Time `json:"executedAt"`
+}
+
+// LinkOCO makes two orders the legs of a one-cancels-other pair
+func LinkOCO(a, b *Order) {
+	a.OCOLegID, b.OCOLegID = b.ID, a.ID
+	a.ocoLeg, b.ocoLeg = b, a
+}
+
+// OCOLeg returns the other leg of a one-cancels-other pair, or nil
+func (o *Order) OCOLeg() *Order {
+	return o.ocoLeg
+}
+
+// AddExits makes orders the exits of a bracket entry
+func (o *Order) AddExits(exits ...*Order) {
+	for _, exit := range exits {
+		exit.ParentID = o.ID
+		o.ExitIDs = append(o.ExitIDs, exit.ID)
+		o.exits = append(o.exits, exit)
+	}
+}
+
+// Exits returns the exits of a bracket entry
+func (o *Order) Exits() []*Order {
+	return o.exits
+}
+
+// Relink restores the links of an order read back from storage, looking the
+// linked orders up with find
+func (o *Order) Relink(find func(orderID string) *Order) {
+	o.ocoLeg = nil
+	if o.OCOLegID != "" {
+		o.ocoLeg = find(o.OCOLegID)
+	}
+	o.exits = nil
+	for _, id := range o.ExitIDs {
+		if exit := find(id); exit != nil {
+			o.exits = append(o.exits, exit)
+		}
+	}
+}
+
+// Arrival returns the order's place in the order store
+func (o *Order) Arrival() uint64 {
+	return o.arrival
+}
+
+// SetArrival records the order's place in the order store
+func (o *Order) SetArrival(seq uint64) {
+	o.arrival = seq
+}
+
+// Queued returns when the order joined the queue of the book it waits on;
+// orders that queued later are behind it at the same price
+func (o *Order) Queued() uint64 {
+	return o.queued
+}
+
+// SetQueued records when the order joined the queue of the book it waits on
+func (o *Order) SetQueued(seq uint64) {
+	o.queued = seq
+}
+
+// IsActive reports whether the order can still trade
+func (o *Order) IsActive() bool {
+	return o.Status == Open || o.Status == PartiallyFilled
+}
+
+// SetStatus moves the order to a new status and stamps the update time
+func (o *Order) SetStatus(status OrderStatus) {
+	o.Status = status
+	o.UpdatedAt = time.Now()
+}
+
+// AddFill records an execution: the remaining quantity goes down, the filled
+// quantity and average fill price go up, and the status follows
+func (o *Order) AddFill(fill Fill) {
+	o.Fills = append(o.Fills, fill)
+	o.FilledQuantity += fill.Quantity
+
+	// Average over the exact fill values so rounding never accumulates
+	filledValue := Money(0)
+	for _, f := range o.Fills {
+		filledValue += f.Price.Mul(f.Quantity)
+	}
+	o.AvgFillPrice = filledValue.Div(o.FilledQuantity)
+	o.Quantity -= fill.Quantity
+	o.Displayed = max(o.Displayed-fill.Quantity, 0)
+
+	if o.Quantity == 0 {
+		o.Status = Filled
+	} else {
+		o.Status = PartiallyFilled
+	}
+	o.UpdatedAt = fill.ExecutedAt
+}
+
+// IsMarket reports whether the order trades at any price; orders without a
+// kind are treated as limit orders
+func (o *Order) IsMarket() bool {
+	return o.Kind == Market || o.Kind == Stop
+}
+
+// IsStop reports whether the order waits for a trigger price before matching
+func (o *Order) IsStop() bool {
+	return o.Kind == Stop || o.Kind == StopLimit
+}
+
+// IsTriggered reports whether a stop order has entered matching
+func (o *Order) IsTriggered() bool {
+	return o.TriggeredAt != nil
+}
+
+// IsTrailing reports whether a stop order's stop price trails the market
+func (o *Order) IsTrailing() bool {
+	return o.TrailAmount > 0 || o.TrailPercent > 0
+}
+
+// TrailFrom returns the stop price of a trailing stop order whose best
+// price seen is peak: below it for a sell, above it for a buy
+func (o *Order) TrailFrom(peak Money) Money {
+	trail := o.TrailAmount
+	if o.TrailPercent > 0 {
+		trail = peak.MulFloat(o.TrailPercent)
+	}
+	if o.Type == Buy {
+		return peak + trail
+	}
+	return max(peak-trail, 0)
+}
+
+// IsImmediate reports whether the order must never rest on the book
+func (o *Order) IsImmediate() bool {
+	return o.IsMarket() || o.TimeInForce == IOC || o.TimeInForce == FOK
+}
+
+// IsIceberg reports whether only part of the order is shown on the book
+func (o *Order) IsIceberg() bool {
+	return o.DisplayQuantity > 0
+}
+
+// Visible returns the quantity the order shows on the book
+func (o *Order) Visible() int {
+	if !o.IsIceberg() {
+		return o.Quantity
+	}
+	return min(o.Displayed, o.Quantity)
+}
+
+// RefreshPeak shows a new peak of an iceberg order from its remaining quantity
+func (o *Order) RefreshPeak() {
+	if o.IsIceberg() {
+		o.Displayed = min(o.DisplayQuantity, o.Quantity)
+	}
+}
+
+// Public returns the order as other traders may see it on the book: an
+// iceberg order shows its displayed peak and nothing of its reserve
+func (o *Order) Public() Order {
+	public := *o
+	if o.IsIceberg() {
+		public.Quantity = o.Visible()
+		public.OriginalQuantity = o.FilledQuantity + public.Quantity
+		public.DisplayQuantity = 0
+		public.Displayed = 0
+		public.ReservedCash = 0
+		public.ReservedShares = 0
+	}
+	return public
+}
+
+// IsExpired reports whether a resting order has passed its expiry time
+func (o *Order) IsExpired(now time.Time) bool {
+	return o.ExpiresAt != nil && !now.Before(*o.ExpiresAt)
 }
 
 type Transaction struct {
-	ID         string    `json:"id"`
-	BuyerID    string    `json:"buyerId"`
-	SellerID   string    `json:"sellerId"`
-	StockID    string    `json:"stockId"`
-	Price      float64   `json:"price"`
-	Quantity   int       `json:"quantity"`
-	ExecutedAt time.Time `json:"executedAt"`
+	ID          string `json:"id"`
+	BuyerID     string `json:"buyerId"`
+	SellerID    string `json:"sellerId"`
+	BuyOrderID  string `json:"buyOrderId"`
+	SellOrderID string `json:"sellOrderId"`
+	StockID     string `json:"stockId"`
+	Price       Money  `json:"price"` // Price of the resting (maker) order, or the auction clearing price
+	Quantity    int    `json:"quantity"`
+	// AggressorSide is the side of the incoming order that took liquidity;
+	// the other side was the passive maker. Auction trades have no aggressor.
+	AggressorSide OrderType `json:"aggressorSide"`
+	ExecutedAt    time.Time `json:"executedAt"`
+}
+
+// Number returns the number in the transaction's ID. Transactions are
+// numbered in the order they were executed.
+func (t Transaction) Number() int64 {
+	number, _ := strconv.ParseInt(strings.TrimPrefix(t.ID, "tx-"), 10, 64)
+	return number
 }
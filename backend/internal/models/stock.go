@@ -1,50 +1,461 @@
 package models
 
 import (
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 )
 
 type Stock struct {
-	ID           string  `json:"id"`
-	Name         string  `json:"name"`
-	CurrentPrice float64 `json:"currentPrice"`
-	Amount       int     `json:"amount"`
-	mu           sync.RWMutex
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	CurrentPrice Money  `json:"currentPrice"`
+	Amount       int    `json:"amount"`
+	// SupplyPeak makes the exchange's initial sell order of Amount shares an
+	// iceberg order showing this many at a time; zero shows it all
+	SupplyPeak int `json:"supplyPeak,omitempty"`
+
+	// Trading rules, fixed once the stock is listed. A zero TickSize uses
+	// TickTable, or DefaultTickTable when that is empty too; zero quantity
+	// limits mean no limit.
+	TickSize    Money      `json:"tickSize,omitempty"`
+	TickTable   []TickBand `json:"tickTable,omitempty"`
+	LotSize     int        `json:"lotSize,omitempty"`
+	MinQuantity int        `json:"minQuantity,omitempty"`
+	MaxQuantity int        `json:"maxQuantity,omitempty"`
+
+	// Price band and circuit breaker settings. Limit orders must be priced
+	// within PriceBand (a fraction, 0.1 for ±10%) of ReferencePrice and a
+	// trade outside it halts the stock for HaltSeconds. A zero PriceBand
+	// turns both off; a zero HaltSeconds uses DefaultHaltDuration.
+	PriceBand   float64 `json:"priceBand,omitempty"`
+	HaltSeconds int     `json:"haltSeconds,omitempty"`
+
+	// Which of the daily call auctions the stock takes part in. Without an
+	// opening auction it only takes cancellations before the open, and
+	// without a closing auction only cancellations while the market closes.
+	Auctions AuctionSchedule `json:"auctions"`
+
+	ReferencePrice Money         `json:"referencePrice"`
+	ClosingPrice   Money         `json:"closingPrice,omitempty"` // Set at each close
+	Status         TradingStatus `json:"status"`
+	HaltedUntil    *time.Time    `json:"haltedUntil,omitempty"`
+
+	mu sync.RWMutex
+}
+
+// StockState is the part of a stock that changes as it trades
+type StockState struct {
+	ID             string        `json:"id"`
+	CurrentPrice   Money         `json:"currentPrice"`
+	Amount         int           `json:"amount"`
+	ReferencePrice Money         `json:"referencePrice"`
+	ClosingPrice   Money         `json:"closingPrice,omitempty"`
+	Status         TradingStatus `json:"status"`
+	HaltedUntil    *time.Time    `json:"haltedUntil,omitempty"`
+}
+
+// Equal reports whether two states are the same
+func (s StockState) Equal(other StockState) bool {
+	if (s.HaltedUntil == nil) != (other.HaltedUntil == nil) ||
+		s.HaltedUntil != nil && !s.HaltedUntil.Equal(*other.HaltedUntil) {
+		return false
+	}
+	s.HaltedUntil, other.HaltedUntil = nil, nil
+	return s == other
+}
+
+// TradingStatus is how a stock is handling orders right now
+type TradingStatus string
+
+const (
+	Trading        TradingStatus = "trading"         // Orders match continuously
+	Halted         TradingStatus = "halted"          // Orders are collected for the reopening auction
+	OpeningAuction TradingStatus = "opening-auction" // Orders are collected for the opening auction
+	ClosingAuction TradingStatus = "closing-auction" // Orders are collected for the closing auction
+	CancelOnly     TradingStatus = "cancel-only"     // No new orders or amendments
+	Closed         TradingStatus = "closed"          // No new orders or amendments
+)
+
+// AuctionSchedule turns a stock's opening and closing auctions on
+type AuctionSchedule struct {
+	Opening bool `json:"opening"`
+	Closing bool `json:"closing"`
 }
 
-func (s *Stock) GetPrice() float64 {
+// DefaultHaltDuration is how long a circuit breaker halt lasts
+const DefaultHaltDuration = 5 * time.Minute
+
+// TickBand is the tick size for prices from MinPrice up to the next band
+type TickBand struct {
+	MinPrice Money `json:"minPrice"`
+	TickSize Money `json:"tickSize"`
+}
+
+// DefaultTickTable prices cheap stocks in cents and expensive ones in
+// coarser steps
+var DefaultTickTable = []TickBand{
+	{MinPrice: 0, TickSize: 1},                  // $0.01 below $100
+	{MinPrice: 100 * MinorUnits, TickSize: 5},   // $0.05 from $100
+	{MinPrice: 1000 * MinorUnits, TickSize: 10}, // $0.10 from $1000
+}
+
+func (s *Stock) GetPrice() Money {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return s.CurrentPrice
 }
 
-func (s *Stock) SetPrice(price float64) {
+func (s *Stock) SetPrice(price Money) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.CurrentPrice = price
 }
 
-// PriceQuote represents a historical price point
-type PriceQuote struct {
-	Timestamp time.Time `json:"timestamp"`
-	Price     float64   `json:"price"`
-	Volume    int       `json:"volume"`
+// AddAmount changes the number of shares the exchange holds and returns the
+// new number
+func (s *Stock) AddAmount(quantity int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Amount += quantity
+	return s.Amount
+}
+
+// GetReferencePrice returns the price the stock's price band is centred on
+func (s *Stock) GetReferencePrice() Money {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ReferencePrice
+}
+
+func (s *Stock) SetReferencePrice(price Money) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ReferencePrice = price
+}
+
+// State returns the stock's trading state
+func (s *Stock) State() StockState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return StockState{
+		ID:             s.ID,
+		CurrentPrice:   s.CurrentPrice,
+		Amount:         s.Amount,
+		ReferencePrice: s.ReferencePrice,
+		ClosingPrice:   s.ClosingPrice,
+		Status:         s.Status,
+		HaltedUntil:    s.HaltedUntil,
+	}
+}
+
+// Restore puts the stock back in a trading state it was in before
+func (s *Stock) Restore(state StockState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.CurrentPrice = state.CurrentPrice
+	s.Amount = state.Amount
+	s.ReferencePrice = state.ReferencePrice
+	s.ClosingPrice = state.ClosingPrice
+	s.Status = state.Status
+	s.HaltedUntil = state.HaltedUntil
+}
+
+// Clone returns a copy of the stock
+func (s *Stock) Clone() *Stock {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &Stock{
+		ID:             s.ID,
+		Name:           s.Name,
+		CurrentPrice:   s.CurrentPrice,
+		Amount:         s.Amount,
+		SupplyPeak:     s.SupplyPeak,
+		TickSize:       s.TickSize,
+		TickTable:      append([]TickBand(nil), s.TickTable...),
+		LotSize:        s.LotSize,
+		MinQuantity:    s.MinQuantity,
+		MaxQuantity:    s.MaxQuantity,
+		PriceBand:      s.PriceBand,
+		HaltSeconds:    s.HaltSeconds,
+		Auctions:       s.Auctions,
+		ReferencePrice: s.ReferencePrice,
+		ClosingPrice:   s.ClosingPrice,
+		Status:         s.Status,
+		HaltedUntil:    s.HaltedUntil,
+	}
+}
+
+// Public returns a copy of the stock as traders may see it. With a
+// SupplyPeak the exchange's supply rests as an iceberg order, so Amount shows
+// no more than its peak and nothing of its reserve.
+func (s *Stock) Public() *Stock {
+	public := s.Clone()
+	if public.SupplyPeak > 0 {
+		public.Amount = min(public.Amount, public.SupplyPeak)
+	}
+	return public
+}
+
+// PriceBandLimits returns the lowest and highest prices allowed around the
+// reference price, or zeros if the stock has no price band
+func (s *Stock) PriceBandLimits() (lower, upper Money) {
+	reference := s.GetReferencePrice()
+	if s.PriceBand == 0 || reference <= 0 {
+		return 0, 0
+	}
+	return s.RoundToTick(reference.MulFloat(1 - s.PriceBand)), s.RoundToTick(reference.MulFloat(1 + s.PriceBand))
+}
+
+// InPriceBand reports whether a price is within the stock's price band
+func (s *Stock) InPriceBand(price Money) bool {
+	lower, upper := s.PriceBandLimits()
+	if upper == 0 {
+		return true
+	}
+	return price >= lower && price <= upper
+}
+
+// HaltDuration returns how long a circuit breaker halt of the stock lasts
+func (s *Stock) HaltDuration() time.Duration {
+	if s.HaltSeconds > 0 {
+		return time.Duration(s.HaltSeconds) * time.Second
+	}
+	return DefaultHaltDuration
+}
+
+// Halt stops continuous trading in the stock until the given time
+func (s *Stock) Halt(until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Status = Halted
+	s.HaltedUntil = &until
+}
+
+// GetStatus returns how the stock is handling orders
+func (s *Stock) GetStatus() TradingStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.Status == "" {
+		return Trading
+	}
+	return s.Status
+}
+
+// SetStatus moves the stock to a new status, ending any halt
+func (s *Stock) SetStatus(status TradingStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Status = status
+	s.HaltedUntil = nil
+}
+
+// IsTrading reports whether the stock is matching orders continuously
+func (s *Stock) IsTrading() bool {
+	return s.GetStatus() == Trading
+}
+
+// AcceptsOrders reports whether new orders and amendments are taken
+func (s *Stock) AcceptsOrders() bool {
+	status := s.GetStatus()
+	return status != CancelOnly && status != Closed
+}
+
+// InAuction reports whether the stock is collecting orders for an auction,
+// including the reopening auction of a halt
+func (s *Stock) InAuction() bool {
+	status := s.GetStatus()
+	return status == Halted || status == OpeningAuction || status == ClosingAuction
+}
+
+func (s *Stock) GetClosingPrice() Money {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ClosingPrice
+}
+
+func (s *Stock) SetClosingPrice(price Money) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ClosingPrice = price
+}
+
+func (s *Stock) IsHalted() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Status == Halted
+}
+
+// HaltOver reports whether the stock is halted and its halt has run its course
+func (s *Stock) HaltOver(now time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Status == Halted && !now.Before(*s.HaltedUntil)
+}
+
+// ValidateRules checks the stock's trading rules are consistent
+func (s *Stock) ValidateRules() error {
+	if s.TickSize < 0 {
+		return fmt.Errorf("stock %s: tick size must not be negative", s.ID)
+	}
+	for i, band := range s.TickTable {
+		if band.TickSize <= 0 {
+			return fmt.Errorf("stock %s: tick table sizes must be greater than 0", s.ID)
+		}
+		if i == 0 && band.MinPrice != 0 {
+			return fmt.Errorf("stock %s: tick table must start at price 0", s.ID)
+		}
+		if i > 0 && band.MinPrice <= s.TickTable[i-1].MinPrice {
+			return fmt.Errorf("stock %s: tick table prices must be increasing", s.ID)
+		}
+	}
+	if s.LotSize < 0 || s.MinQuantity < 0 || s.MaxQuantity < 0 {
+		return fmt.Errorf("stock %s: lot size and quantity limits must not be negative", s.ID)
+	}
+	if s.MaxQuantity > 0 && s.MinQuantity > s.MaxQuantity {
+		return fmt.Errorf("stock %s: min quantity is above max quantity", s.ID)
+	}
+	if s.PriceBand < 0 || s.PriceBand >= 1 {
+		return fmt.Errorf("stock %s: price band must be between 0 and 1", s.ID)
+	}
+	if s.HaltSeconds < 0 {
+		return fmt.Errorf("stock %s: halt seconds must not be negative", s.ID)
+	}
+	if s.SupplyPeak < 0 || s.SupplyPeak%s.Lot() != 0 {
+		return fmt.Errorf("stock %s: supply peak must be a whole number of lots", s.ID)
+	}
+	return nil
+}
+
+// TickFor returns the tick size that applies at the given price
+func (s *Stock) TickFor(price Money) Money {
+	if s.TickSize > 0 {
+		return s.TickSize
+	}
+	table := s.TickTable
+	if len(table) == 0 {
+		table = DefaultTickTable
+	}
+
+	// The last band starting at or below the price
+	i := sort.Search(len(table), func(i int) bool { return table[i].MinPrice > price })
+	if i == 0 {
+		return table[0].TickSize
+	}
+	return table[i-1].TickSize
+}
+
+// OnTick reports whether a price is a whole number of ticks
+func (s *Stock) OnTick(price Money) bool {
+	return price%s.TickFor(price) == 0
+}
+
+// RoundToTick rounds a price to the nearest valid tick, never below one tick
+func (s *Stock) RoundToTick(price Money) Money {
+	tick := s.TickFor(price)
+	rounded := (price + tick/2) / tick * tick
+	if !s.OnTick(rounded) {
+		// Rounding crossed into a band with a coarser tick
+		tick = s.TickFor(rounded)
+		rounded = (rounded + tick/2) / tick * tick
+	}
+	if rounded < tick {
+		return tick
+	}
+	return rounded
+}
+
+// TickBelow returns the highest valid price below price, or zero if there is none
+func (s *Stock) TickBelow(price Money) Money {
+	below := price - 1
+	if below <= 0 {
+		return 0
+	}
+	tick := s.TickFor(below)
+	return below / tick * tick
+}
+
+// TickAbove returns the lowest valid price above price
+func (s *Stock) TickAbove(price Money) Money {
+	above := price + 1
+	tick := s.TickFor(above)
+	above = (above + tick - 1) / tick * tick
+	if !s.OnTick(above) {
+		// Rounding up crossed into a band with a coarser tick
+		tick = s.TickFor(above)
+		above = (above + tick - 1) / tick * tick
+	}
+	return above
+}
+
+// Lot returns the number of shares orders must be a multiple of
+func (s *Stock) Lot() int {
+	if s.LotSize > 0 {
+		return s.LotSize
+	}
+	return 1
+}
+
+// ValidQuantity checks an order quantity against the lot size and limits
+func (s *Stock) ValidQuantity(quantity int) error {
+	if quantity%s.Lot() != 0 {
+		return fmt.Errorf("quantity must be a multiple of the lot size %d", s.Lot())
+	}
+	if quantity < s.MinQuantity {
+		return fmt.Errorf("quantity must be at least %d", s.MinQuantity)
+	}
+	if s.MaxQuantity > 0 && quantity > s.MaxQuantity {
+		return fmt.Errorf("quantity must be at most %d", s.MaxQuantity)
+	}
+	return nil
+}
+
+// RoundQuantity rounds a quantity down to whole lots within the maximum,
+// or returns 0 if that leaves less than the minimum
+func (s *Stock) RoundQuantity(quantity int) int {
+	if s.MaxQuantity > 0 && quantity > s.MaxQuantity {
+		quantity = s.MaxQuantity
+	}
+	quantity -= quantity % s.Lot()
+	if quantity < s.MinQuantity {
+		return 0
+	}
+	return quantity
+}
+
+// Tick is a price a stock traded or moved to. Moves without a trade have no
+// quantity.
+type Tick struct {
+	Time     time.Time `json:"time"`
+	Price    Money     `json:"price"`
+	Quantity int       `json:"quantity"`
+}
+
+// Candle summarises the ticks in one interval of a stock's price history
+type Candle struct {
+	Time   time.Time `json:"time"` // Start of the interval
+	Open   Money     `json:"open"`
+	High   Money     `json:"high"`
+	Low    Money     `json:"low"`
+	Close  Money     `json:"close"`
+	Volume int       `json:"volume"` // Shares traded
 }
 
 // PerformanceData represents trader performance at a point in time
 type PerformanceData struct {
 	Date           time.Time `json:"date"`
-	PortfolioValue float64   `json:"portfolioValue"`
-	ProfitLoss     float64   `json:"profitLoss"`
-	CashBalance    float64   `json:"cashBalance"`
+	PortfolioValue Money     `json:"portfolioValue"` // Cash and holdings
+	ProfitLoss     Money     `json:"profitLoss"`     // Against the trader's initial money
+	CashBalance    Money     `json:"cashBalance"`
+	HoldingsValue  Money     `json:"holdingsValue"` // Holdings at the last prices
 }
 
 // PortfolioData represents current portfolio distribution
 type PortfolioData struct {
 	Holdings    []PortfolioHolding `json:"holdings"`
-	TotalValue  float64            `json:"totalValue"`
-	CashBalance float64            `json:"cashBalance"`
+	TotalValue  Money              `json:"totalValue"`
+	CashBalance Money              `json:"cashBalance"`
 }
 
 // PortfolioHolding represents a single holding in the portfolio
@@ -52,15 +463,15 @@ type PortfolioHolding struct {
 	StockID    string  `json:"stockId"`
 	StockName  string  `json:"stockName"`
 	Quantity   int     `json:"quantity"`
-	Value      float64 `json:"value"`
+	Value      Money   `json:"value"`
 	Percentage float64 `json:"percentage"`
 }
 
 // ActivityLog represents trading activity data
 type ActivityLog struct {
-	Period     string  `json:"period"`
-	BuyOrders  int     `json:"buyOrders"`
-	SellOrders int     `json:"sellOrders"`
-	Volume     int     `json:"volume"`
-	Value      float64 `json:"value"`
+	Period     string `json:"period"`
+	BuyOrders  int    `json:"buyOrders"`
+	SellOrders int    `json:"sellOrders"`
+	Volume     int    `json:"volume"`
+	Value      Money  `json:"value"`
 }
@@ -0,0 +1,104 @@
+package models
+
+import "testing"
+
+// Test tick lookup and rounding across the default and custom tick tables
+func TestStockTicks(t *testing.T) {
+	stock := &Stock{ID: "1"}
+	tests := []struct {
+		price, tick, rounded float64
+	}{
+		{2.013, 0.01, 2.01},
+		{99.99, 0.01, 99.99},
+		{150.02, 0.05, 150.0},
+		{150.03, 0.05, 150.05},
+		{999.98, 0.05, 1000.0},
+		{1500.04, 0.10, 1500.0},
+		{0.001, 0.01, 0.01},
+	}
+	for _, tt := range tests {
+		price := MoneyFromFloat(tt.price)
+		if tick := stock.TickFor(price); tick != MoneyFromFloat(tt.tick) {
+			t.Errorf("TickFor(%s) = %s, expected %.2f", price, tick, tt.tick)
+		}
+		if rounded := stock.RoundToTick(price); rounded != MoneyFromFloat(tt.rounded) {
+			t.Errorf("RoundToTick(%.3f) = %s, expected %.2f", tt.price, rounded, tt.rounded)
+		}
+	}
+
+	// A fixed tick size overrides the table
+	stock.TickSize = MoneyFromFloat(0.25)
+	if stock.OnTick(MoneyFromFloat(10.10)) || !stock.OnTick(MoneyFromFloat(10.25)) {
+		t.Error("Expected prices to be checked against the fixed tick size")
+	}
+}
+
+// Test the next valid prices either side of a price, across tick bands
+func TestStockTickNeighbours(t *testing.T) {
+	stock := &Stock{ID: "1"}
+	tests := []struct {
+		price, below, above float64
+	}{
+		{50.00, 49.99, 50.01},
+		{100.00, 99.99, 100.05},
+		{99.99, 99.98, 100.00},
+		{100.05, 100.00, 100.10},
+		{1000.00, 999.95, 1000.10},
+		{0.01, 0, 0.02},
+	}
+	for _, tt := range tests {
+		price := MoneyFromFloat(tt.price)
+		if below := stock.TickBelow(price); below != MoneyFromFloat(tt.below) {
+			t.Errorf("TickBelow(%s) = %s, expected %.2f", price, below, tt.below)
+		}
+		if above := stock.TickAbove(price); above != MoneyFromFloat(tt.above) {
+			t.Errorf("TickAbove(%s) = %s, expected %.2f", price, above, tt.above)
+		}
+	}
+}
+
+// Test quantity rounding to lots within the limits
+func TestStockQuantityRules(t *testing.T) {
+	stock := &Stock{ID: "1", LotSize: 100, MinQuantity: 200, MaxQuantity: 1000}
+
+	if err := stock.ValidQuantity(300); err != nil {
+		t.Errorf("Expected 300 to be valid: %v", err)
+	}
+	for _, quantity := range []int{150, 100, 1100} {
+		if err := stock.ValidQuantity(quantity); err == nil {
+			t.Errorf("Expected %d to be invalid", quantity)
+		}
+	}
+
+	for quantity, expected := range map[int]int{350: 300, 199: 0, 5000: 1000, 250: 200} {
+		if rounded := stock.RoundQuantity(quantity); rounded != expected {
+			t.Errorf("RoundQuantity(%d) = %d, expected %d", quantity, rounded, expected)
+		}
+	}
+
+	if err := (&Stock{ID: "1", TickTable: []TickBand{{MinPrice: 100, TickSize: 1}}}).ValidateRules(); err == nil {
+		t.Error("Expected a tick table not starting at 0 to be rejected")
+	}
+	if err := (&Stock{ID: "1", MinQuantity: 10, MaxQuantity: 5}).ValidateRules(); err == nil {
+		t.Error("Expected min quantity above max quantity to be rejected")
+	}
+}
+
+// Test the public view hides the exchange's reserve supply
+func TestStockPublic(t *testing.T) {
+	stock := &Stock{ID: "1", Amount: 1000, SupplyPeak: 100}
+	if public := stock.Public(); public.Amount != 100 {
+		t.Errorf("Expected only the peak of 100 shares to show, got %d", public.Amount)
+	}
+	if stock.Amount != 1000 {
+		t.Errorf("Expected the stock itself to keep its amount, got %d", stock.Amount)
+	}
+
+	stock.Amount = 40
+	if public := stock.Public(); public.Amount != 40 {
+		t.Errorf("Expected a supply below the peak to show in full, got %d", public.Amount)
+	}
+	if public := (&Stock{ID: "2", Amount: 500}).Public(); public.Amount != 500 {
+		t.Errorf("Expected a stock without a peak to show its whole supply, got %d", public.Amount)
+	}
+}
@@ -5,18 +5,37 @@ import "sync"
 type Trader struct {
 	ID           string         `json:"id"`
 	Name         string         `json:"name"`
-	Money        float64        `json:"money"`
-	InitialMoney float64        `json:"initialMoney"`
+	Money        Money          `json:"money"`
+	InitialMoney Money          `json:"initialMoney"`
 	Holdings     map[string]int `json:"holdings"`
-	mu           sync.RWMutex
+
+	// SelfTradePrevention is the default mode for the trader's orders
+	SelfTradePrevention SelfTradePrevention `json:"selfTradePrevention,omitempty"`
+
+	// Cash and shares committed to the trader's open orders. They are still
+	// part of Money and Holdings until the orders fill.
+	ReservedCash   Money          `json:"-"`
+	ReservedShares map[string]int `json:"-"`
+	mu             sync.RWMutex
 }
 
-func NewTrader(id, name string, money float64) *Trader {
+func NewTrader(id, name string, money Money) *Trader {
 	return &Trader{
-		ID:           id,
-		Name:         name,
-		Money:        money,
-		InitialMoney: money, // Store initial amount
-		Holdings:     make(map[string]int),
+		ID:             id,
+		Name:           name,
+		Money:          money,
+		InitialMoney:   money, // Store initial amount
+		Holdings:       make(map[string]int),
+		ReservedShares: make(map[string]int),
 	}
 }
+
+// AvailableCash returns the money not committed to open buy orders
+func (t *Trader) AvailableCash() Money {
+	return t.Money - t.ReservedCash
+}
+
+// AvailableShares returns the shares of a stock not committed to open sell orders
+func (t *Trader) AvailableShares(stockID string) int {
+	return t.Holdings[stockID] - t.ReservedShares[stockID]
+}
@@ -21,8 +21,8 @@ type AlgoTrader struct {
 	// Trading state
 	LastAction   time.Time
 	OrdersPlaced int
-	ProfitLoss   float64
-	InitialMoney float64
+	ProfitLoss   models.Money
+	InitialMoney models.Money
 }
 
 // AlgoConfig contains configuration for the algorithmic trader
@@ -41,6 +41,7 @@ type AlgorithmManager struct {
 	exchange *Exchange
 	running  bool
 	ticker   *time.Ticker
+	done     chan bool
 }
 
 // NewAlgorithmManager creates a new algorithm manager
@@ -65,7 +66,7 @@ func NewAlgorithmManager(exchange *Exchange) *AlgorithmManager {
 			MomentumThreshold: 0.025, // 2.5% momentum threshold (more aggressive)
 			ContrarianSpread:  0.005, // Not used for momentum strategy
 		},
-		InitialMoney: 50000,
+		InitialMoney: models.MoneyFromFloat(50000),
 	})
 
 	am.traders = append(am.traders, &AlgoTrader{
@@ -82,7 +83,7 @@ func NewAlgorithmManager(exchange *Exchange) *AlgorithmManager {
 			MomentumThreshold: 0.02,  // Not used for contrarian strategy
 			ContrarianSpread:  0.007, // 0.7% spread (more conservative than before)
 		},
-		InitialMoney: 40000,
+		InitialMoney: models.MoneyFromFloat(40000),
 	})
 
 	return am
@@ -96,6 +97,7 @@ func (am *AlgorithmManager) Start() {
 
 	am.running = true
 	am.ticker = time.NewTicker(5 * time.Second) // Execute every 5 seconds for faster testing
+	am.done = make(chan bool)
 
 	log.Println("🤖 Algorithm Manager started - bots are now trading!")
 
@@ -103,21 +105,28 @@ func (am *AlgorithmManager) Start() {
 		for {
 			select {
 			case <-am.ticker.C:
-				if am.running {
+				// Bots sit out whenever the market is not open
+				if am.exchange.IsMarketOpen() {
 					log.Printf("🕐 Algorithm Manager tick - executing strategies...")
 					am.executeAllStrategies()
 				}
+			case <-am.done:
+				return
 			}
 		}
 	}()
 }
 
-// Stop halts algorithmic trading
+// Stop halts algorithmic trading, waiting for strategies already running to
+// finish
 func (am *AlgorithmManager) Stop() {
-	am.running = false
-	if am.ticker != nil {
-		am.ticker.Stop()
+	if !am.running {
+		return
 	}
+
+	am.running = false
+	am.ticker.Stop()
+	am.done <- true
 	log.Println("🛑 Algorithm Manager stopped")
 }
 
@@ -151,15 +160,16 @@ func (at *AlgoTrader) executeMomentumStrategy() {
 		}
 
 		// Calculate momentum (price change percentage)
+		currentPrice := stock.GetPrice().Float64()
 		momentum := (priceHistory[len(priceHistory)-1] - priceHistory[0]) / priceHistory[0]
-		log.Printf("📊 %s: %s momentum = %.4f%% (price: $%.2f, threshold: %.2f%%)", at.Name, stock.ID, momentum*100, stock.CurrentPrice, at.Config.MomentumThreshold*100)
+		log.Printf("📊 %s: %s momentum = %.4f%% (price: $%.2f, threshold: %.2f%%)", at.Name, stock.ID, momentum*100, currentPrice, at.Config.MomentumThreshold*100)
 
 		// Strong upward momentum - BUY
 		if momentum > at.Config.MomentumThreshold {
 			quantity := at.calculateOrderQuantity(stock, "buy")
 			if quantity > 0 {
 				log.Printf("🚀 %s: BUYING %s! Strong upward momentum %.2f%% (above %.2f%% threshold)", at.Name, stock.ID, momentum*100, at.Config.MomentumThreshold*100)
-				at.placeBuyOrder(stock.ID, quantity, stock.CurrentPrice*1.001) // Slightly above market
+				at.placeBuyOrder(stock.ID, quantity, currentPrice*1.001) // Slightly above market
 			} else {
 				log.Printf("💰 %s: Would buy %s but insufficient funds", at.Name, stock.ID)
 			}
@@ -172,7 +182,7 @@ func (at *AlgoTrader) executeMomentumStrategy() {
 				sellQuantity := int(math.Min(float64(holdings), float64(holdings)/2)) // Sell half
 				if sellQuantity > 0 {
 					log.Printf("📉 %s: SELLING %s! Strong downward momentum %.2f%% (below -%.2f%% threshold)", at.Name, stock.ID, momentum*100, at.Config.MomentumThreshold*100)
-					at.placeSellOrder(stock.ID, sellQuantity, stock.CurrentPrice*0.999) // Slightly below market
+					at.placeSellOrder(stock.ID, sellQuantity, currentPrice*0.999) // Slightly below market
 				}
 			}
 		}
@@ -198,7 +208,7 @@ func (at *AlgoTrader) executeContrarianStrategy() {
 		}
 		avgPrice /= float64(len(priceHistory))
 
-		currentPrice := stock.CurrentPrice
+		currentPrice := stock.GetPrice().Float64()
 		priceVsAvg := (currentPrice - avgPrice) / avgPrice
 		log.Printf("📊 %s: %s current=$%.2f vs avg=$%.2f (%.2f%%, threshold: ±%.2f%%)", at.Name, stock.ID, currentPrice, avgPrice, priceVsAvg*100, at.Config.ContrarianSpread*100)
 
@@ -242,7 +252,7 @@ func (at *AlgoTrader) getRecentPrices(stockID string, count int) []float64 {
 	}
 
 	prices := make([]float64, count)
-	basePrice := stock.CurrentPrice
+	basePrice := stock.GetPrice().Float64()
 
 	// Create stock-specific seed for variation
 	// Each stock gets a unique "personality" by converting its ID to a number
@@ -296,21 +306,21 @@ func (at *AlgoTrader) getRecentPrices(stockID string, count int) []float64 {
 }
 
 func (at *AlgoTrader) calculateOrderQuantity(stock *models.Stock, orderType string) int {
-	trader, exists := at.Exchange.GetTrader(at.ID)
+	balance, exists := at.Exchange.GetTraderBalance(at.ID)
 	if !exists {
 		log.Printf("❌ %s: Trader not found in exchange! Cannot calculate order quantity", at.Name)
 		return 0
 	}
 
-	log.Printf("💰 %s: Found trader with $%.2f available", at.Name, trader.Money)
+	log.Printf("💰 %s: Found trader with $%s available", at.Name, balance.AvailableCash)
 
 	if orderType == "buy" {
 		// Use RiskThreshold percentage of available money
-		availableMoney := trader.Money
+		availableMoney := balance.AvailableCash.Float64()
 		orderValue := math.Min(availableMoney*at.Config.RiskThreshold, at.Config.MaxOrderValue) // RiskThreshold% of money or max order value
 		orderValue = math.Max(orderValue, at.Config.MinOrderValue)
 
-		quantity := int(orderValue / stock.CurrentPrice)
+		quantity := stock.RoundQuantity(int(orderValue / stock.GetPrice().Float64()))
 		log.Printf("📊 %s: Calculated buy quantity for %s: %d shares (value: $%.2f)", at.Name, stock.ID, quantity, orderValue)
 		return quantity
 	}
@@ -319,15 +329,20 @@ func (at *AlgoTrader) calculateOrderQuantity(stock *models.Stock, orderType stri
 }
 
 func (at *AlgoTrader) getCurrentHoldings(stockID string) int {
-	trader, exists := at.Exchange.GetTrader(at.ID)
+	balance, exists := at.Exchange.GetTraderBalance(at.ID)
 	if !exists {
 		return 0
 	}
 
-	return trader.Holdings[stockID]
+	return balance.AvailableShares[stockID]
 }
 
 func (at *AlgoTrader) placeBuyOrder(stockID string, quantity int, price float64) {
+	stock, exists := at.Exchange.GetStock(stockID)
+	if !exists {
+		return
+	}
+	quantity = stock.RoundQuantity(quantity)
 	if quantity <= 0 {
 		return
 	}
@@ -337,7 +352,7 @@ func (at *AlgoTrader) placeBuyOrder(stockID string, quantity int, price float64)
 		TraderID:  at.ID,
 		StockID:   stockID,
 		Type:      models.Buy,
-		Price:     price,
+		Price:     stock.RoundToTick(models.MoneyFromFloat(price)),
 		Quantity:  quantity,
 		Status:    models.Open,
 		CreatedAt: time.Now(),
@@ -347,14 +362,19 @@ func (at *AlgoTrader) placeBuyOrder(stockID string, quantity int, price float64)
 	if err == nil {
 		at.OrdersPlaced++
 		at.LastAction = time.Now()
-		log.Printf("🤖 %s placed BUY order: %d shares of %s at $%.2f",
-			at.Name, quantity, stockID, price)
+		log.Printf("🤖 %s placed BUY order: %d shares of %s at $%s",
+			at.Name, quantity, stockID, order.Price)
 	} else {
 		log.Printf("❌ %s failed to place BUY order: %s", at.Name, err.Error())
 	}
 }
 
 func (at *AlgoTrader) placeSellOrder(stockID string, quantity int, price float64) {
+	stock, exists := at.Exchange.GetStock(stockID)
+	if !exists {
+		return
+	}
+	quantity = stock.RoundQuantity(quantity)
 	if quantity <= 0 {
 		return
 	}
@@ -364,7 +384,7 @@ func (at *AlgoTrader) placeSellOrder(stockID string, quantity int, price float64
 		TraderID:  at.ID,
 		StockID:   stockID,
 		Type:      models.Sell,
-		Price:     price,
+		Price:     stock.RoundToTick(models.MoneyFromFloat(price)),
 		Quantity:  quantity,
 		Status:    models.Open,
 		CreatedAt: time.Now(),
@@ -374,8 +394,8 @@ func (at *AlgoTrader) placeSellOrder(stockID string, quantity int, price float64
 	if err == nil {
 		at.OrdersPlaced++
 		at.LastAction = time.Now()
-		log.Printf("🤖 %s placed SELL order: %d shares of %s at $%.2f",
-			at.Name, quantity, stockID, price)
+		log.Printf("🤖 %s placed SELL order: %d shares of %s at $%s",
+			at.Name, quantity, stockID, order.Price)
 	} else {
 		log.Printf("❌ %s failed to place SELL order: %s", at.Name, err.Error())
 	}
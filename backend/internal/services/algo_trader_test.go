@@ -251,7 +251,7 @@ func TestAlgoTrader_Configuration(t *testing.T) {
 		}
 
 		if trader.InitialMoney <= 0 {
-			t.Errorf("Trader %s has invalid InitialMoney: %f", trader.Name, trader.InitialMoney)
+			t.Errorf("Trader %s has invalid InitialMoney: %s", trader.Name, trader.InitialMoney)
 		}
 	}
 }
@@ -273,7 +273,7 @@ func TestAlgoTrader_GetRecentPrices(t *testing.T) {
 	}
 
 	// Test that prices are realistic
-	basePrice := exchange.stocks["1"].CurrentPrice
+	basePrice := exchange.stocks["1"].CurrentPrice.Float64()
 	for i, price := range prices {
 		if price <= 0 {
 			t.Errorf("Price at index %d should be positive, got %f", i, price)
@@ -312,8 +312,8 @@ func TestAlgoTrader_CalculateOrderQuantity(t *testing.T) {
 	}
 
 	// Should respect risk threshold
-	orderValue := float64(quantity) * stock.CurrentPrice
-	maxExpectedValue := trader.InitialMoney * trader.Config.RiskThreshold
+	orderValue := float64(quantity) * stock.CurrentPrice.Float64()
+	maxExpectedValue := trader.InitialMoney.Float64() * trader.Config.RiskThreshold
 
 	if orderValue > maxExpectedValue*1.1 { // Allow 10% tolerance
 		t.Errorf("Order value (%f) exceeds risk threshold (%f)", orderValue, maxExpectedValue)
@@ -391,7 +391,7 @@ func TestAlgoTrader_TradingIntegration(t *testing.T) {
 		if !exists {
 			t.Errorf("Expected trader %s to be registered in exchange", trader.Name)
 		} else {
-			t.Logf("Trader %s found in exchange with balance $%.2f", exchangeTrader.Name, exchangeTrader.Money)
+			t.Logf("Trader %s found in exchange with balance $%s", exchangeTrader.Name, exchangeTrader.Money)
 		}
 	}
 }
@@ -412,12 +412,12 @@ func TestAlgoTrader_PerformanceTracking(t *testing.T) {
 	}
 
 	if trader.ProfitLoss != 0 {
-		t.Errorf("Expected 0 initial profit/loss, got %f", trader.ProfitLoss)
+		t.Errorf("Expected 0 initial profit/loss, got %s", trader.ProfitLoss)
 	}
 
 	// Verify trader has reasonable initial money
 	if trader.InitialMoney <= 0 {
-		t.Errorf("Expected positive initial money, got %f", trader.InitialMoney)
+		t.Errorf("Expected positive initial money, got %s", trader.InitialMoney)
 	}
 
 	// Verify LastAction is initially zero
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"stock-exchange/internal/models"
+)
+
+// An auction collects orders on a stock's book without matching them and then
+// uncrosses the book in one go at a single clearing price. The clearing price
+// executes the most shares; ties go to the price leaving the smallest
+// imbalance between the two sides and then to the price closest to the
+// stock's reference price. The same auction opens the market, sets the
+// closing price and reopens a stock after a halt. While orders are being
+// collected the price and imbalance the book would uncross at right now are
+// published after every change to it.
+
+// AuctionKind is what an auction is run for
+type AuctionKind string
+
+const (
+	AuctionOpening   AuctionKind = "opening"
+	AuctionClosing   AuctionKind = "closing"
+	AuctionReopening AuctionKind = "reopening" // After a circuit breaker halt
+)
+
+// auctionKind gives the auction a stock in status is collecting orders for
+func auctionKind(status models.TradingStatus) (AuctionKind, bool) {
+	switch status {
+	case models.OpeningAuction:
+		return AuctionOpening, true
+	case models.ClosingAuction:
+		return AuctionClosing, true
+	case models.Halted:
+		return AuctionReopening, true
+	}
+	return "", false
+}
+
+// AuctionResult is the outcome of an auction uncross, or while orders are
+// still being collected, the indicative outcome if it uncrossed now
+type AuctionResult struct {
+	StockID       string       `json:"stockId"`
+	Kind          AuctionKind  `json:"kind"`
+	ClearingPrice models.Money `json:"clearingPrice"` // Zero if nothing could trade
+	Volume        int          `json:"volume"`
+	// Imbalance is the buy volume minus the sell volume left unmatched at
+	// the clearing price
+	Imbalance int `json:"imbalance"`
+}
+
+// clearingPrice finds the auction price for a book. Only the prices of
+// resting orders need to be tried, since executable volume only changes there.
+func clearingPrice(book *OrderBook, reference models.Money) (price models.Money, volume, imbalance int) {
+	candidates := make([]models.Money, 0, len(book.bids)+len(book.asks))
+	for _, level := range book.bids {
+		candidates = append(candidates, level.Price)
+	}
+	for _, level := range book.asks {
+		candidates = append(candidates, level.Price)
+	}
+
+	for _, candidate := range candidates {
+		buys, sells := 0, 0
+		for _, level := range book.bids {
+			if level.Price >= candidate {
+				buys += levelQuantity(level)
+			}
+		}
+		for _, level := range book.asks {
+			if level.Price <= candidate {
+				sells += levelQuantity(level)
+			}
+		}
+
+		executable := min(buys, sells)
+		if executable == 0 {
+			continue
+		}
+		if price == 0 || betterClearing(executable, buys-sells, candidate, volume, imbalance, price, reference) {
+			price, volume, imbalance = candidate, executable, buys-sells
+		}
+	}
+
+	return price, volume, imbalance
+}
+
+// betterClearing reports whether a candidate clearing price beats the best so far
+func betterClearing(volume, imbalance int, price models.Money, bestVolume, bestImbalance int, best, reference models.Money) bool {
+	if volume != bestVolume {
+		return volume > bestVolume
+	}
+	if abs(imbalance) != abs(bestImbalance) {
+		return abs(imbalance) < abs(bestImbalance)
+	}
+	if distance(price, reference) != distance(best, reference) {
+		return distance(price, reference) < distance(best, reference)
+	}
+	return price < best
+}
+
+func levelQuantity(level *PriceLevel) int {
+	quantity := 0
+	for _, order := range level.Orders {
+		quantity += order.Quantity
+	}
+	return quantity
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func distance(a, b models.Money) models.Money {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// GetIndicativeAuction returns the indicative price, volume and imbalance of
+// the auction a stock is collecting orders for
+func (e *Exchange) GetIndicativeAuction(stockID string) (AuctionResult, error) {
+	mu, exists := e.stockLocks[stockID]
+	if !exists {
+		return AuctionResult{}, fmt.Errorf("stock not found")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+
+	result, ok := e.indicativeAuction(stockID)
+	if !ok {
+		return AuctionResult{}, fmt.Errorf("%s is not in an auction", stockID)
+	}
+	return result, nil
+}
+
+// indicativeAuction works out what the stock's auction would uncross at if it
+// ran now. ok is false if the stock is not collecting orders for an auction.
+// The caller must hold the stock's lock.
+func (e *Exchange) indicativeAuction(stockID string) (result AuctionResult, ok bool) {
+	stock := e.stocks[stockID]
+	kind, ok := auctionKind(stock.GetStatus())
+	if !ok {
+		return AuctionResult{}, false
+	}
+
+	price, volume, imbalance := clearingPrice(e.books[stockID], stock.GetReferencePrice())
+	return AuctionResult{StockID: stockID, Kind: kind, ClearingPrice: price, Volume: volume, Imbalance: imbalance}, true
+}
+
+// publishIndicative broadcasts the indicative auction of a stock that is
+// collecting orders, after its book has changed. The caller must hold the
+// stock's lock.
+func (e *Exchange) publishIndicative(stockID string) {
+	if result, ok := e.indicativeAuction(stockID); ok {
+		e.broadcast(Update{Type: "auctionIndicative", Data: result})
+	}
+}
+
+// uncross trades every crossing order on a stock's book at the clearing price,
+// best price and then oldest first on each side. The stock's last and
+// reference prices move to the clearing price, and the result is broadcast.
+// The caller must hold the stock's lock.
+func (e *Exchange) uncross(stockID string, kind AuctionKind) AuctionResult {
+	book := e.books[stockID]
+	stock := e.stocks[stockID]
+
+	price, _, imbalance := clearingPrice(book, stock.GetReferencePrice())
+	result := AuctionResult{StockID: stockID, Kind: kind, Imbalance: imbalance}
+	if price == 0 {
+		e.broadcast(Update{Type: "auctionUncrossed", Data: result})
+		return result
+	}
+
+	for {
+		bid, ask := book.BestBid(), book.BestAsk()
+		if bid == nil || ask == nil || bid.Price < price || ask.Price > price {
+			break
+		}
+
+		if bid.TraderID == ask.TraderID {
+			// The later of the two orders applies its self-trade prevention
+			// mode, as if it had arrived into continuous trading. A survivor
+			// keeps its place in the queue.
+			incoming, resting := bid, ask
+			if ask.CreatedAt.After(bid.CreatedAt) {
+				incoming, resting = ask, bid
+			}
+			e.preventSelfTrade(incoming, resting)
+			if !incoming.IsActive() {
+				book.Remove(incoming.ID)
+			}
+			continue
+		}
+
+		quantity := min(bid.Quantity, ask.Quantity)
+		e.executeTrade(bid, ask, quantity, price, "")
+		result.Volume += quantity
+
+		// Iceberg orders take part with their hidden reserve too
+		for _, order := range []*models.Order{bid, ask} {
+			if order.Quantity == 0 {
+				book.Remove(order.ID)
+			} else {
+				e.replenish(order)
+			}
+		}
+	}
+
+	if result.Volume > 0 {
+		result.ClearingPrice = price
+		stock.SetPrice(price)
+		stock.SetReferencePrice(price)
+	}
+
+	log.Printf("Auction for %s (%s) uncrossed %d shares at %s (imbalance %d)", stockID, kind, result.Volume, price, imbalance)
+	e.broadcast(Update{Type: "auctionUncrossed", Data: result})
+	return result
+}
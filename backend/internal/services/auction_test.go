@@ -0,0 +1,197 @@
+package services
+
+import (
+	"stock-exchange/internal/models"
+	"testing"
+	"time"
+)
+
+// Test clearingPrice - maximum volume, then minimum imbalance, then reference price
+func TestClearingPrice(t *testing.T) {
+	tests := []struct {
+		name      string
+		bids      [][2]float64 // price, quantity
+		asks      [][2]float64
+		reference float64
+		price     float64
+		volume    int
+		imbalance int
+	}{
+		{
+			name:   "maximum volume",
+			bids:   [][2]float64{{102, 10}, {101, 20}, {100, 5}},
+			asks:   [][2]float64{{99, 10}, {100, 25}, {101, 40}},
+			price:  100,
+			volume: 35,
+		},
+		{
+			name:      "minimum imbalance",
+			bids:      [][2]float64{{101, 10}, {99, 5}},
+			asks:      [][2]float64{{99, 10}, {101, 2}},
+			price:     101,
+			volume:    10,
+			imbalance: -2,
+		},
+		{
+			name:      "reference price",
+			bids:      [][2]float64{{105, 10}},
+			asks:      [][2]float64{{100, 10}},
+			reference: 104,
+			price:     105,
+			volume:    10,
+		},
+		{
+			name: "no cross",
+			bids: [][2]float64{{99, 10}},
+			asks: [][2]float64{{100, 10}},
+		},
+	}
+
+	for _, tt := range tests {
+		book := NewOrderBook("1")
+		for i, bid := range tt.bids {
+			book.Add(createTestOrder(tt.name+"-bid"+string(rune('a'+i)), "trader1", "1", models.Buy, bid[0], int(bid[1])))
+		}
+		for i, ask := range tt.asks {
+			book.Add(createTestOrder(tt.name+"-ask"+string(rune('a'+i)), "trader2", "1", models.Sell, ask[0], int(ask[1])))
+		}
+
+		price, volume, imbalance := clearingPrice(book, money(tt.reference))
+		if price != money(tt.price) || volume != tt.volume || imbalance != tt.imbalance {
+			t.Errorf("%s: expected %d at %.2f (imbalance %d), got %d at %s (imbalance %d)",
+				tt.name, tt.volume, tt.price, tt.imbalance, volume, price, imbalance)
+		}
+	}
+}
+
+// Test uncross - crossing orders trade at one price and the rest stay on the book
+func TestUncross(t *testing.T) {
+	exchange := createTestExchange()
+	exchange.traders["trader2"].Holdings["1"] = 100
+	exchange.stocks["1"].Halt(time.Now().Add(time.Minute))
+
+	exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 152.0, 10))
+	exchange.PlaceOrder(createTestOrder("buy2", "trader1", "1", models.Buy, 150.0, 10))
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 148.0, 15))
+	exchange.PlaceOrder(createTestOrder("sell2", "trader2", "1", models.Sell, 155.0, 10))
+
+	if len(transactionLog(exchange)) != 0 {
+		t.Fatalf("Expected no trades while collecting orders, got %d", len(transactionLog(exchange)))
+	}
+
+	unlock := exchange.lockStock("1")
+	result := exchange.uncross("1", AuctionReopening)
+	unlock()
+	if result.ClearingPrice != money(150.0) || result.Volume != 15 {
+		t.Errorf("Expected 15 shares at 150.00, got %d at %s", result.Volume, result.ClearingPrice)
+	}
+	for _, transaction := range transactionLog(exchange) {
+		if transaction.Price != money(150.0) || transaction.AggressorSide != "" {
+			t.Errorf("Expected auction trades at 150.00 without an aggressor, got %s (%s)", transaction.Price, transaction.AggressorSide)
+		}
+	}
+
+	buy1, _ := exchange.GetOrder("buy1")
+	buy2, _ := exchange.GetOrder("buy2")
+	if buy1.Status != models.Filled || buy2.FilledQuantity != 5 {
+		t.Errorf("Expected buy1 filled and buy2 to fill 5, got %v and %d", buy1.Status, buy2.FilledQuantity)
+	}
+
+	// buy1 reserved 1520 but paid 1500, the difference is released
+	trader1 := exchange.traders["trader1"]
+	if trader1.ReservedCash != money(750.0) {
+		t.Errorf("Expected only buy2's remaining 5 shares to stay reserved, got %s", trader1.ReservedCash)
+	}
+	if trader1.Money != money(10000.0-15*150.0) {
+		t.Errorf("Expected trader1 to pay the clearing price, got %s", trader1.Money)
+	}
+
+	stock := exchange.stocks["1"]
+	if stock.GetPrice() != money(150.0) || stock.GetReferencePrice() != money(150.0) {
+		t.Errorf("Expected last and reference price at 150.00, got %s and %s", stock.GetPrice(), stock.GetReferencePrice())
+	}
+	if exchange.books["1"].Len() != 2 {
+		t.Errorf("Expected buy2 and sell2 left on the book, got %d orders", exchange.books["1"].Len())
+	}
+}
+
+// Test uncross - an order that survives self-trade prevention keeps its place in the queue
+func TestUncross_SelfTradeKeepsPriority(t *testing.T) {
+	exchange := createTestExchange()
+	exchange.traders["trader1"].Holdings["1"] = 100
+	trader3 := models.NewTrader("trader3", "Sam Lee", money(10000.0))
+	trader3.Holdings["1"] = 100
+	exchange.traders["trader3"] = trader3
+	exchange.stocks["1"].Halt(time.Now().Add(time.Minute))
+
+	exchange.PlaceOrder(createTestOrder("sell1", "trader1", "1", models.Sell, 150.0, 4))
+	buy1 := createTestOrder("buy1", "trader1", "1", models.Buy, 150.0, 10)
+	buy1.SelfTradePrevention = models.DecrementAndCancel
+	exchange.PlaceOrder(buy1)
+	exchange.PlaceOrder(createTestOrder("buy2", "trader2", "1", models.Buy, 150.0, 10))
+	exchange.PlaceOrder(createTestOrder("sell2", "trader3", "1", models.Sell, 150.0, 6))
+
+	unlock := exchange.lockStock("1")
+	result := exchange.uncross("1", AuctionReopening)
+	unlock()
+	if result.Volume != 6 {
+		t.Errorf("Expected 6 shares to trade, got %d", result.Volume)
+	}
+
+	if sell1, _ := exchange.GetOrder("sell1"); sell1.Status != models.Cancelled {
+		t.Errorf("Expected sell1 to be cancelled, got %v", sell1.Status)
+	}
+	// buy1 was ahead of buy2 at 150.00 and stays there after decrementing
+	filled1, _ := exchange.GetOrder("buy1")
+	filled2, _ := exchange.GetOrder("buy2")
+	if filled1.FilledQuantity != 6 || filled2.FilledQuantity != 0 {
+		t.Errorf("Expected buy1 to fill 6 ahead of buy2, got %d and %d", filled1.FilledQuantity, filled2.FilledQuantity)
+	}
+}
+
+// Test indicative auctions - published after every change while collecting orders
+func TestIndicativeAuction(t *testing.T) {
+	exchange := createTestExchange()
+	exchange.traders["trader2"].Holdings["1"] = 100
+
+	if _, err := exchange.GetIndicativeAuction("1"); err == nil {
+		t.Error("Expected no indicative auction while trading continuously")
+	}
+	if _, err := exchange.GetIndicativeAuction("999"); err == nil {
+		t.Error("Expected an error for an unknown stock")
+	}
+
+	exchange.stocks["1"].SetStatus(models.OpeningAuction)
+	sub := exchange.Subscribe()
+	defer exchange.Unsubscribe(sub)
+
+	exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 152.0, 10))
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 149.0, 4))
+
+	result, err := exchange.GetIndicativeAuction("1")
+	if err != nil {
+		t.Fatalf("Failed to get the indicative auction: %v", err)
+	}
+	// Both prices execute 4 shares leaving 6 unmatched; 149 is closer to 150
+	if result.Kind != AuctionOpening || result.ClearingPrice != money(149.0) || result.Volume != 4 || result.Imbalance != 6 {
+		t.Errorf("Expected 4 shares at 149.00 with 6 unmatched in the opening auction, got %+v", result)
+	}
+
+	exchange.CancelOrder("sell1")
+
+	var published []AuctionResult
+	for len(sub.GetChannel()) > 0 {
+		if update := <-sub.GetChannel(); update.Type == "auctionIndicative" {
+			published = append(published, update.Data.(AuctionResult))
+		}
+	}
+	if len(published) != 3 {
+		t.Fatalf("Expected an indicative update per order and cancellation, got %d", len(published))
+	}
+	if last := published[2]; last.ClearingPrice != 0 || last.Volume != 0 || last.Imbalance != 0 {
+		t.Errorf("Expected nothing to uncross after the cancellation, got %+v", last)
+	}
+	if len(transactionLog(exchange)) != 0 {
+		t.Errorf("Expected no trades while collecting orders, got %d", len(transactionLog(exchange)))
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"stock-exchange/internal/models"
+	"time"
+)
+
+// Stocks with a price band only trade within it. Limit orders priced outside
+// the band are rejected, and a trade that would print outside it halts the
+// stock instead. While halted, orders that can rest are collected on the book
+// without matching, and when the cool-off is over a reopening auction
+// uncrosses them and recentres the band on the auction price.
+
+// HaltEvent is broadcast when a stock is halted
+type HaltEvent struct {
+	StockID        string       `json:"stockId"`
+	Price          models.Money `json:"price"` // The trade price that breached the band
+	ReferencePrice models.Money `json:"referencePrice"`
+	LowerBand      models.Money `json:"lowerBand"`
+	UpperBand      models.Money `json:"upperBand"`
+	HaltedUntil    time.Time    `json:"haltedUntil"`
+}
+
+// ResumeEvent is broadcast when a halted stock reopens
+type ResumeEvent struct {
+	AuctionResult
+	ResumedAt time.Time `json:"resumedAt"`
+}
+
+// checkPriceBand rejects a limit price outside the stock's price band. Stocks
+// collecting orders for an auction accept any price, so the auction can find
+// a new level.
+func checkPriceBand(stock *models.Stock, price models.Money) error {
+	if !stock.IsTrading() || stock.InPriceBand(price) {
+		return nil
+	}
+	lower, upper := stock.PriceBandLimits()
+	return fmt.Errorf("price %s is outside the price band %s - %s", price, lower, upper)
+}
+
+// halt stops continuous trading in a stock after a trade at price would have
+// breached its band, and schedules the reopening. The caller must hold the
+// stock's lock.
+func (e *Exchange) halt(stockID string, price models.Money) {
+	stock := e.stocks[stockID]
+	duration := stock.HaltDuration()
+	until := time.Now().Add(duration)
+	stock.Halt(until)
+
+	lower, upper := stock.PriceBandLimits()
+	log.Printf("Trading in %s halted until %s: trade at %s outside the price band %s - %s",
+		stockID, until.Format(time.RFC3339), price, lower, upper)
+	e.broadcast(Update{Type: "tradingHalted", Data: HaltEvent{
+		StockID:        stockID,
+		Price:          price,
+		ReferencePrice: stock.GetReferencePrice(),
+		LowerBand:      lower,
+		UpperBand:      upper,
+		HaltedUntil:    until,
+	}})
+	e.publishIndicative(stockID)
+
+	time.AfterFunc(duration, func() {
+		e.resume(stockID, time.Now())
+	})
+}
+
+// resume reopens a halted stock whose halt is over with an auction, then
+// returns it to continuous trading. It does nothing if the stock is not
+// halted or was halted again since.
+func (e *Exchange) resume(stockID string, now time.Time) {
+	defer e.lockStock(stockID)()
+
+	stock := e.stocks[stockID]
+	if !stock.HaltOver(now) {
+		return
+	}
+
+	result := e.uncross(stockID, AuctionReopening)
+	stock.SetStatus(models.Trading)
+	log.Printf("Trading in %s resumed", stockID)
+	e.broadcast(Update{Type: "tradingResumed", Data: ResumeEvent{AuctionResult: result, ResumedAt: now}})
+
+	// The auction price may have reached stop orders
+	e.processTriggers(stockID)
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"stock-exchange/internal/models"
+	"testing"
+	"time"
+)
+
+// createBandedExchange gives stock 1 a ±10% price band around 150
+func createBandedExchange() *Exchange {
+	exchange := createTestExchange()
+	exchange.stocks["1"].PriceBand = 0.10
+	exchange.traders["trader2"].Holdings["1"] = 100
+	return exchange
+}
+
+// Test price bands - limit orders outside the band are rejected
+func TestPriceBand_RejectsOrders(t *testing.T) {
+	exchange := createBandedExchange()
+
+	if err := exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 165.0, 1)); err != nil {
+		t.Errorf("Expected an order at the upper band to be accepted: %v", err)
+	}
+	if err := exchange.PlaceOrder(createTestOrder("buy2", "trader1", "1", models.Buy, 165.05, 1)); err == nil {
+		t.Error("Expected an order above the band to be rejected")
+	}
+	if err := exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 134.95, 1)); err == nil {
+		t.Error("Expected an order below the band to be rejected")
+	}
+	if _, err := exchange.AmendOrder("buy1", money(170.0), 0); err == nil {
+		t.Error("Expected amending outside the band to be rejected")
+	}
+
+	// The price updater stops at the band and leaves it where it is
+	exchange.UpdatePrice("1", money(170.0))
+	if price := exchange.stocks["1"].GetPrice(); price != money(165.0) {
+		t.Errorf("Expected the price to stop at the upper band at 165.00, got %s", price)
+	}
+	exchange.UpdatePrice("1", money(120.0))
+	if price := exchange.stocks["1"].GetPrice(); price != money(135.0) {
+		t.Errorf("Expected the price to stop at the lower band at 135.00, got %s", price)
+	}
+	if err := exchange.PlaceOrder(createTestOrder("buy3", "trader1", "1", models.Buy, 165.05, 1)); err == nil {
+		t.Error("Expected the band to stay centred on the reference price")
+	}
+}
+
+// Test circuit breaker - a trade outside the band halts the stock instead
+func TestCircuitBreaker_HaltAndReopen(t *testing.T) {
+	exchange := createBandedExchange()
+	stock := exchange.stocks["1"]
+	sub := exchange.Subscribe()
+	defer exchange.Unsubscribe(sub)
+
+	// A resting ask left far away once an auction moves the band down
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 150.0, 5))
+	exchange.PlaceOrder(createTestOrder("sell2", "trader2", "1", models.Sell, 160.0, 5))
+	stock.SetReferencePrice(money(140.0))
+
+	// The market buy takes sell1 at 150, within 126-154, then halts at 160
+	buy := createTestOrder("buy1", "trader1", "1", models.Buy, 0, 10)
+	buy.Kind = models.Market
+	if err := exchange.PlaceOrder(buy); err != nil {
+		t.Fatalf("Failed to place market order: %v", err)
+	}
+
+	if buy.FilledQuantity != 5 || buy.Status != models.Cancelled {
+		t.Errorf("Expected 5 shares filled and the rest cancelled, got %d filled (%v)", buy.FilledQuantity, buy.Status)
+	}
+	if !stock.IsHalted() {
+		t.Fatal("Expected the stock to be halted")
+	}
+	if stock.GetPrice() != money(150.0) {
+		t.Errorf("Expected the last price to stay at 150.00, got %s", stock.GetPrice())
+	}
+
+	halted := false
+	for len(sub.GetChannel()) > 0 {
+		if update := <-sub.GetChannel(); update.Type == "tradingHalted" {
+			event := update.Data.(HaltEvent)
+			halted = event.StockID == "1" && event.Price == money(160.0)
+		}
+	}
+	if !halted {
+		t.Error("Expected a tradingHalted update for the 160.00 trade")
+	}
+
+	// While halted only orders that can rest are taken, at any price
+	ioc := createTestOrder("ioc1", "trader1", "1", models.Buy, 160.0, 1)
+	ioc.TimeInForce = models.IOC
+	if err := exchange.PlaceOrder(ioc); err == nil {
+		t.Error("Expected an IOC order to be rejected while halted")
+	}
+	if err := exchange.PlaceOrder(createTestOrder("buy2", "trader1", "1", models.Buy, 162.0, 3)); err != nil {
+		t.Fatalf("Expected a limit order to be collected while halted: %v", err)
+	}
+	exchange.UpdatePrice("1", money(100.0))
+	if len(transactionLog(exchange)) != 1 || stock.GetPrice() != money(150.0) {
+		t.Error("Expected no trading or price updates while halted")
+	}
+
+	// Nothing happens before the halt is over
+	exchange.resume("1", time.Now())
+	if !stock.IsHalted() {
+		t.Fatal("Expected the stock to stay halted during the cool-off")
+	}
+
+	exchange.resume("1", *stock.HaltedUntil)
+	if stock.IsHalted() {
+		t.Fatal("Expected the stock to reopen after the cool-off")
+	}
+	if buy2, _ := exchange.GetOrder("buy2"); buy2.Status != models.Filled || buy2.AvgFillPrice != money(160.0) {
+		t.Errorf("Expected buy2 to fill in the reopening auction at 160.00, got %v at %s", buy2.Status, buy2.AvgFillPrice)
+	}
+	if stock.GetReferencePrice() != money(160.0) {
+		t.Errorf("Expected the band to be recentred on 160.00, got %s", stock.GetReferencePrice())
+	}
+
+	resumed := false
+	for len(sub.GetChannel()) > 0 {
+		if update := <-sub.GetChannel(); update.Type == "tradingResumed" {
+			resumed = update.Data.(ResumeEvent).Volume == 3
+		}
+	}
+	if !resumed {
+		t.Error("Expected a tradingResumed update with the auction volume")
+	}
+}
+
+// Test circuit breaker - fill-or-kill orders only count liquidity inside the band
+func TestCircuitBreaker_FillOrKill(t *testing.T) {
+	exchange := createBandedExchange()
+
+	// Both asks cross the FOK order, but 150 is now below the 153-187 band
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 150.0, 5))
+	exchange.PlaceOrder(createTestOrder("sell2", "trader2", "1", models.Sell, 160.0, 5))
+	exchange.stocks["1"].SetReferencePrice(money(170.0))
+
+	fok := createTestOrder("fok1", "trader1", "1", models.Buy, 170.0, 10)
+	fok.TimeInForce = models.FOK
+	exchange.PlaceOrder(fok)
+
+	if fok.Status != models.Cancelled || fok.FilledQuantity != 0 {
+		t.Errorf("Expected the FOK order to be killed untouched, got %v with %d filled", fok.Status, fok.FilledQuantity)
+	}
+	if exchange.stocks["1"].IsHalted() {
+		t.Error("Expected no halt when nothing traded")
+	}
+}
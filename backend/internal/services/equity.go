@@ -0,0 +1,196 @@
+package services
+
+import (
+	"sort"
+	"stock-exchange/internal/models"
+	"sync"
+	"time"
+)
+
+// EquityRetention is how long a trader's valuations are kept
+const EquityRetention = 90 * 24 * time.Hour
+
+// equityResolution thins out older valuations, keeping the last one in each
+// step once they are older than age, so a curve held for EquityRetention
+// stays small. Coarser steps come later.
+var equityResolution = []struct {
+	age  time.Duration
+	step time.Duration
+}{
+	{24 * time.Hour, time.Hour},
+	{7 * 24 * time.Hour, 24 * time.Hour},
+}
+
+// EquityCurve keeps each trader's valuations over time: cash, holdings marked
+// to the last prices and profit or loss. A valuation is recorded whenever a
+// trader's fills are committed and for every trader at the Valuator's cadence,
+// and recovery rebuilds both from the journal.
+type EquityCurve struct {
+	points map[string][]models.PerformanceData // By trader, oldest first
+	mu     sync.RWMutex
+}
+
+func NewEquityCurve() *EquityCurve {
+	return &EquityCurve{points: make(map[string][]models.PerformanceData)}
+}
+
+// Record adds a valuation to a trader's curve, drops those past
+// EquityRetention and thins out older ones to equityResolution as each hour
+// passes. Operations on different stocks can commit in a different order
+// from the one their fills were executed in, so a valuation may go a little
+// before the end.
+func (c *EquityCurve) Record(traderID string, point models.PerformanceData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	points := c.points[traderID]
+	if last := len(points) - 1; last >= 0 && point.Date.Truncate(time.Hour).After(points[last].Date) {
+		points = downsample(points, point.Date)
+	}
+	i := len(points)
+	for i > 0 && points[i-1].Date.After(point.Date) {
+		i--
+	}
+	points = append(points, models.PerformanceData{})
+	copy(points[i+1:], points[i:])
+	points[i] = point
+
+	cutoff := points[len(points)-1].Date.Add(-EquityRetention)
+	expired := sort.Search(len(points), func(i int) bool { return points[i].Date.After(cutoff) })
+	if expired > 0 {
+		points = append(points[:0:0], points[expired:]...)
+	}
+	c.points[traderID] = points
+}
+
+// downsample keeps only the last of the valuations in each step of
+// equityResolution that they are old enough for, as of now
+func downsample(points []models.PerformanceData, now time.Time) []models.PerformanceData {
+	step := func(point models.PerformanceData) time.Duration {
+		age := now.Sub(point.Date)
+		for i := len(equityResolution) - 1; i >= 0; i-- {
+			if age >= equityResolution[i].age {
+				return equityResolution[i].step
+			}
+		}
+		return 0
+	}
+
+	kept := points[:0:0]
+	for i, point := range points {
+		current := step(point)
+		if current > 0 && i+1 < len(points) {
+			next := points[i+1]
+			if step(next) == current && next.Date.UTC().Truncate(current).Equal(point.Date.UTC().Truncate(current)) {
+				continue
+			}
+		}
+		kept = append(kept, point)
+	}
+	return kept
+}
+
+// Since returns a trader's valuations from a time on, oldest first
+func (c *EquityCurve) Since(traderID string, from time.Time) []models.PerformanceData {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	points := c.points[traderID]
+	first := sort.Search(len(points), func(i int) bool { return !points[i].Date.Before(from) })
+	return append(make([]models.PerformanceData, 0, len(points)-first), points[first:]...)
+}
+
+// dump copies every trader's curve, for a snapshot
+func (c *EquityCurve) dump() map[string][]models.PerformanceData {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	dump := make(map[string][]models.PerformanceData, len(c.points))
+	for traderID, points := range c.points {
+		dump[traderID] = append([]models.PerformanceData(nil), points...)
+	}
+	return dump
+}
+
+// load replaces the curves with ones from a snapshot
+func (c *EquityCurve) load(points map[string][]models.PerformanceData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.points = make(map[string][]models.PerformanceData, len(points))
+	for traderID, curve := range points {
+		c.points[traderID] = curve
+	}
+}
+
+// valuation values a trader's account at the last prices. The caller must
+// hold the trader's account lock.
+func (e *Exchange) valuation(trader *models.Trader, at time.Time) models.PerformanceData {
+	holdingsValue := models.Money(0)
+	for stockID, quantity := range trader.Holdings {
+		if stock, exists := e.stocks[stockID]; exists {
+			holdingsValue += stock.GetPrice().Mul(quantity)
+		}
+	}
+	return models.PerformanceData{
+		Date:           at,
+		PortfolioValue: trader.Money + holdingsValue,
+		ProfitLoss:     trader.Money + holdingsValue - trader.InitialMoney,
+		CashBalance:    trader.Money,
+		HoldingsValue:  holdingsValue,
+	}
+}
+
+// recordValuations values the traders on either side of an operation's
+// fills, as of the last of them. Recovery replays journal entries through
+// here too. The caller must hold the stock's lock but no account lock.
+func (e *Exchange) recordValuations(transactions []models.Transaction) {
+	if len(transactions) == 0 {
+		return
+	}
+	at := transactions[len(transactions)-1].ExecutedAt
+	valued := make(map[string]bool)
+	for _, transaction := range transactions {
+		for _, traderID := range []string{transaction.BuyerID, transaction.SellerID} {
+			if trader, exists := e.trader(traderID); exists && !valued[traderID] {
+				valued[traderID] = true
+				account := e.accountLock(traderID)
+				account.RLock()
+				valuation := e.valuation(trader, at)
+				account.RUnlock()
+				e.equity.Record(traderID, valuation)
+			}
+		}
+	}
+}
+
+// RecordValuations values every trader now and journals that it did, so
+// recovery values them again at the same point. It holds every stock's lock,
+// so the valuations only include operations that have been journaled.
+func (e *Exchange) RecordValuations() {
+	_, unlock := e.lockAllStocks()
+	defer unlock()
+
+	now := time.Now()
+	e.journalEvent(Event{Type: EventValuation, ValuedAt: &now})
+	e.valueTraders(now)
+}
+
+// valueTraders values every trader at the last prices. Recovery replays
+// journaled valuations through here too. The caller must hold every stock's
+// lock but no account lock.
+func (e *Exchange) valueTraders(at time.Time) {
+	for _, trader := range e.allTraders() {
+		account := e.accountLock(trader.ID)
+		account.RLock()
+		valuation := e.valuation(trader, at)
+		account.RUnlock()
+		e.equity.Record(trader.ID, valuation)
+	}
+}
+
+// GetTraderPerformance returns a trader's recorded valuations over the last
+// days, oldest first
+func (e *Exchange) GetTraderPerformance(traderID string, days int) []models.PerformanceData {
+	return e.equity.Since(traderID, time.Now().AddDate(0, 0, -days))
+}
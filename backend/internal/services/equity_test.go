@@ -0,0 +1,126 @@
+package services
+
+import (
+	"stock-exchange/internal/models"
+	"testing"
+	"time"
+)
+
+// Test equity curve - fills and the valuator's cadence record valuations at the last prices
+func TestGetTraderPerformance(t *testing.T) {
+	exchange := createTestExchange()
+	exchange.traders["trader2"].Holdings["1"] = 100
+
+	exchange.RecordValuations()
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 149.0, 10))
+	exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 149.0, 4))
+	exchange.UpdatePrice("1", money(160.0))
+	exchange.RecordValuations()
+
+	curve := exchange.GetTraderPerformance("trader1", 1)
+	if len(curve) != 3 {
+		t.Fatalf("Expected a valuation before, on the fill and after, got %d", len(curve))
+	}
+
+	if curve[0].PortfolioValue != money(10000.0) || curve[0].ProfitLoss != 0 {
+		t.Errorf("Expected to start at 10000.00 with no P&L, got %s (%s)", curve[0].PortfolioValue, curve[0].ProfitLoss)
+	}
+
+	fill := curve[1]
+	if fill.CashBalance != money(10000.0-4*149.0) || fill.HoldingsValue != money(4*149.0) || fill.ProfitLoss != 0 {
+		t.Errorf("Expected the fill valued at its price, got cash %s, holdings %s, P&L %s", fill.CashBalance, fill.HoldingsValue, fill.ProfitLoss)
+	}
+
+	// Holdings are marked to the price the stock moved to
+	if last := curve[2]; last.HoldingsValue != money(4*160.0) || last.ProfitLoss != money(4*11.0) {
+		t.Errorf("Expected holdings at 160.00 and 44.00 P&L, got %s and %s", last.HoldingsValue, last.ProfitLoss)
+	}
+
+	if seller := exchange.GetTraderPerformance("trader2", 1); len(seller) != 3 {
+		t.Errorf("Expected the seller to be valued on the fill too, got %d valuations", len(seller))
+	}
+}
+
+// Test equity curve - valuations are kept in time order for the retention period
+func TestEquityCurve_Record(t *testing.T) {
+	curve := NewEquityCurve()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	curve.Record("trader1", models.PerformanceData{Date: start.Add(time.Minute), PortfolioValue: money(2.0)})
+	curve.Record("trader1", models.PerformanceData{Date: start, PortfolioValue: money(1.0)})
+
+	points := curve.Since("trader1", start)
+	if len(points) != 2 || points[0].PortfolioValue != money(1.0) {
+		t.Errorf("Expected a late valuation to go before the later one, got %v", points)
+	}
+	if points := curve.Since("trader1", start.Add(time.Second)); len(points) != 1 {
+		t.Errorf("Expected only the valuation after from, got %d", len(points))
+	}
+
+	curve.Record("trader1", models.PerformanceData{Date: start.Add(EquityRetention + time.Hour), PortfolioValue: money(3.0)})
+	if points := curve.Since("trader1", start); len(points) != 1 {
+		t.Errorf("Expected valuations past the retention to be dropped, got %d", len(points))
+	}
+}
+
+// Test trader activity - counted from the trader's own recorded trades
+func TestGetTraderActivity(t *testing.T) {
+	exchange := createTestExchange()
+	exchange.traders["trader2"].Holdings["1"] = 100
+
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 149.0, 10))
+	exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 149.0, 4))
+	exchange.PlaceOrder(createTestOrder("buy2", "trader1", "1", models.Buy, 149.0, 2))
+
+	activity := exchange.GetTraderActivity("trader2", 6)
+	if len(activity) != 6 || activity[5].Period != time.Now().Month().String()[:3] {
+		t.Fatalf("Expected 6 months ending with this one, got %v", activity)
+	}
+	month := activity[5]
+	if month.SellOrders != 1 || month.BuyOrders != 0 || month.Volume != 6 || month.Value != money(6*149.0) {
+		t.Errorf("Expected one sell order trading 6 shares for 894.00, got %+v", month)
+	}
+	if buyer := exchange.GetTraderActivity("trader1", 6)[5]; buyer.BuyOrders != 2 || buyer.SellOrders != 0 {
+		t.Errorf("Expected two buy orders, got %+v", buyer)
+	}
+	for _, earlier := range activity[:5] {
+		if earlier.BuyOrders != 0 || earlier.SellOrders != 0 || earlier.Volume != 0 {
+			t.Errorf("Expected no activity before this month, got %+v", earlier)
+		}
+	}
+}
+
+// Test equity curve - older valuations are thinned out to hourly, then daily
+func TestEquityCurve_Downsample(t *testing.T) {
+	curve := NewEquityCurve()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	record := func(after time.Duration) {
+		curve.Record("trader1", models.PerformanceData{Date: start.Add(after)})
+	}
+	dates := func() []time.Duration {
+		offsets := make([]time.Duration, 0)
+		for _, point := range curve.Since("trader1", start) {
+			offsets = append(offsets, point.Date.Sub(start))
+		}
+		return offsets
+	}
+
+	for _, after := range []time.Duration{0, 30 * time.Minute, 50 * time.Minute, 70 * time.Minute} {
+		record(after)
+	}
+	if offsets := dates(); len(offsets) != 4 {
+		t.Fatalf("Expected recent valuations to be kept, got %v", offsets)
+	}
+
+	// A day on, the first hour keeps its last valuation
+	record(48 * time.Hour)
+	if offsets := dates(); len(offsets) != 3 || offsets[0] != 50*time.Minute || offsets[1] != 70*time.Minute {
+		t.Errorf("Expected one valuation per hour after a day, got %v", offsets)
+	}
+
+	// A week on, the first day keeps its last valuation
+	record(10 * 24 * time.Hour)
+	if offsets := dates(); len(offsets) != 3 || offsets[0] != 70*time.Minute || offsets[1] != 48*time.Hour {
+		t.Errorf("Expected one valuation per day after a week, got %v", offsets)
+	}
+}
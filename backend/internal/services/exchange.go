@@ -4,10 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"math/rand"
 	"os"
 	"sort"
 	"stock-exchange/internal/models"
+	"stock-exchange/internal/storage"
 	"strconv"
 	"sync"
 	"time"
@@ -18,48 +18,146 @@ type Subscription struct {
 	done chan struct{}
 }
 
+// Exchange matches each stock independently. Every listed stock has its own
+// lock guarding its order book, trigger book, last price and the orders on
+// them, so orders for different stocks match in parallel. State shared by
+// all stocks sits behind small leaf locks: traders' money, holdings and
+// reservations behind account locks spread over the traders (accountLock),
+// the index of open orders behind its own shard locks, txMu for transaction
+// numbering, subMu for subscriptions and sessionMu for the market phase. A
+// stock lock is always taken before any leaf lock, and no leaf lock is held
+// while taking another, except tradersMu, which only guards who the traders
+// are and may be taken under an account lock. Operations that change a stock
+// journal what they changed and queue it for the store as they release its
+// lock, and save it once the lock is released; the journal's and the store
+// writer's own locks are innermost and may be taken under any other. Taking
+// a snapshot holds every stock lock, in order of stock ID.
 type Exchange struct {
-	stocks        map[string]*models.Stock
-	traders       map[string]*models.Trader
-	buyOrders     map[string][]*models.Order
-	sellOrders    map[string][]*models.Order
-	transactions  []models.Transaction
+	// The listed stocks are fixed once the exchange is loaded, so these maps
+	// are only read after startup
+	stocks     map[string]*models.Stock
+	books      map[string]*OrderBook
+	stopBooks  map[string]*StopBook
+	exits      map[string]*exitQueue
+	changes    map[string]*changeSet
+	stockLocks map[string]*sync.Mutex
+
+	traders      map[string]*models.Trader
+	tradersMu    sync.RWMutex
+	accountLocks [shards]sync.RWMutex
+
+	// Orders stay here while they are open or pending; the store has every
+	// order as of the last operation that changed it
+	orders *orderIndex
+
+	lastTxID int64
+	txMu     sync.Mutex
+
+	store  storage.Store // Order history and transactions, and a copy of the rest
+	writer *storeWriter  // Saves operations' changes to the store
+	ticks  *TickStore    // Price history
+	equity *EquityCurve  // Traders' valuations over time
+
 	subscriptions map[*Subscription]bool
-	mu            sync.RWMutex
+	subMu         sync.RWMutex
+
+	calendar  *MarketCalendar // Nil keeps the market open around the clock
+	phase     models.MarketPhase
+	sessionMu sync.RWMutex
+
+	journal   *Journal       // Nil keeps the exchange in memory only
+	snapshots *SnapshotStore // Nil takes no snapshots
 }
 
+// DefaultSessionClose is the time of day (local time) DAY orders expire at
+// when there is no market calendar
+const DefaultSessionClose = 16 * time.Hour
+
+// NewExchange returns an exchange that keeps its records in memory
 func NewExchange() *Exchange {
+	return NewExchangeWithStore(storage.NewMemoryStore())
+}
+
+// NewExchangeWithStore returns an exchange that keeps its records in store
+func NewExchangeWithStore(store storage.Store) *Exchange {
 	return &Exchange{
 		stocks:        make(map[string]*models.Stock),
 		traders:       make(map[string]*models.Trader),
-		buyOrders:     make(map[string][]*models.Order),
-		sellOrders:    make(map[string][]*models.Order),
-		transactions:  make([]models.Transaction, 0),
+		orders:        newOrderIndex(),
+		books:         make(map[string]*OrderBook),
+		stopBooks:     make(map[string]*StopBook),
+		exits:         make(map[string]*exitQueue),
+		changes:       make(map[string]*changeSet),
+		stockLocks:    make(map[string]*sync.Mutex),
 		subscriptions: make(map[*Subscription]bool),
+		phase:         models.PhaseOpen,
+		store:         store,
+		writer:        newStoreWriter(store),
+		ticks:         NewTickStore(),
+		equity:        NewEquityCurve(),
 	}
 }
 
-func (e *Exchange) LoadConfig(filename string) error {
-	data, err := os.ReadFile(filename)
+// exchangeConfig is the layout of config.json
+type exchangeConfig struct {
+	Shares  []models.Stock  `json:"shares"` // Changed from "stocks"
+	Traders []models.Trader `json:"traders"`
+	Market  *CalendarConfig `json:"market"`
+	Storage storage.Config  `json:"storage"`
+}
+
+// ReadStorageConfig reads which store config.json selects
+func ReadStorageConfig(filename string) (storage.Config, error) {
+	config, err := readConfig(filename)
 	if err != nil {
-		return err
+		return storage.Config{}, err
 	}
+	return config.Storage, nil
+}
 
-	var config struct {
-		Shares  []models.Stock  `json:"shares"` // Changed from "stocks"
-		Traders []models.Trader `json:"traders"`
+func readConfig(filename string) (*exchangeConfig, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
 	}
 
+	var config exchangeConfig
 	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// setCalendar sets up the market calendar, if the config has one
+func (e *Exchange) setCalendar(market *CalendarConfig) error {
+	if market == nil {
+		return nil
+	}
+	calendar, err := NewMarketCalendar(*market)
+	if err != nil {
+		return err
+	}
+	e.calendar = calendar
+	return nil
+}
+
+func (e *Exchange) LoadConfig(filename string) error {
+	config, err := readConfig(filename)
+	if err != nil {
+		return err
+	}
+	if err := e.setCalendar(config.Market); err != nil {
 		return err
 	}
 
 	// Load stocks
 	for i := range config.Shares {
 		stock := &config.Shares[i] // Get pointer to original struct
-		e.stocks[stock.ID] = stock
-		e.buyOrders[stock.ID] = make([]*models.Order, 0)
-		e.sellOrders[stock.ID] = make([]*models.Order, 0)
+		if err := stock.ValidateRules(); err != nil {
+			return err
+		}
+		e.addStock(stock)
+		e.recordListing(stock)
 
 		// Create initial sell orders from exchange
 		initialOrder := &models.Order{
@@ -71,197 +169,829 @@ func (e *Exchange) LoadConfig(filename string) error {
 			Quantity:  stock.Amount,
 			Status:    models.Open,
 			CreatedAt: time.Now(),
+
+			DisplayQuantity: stock.SupplyPeak,
+		}
+		unlock := e.lockStock(stock.ID)
+		err := e.accept(initialOrder)
+		if err == nil {
+			e.rest(initialOrder)
+		}
+		unlock()
+		if err != nil {
+			return err
 		}
-		e.sellOrders[stock.ID] = append(e.sellOrders[stock.ID], initialOrder)
 	}
 
 	// Load traders
 	for i := range config.Traders {
 		trader := &config.Traders[i] // Get pointer to original struct
 		t := models.NewTrader(trader.ID, trader.Name, trader.Money)
+		if trader.SelfTradePrevention != "" && !trader.SelfTradePrevention.IsValid() {
+			return fmt.Errorf("trader %s: invalid self-trade prevention mode: %s", trader.ID, trader.SelfTradePrevention)
+		}
+		t.SelfTradePrevention = trader.SelfTradePrevention
+
+		e.tradersMu.Lock()
 		e.traders[t.ID] = t
+		e.recordTrader(EventTraderRegistered, t)
+		e.tradersMu.Unlock()
 	}
+	e.writer.write()
+
+	// Start in whatever phase the market is in right now
+	e.AdvanceSession(time.Now())
 
 	log.Println("Exchange loaded successfully")
 	return nil
 }
 
-func (e *Exchange) PlaceOrder(order *models.Order) error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+// addStock lists a stock on the exchange with empty books. Stocks can only
+// be added while the exchange is being set up.
+func (e *Exchange) addStock(stock *models.Stock) {
+	if stock.ReferencePrice == 0 {
+		stock.ReferencePrice = stock.CurrentPrice
+	}
+	if stock.Status == "" {
+		stock.Status = models.Trading
+	}
+	e.stocks[stock.ID] = stock
+	e.books[stock.ID] = NewOrderBook(stock.ID)
+	e.stopBooks[stock.ID] = NewStopBook(stock.ID)
+	e.exits[stock.ID] = &exitQueue{}
+	e.changes[stock.ID] = newChangeSet()
+	e.changes[stock.ID].stock = stock.State()
+	e.stockLocks[stock.ID] = &sync.Mutex{}
+}
+
+// trader looks a trader up. Their account is read and changed under their
+// account lock.
+func (e *Exchange) trader(traderID string) (*models.Trader, bool) {
+	e.tradersMu.RLock()
+	defer e.tradersMu.RUnlock()
+	trader, exists := e.traders[traderID]
+	return trader, exists
+}
+
+// allTraders lists the traders. Their accounts are read and changed under
+// their account locks, which must not be taken while listing them.
+func (e *Exchange) allTraders() []*models.Trader {
+	e.tradersMu.RLock()
+	defer e.tradersMu.RUnlock()
+	traders := make([]*models.Trader, 0, len(e.traders))
+	for _, trader := range e.traders {
+		traders = append(traders, trader)
+	}
+	return traders
+}
+
+// accountLock returns the lock guarding a trader's money, holdings and
+// reservations
+func (e *Exchange) accountLock(traderID string) *sync.RWMutex {
+	return &e.accountLocks[shardOf(traderID)]
+}
+
+// forget drops an order that is done from the open orders. The store keeps it.
+func (e *Exchange) forget(order *models.Order) {
+	e.orders.remove(order)
+}
+
+// lockOrder looks an open order up and takes the lock of its stock. The
+// caller must call unlock when order is not nil.
+func (e *Exchange) lockOrder(orderID string) (order *models.Order, unlock func()) {
+	order, exists := e.orders.get(orderID)
+	if !exists {
+		return nil, nil
+	}
+
+	return order, e.lockStock(order.StockID)
+}
 
+// snapshot copies an order under its stock's lock
+func (e *Exchange) snapshot(order *models.Order) models.Order {
+	mu := e.stockLocks[order.StockID]
+	mu.Lock()
+	defer mu.Unlock()
+	return *order
+}
+
+func (e *Exchange) PlaceOrder(order *models.Order) error {
 	// Validate order
 	if err := e.validateOrder(order); err != nil {
 		return err
 	}
 
-	// Add order to appropriate book
-	if order.Type == models.Buy {
-		e.buyOrders[order.StockID] = append(e.buyOrders[order.StockID], order)
-	} else {
-		e.sellOrders[order.StockID] = append(e.sellOrders[order.StockID], order)
+	defer e.lockStock(order.StockID)()
+
+	if err := e.checkEntry(order); err != nil {
+		return err
 	}
 
-	// Try to match orders
-	e.matchOrders(order.StockID)
+	// Check and reserve funds in one step so parallel orders on other stocks
+	// cannot spend the same cash or shares
+	account := e.accountLock(order.TraderID)
+	account.Lock()
+	err := e.checkFunds(order)
+	if err == nil {
+		e.reserve(order)
+	}
+	account.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := e.accept(order); err != nil {
+		return err
+	}
+	e.route(order)
+
+	e.processTriggers(order.StockID)
+	e.publishIndicative(order.StockID)
 
 	return nil
 }
 
+// checkEntry runs the checks on a validated order that depend on its stock's
+// status and book. A post-only order may be repriced. The caller must hold
+// the stock's lock.
+func (e *Exchange) checkEntry(order *models.Order) error {
+	stock := e.stocks[order.StockID]
+	if err := checkOrderEntry(stock, order); err != nil {
+		return err
+	}
+	if order.IsTrailing() {
+		startTrail(stock, order)
+	}
+	if order.PostOnly != "" {
+		price, err := e.postOnlyPrice(stock, order, order.Price)
+		if err != nil {
+			return err
+		}
+		order.Price = price
+	}
+	if !order.IsMarket() && !order.IsStop() {
+		if err := checkPriceBand(stock, order.Price); err != nil {
+			return err
+		}
+	}
+	if order.IsMarket() && !order.IsStop() && len(e.books[order.StockID].Opposite(order.Type)) == 0 {
+		return fmt.Errorf("no liquidity available for market order")
+	}
+	return nil
+}
+
+// accept adds an order whose funds are reserved to the order store. If that
+// fails the reservation is given back.
+func (e *Exchange) accept(order *models.Order) error {
+	order.OriginalQuantity = order.Quantity
+	order.UpdatedAt = order.CreatedAt
+	order.Fills = make([]models.Fill, 0)
+	if err := e.orders.add(order); err != nil {
+		account := e.accountLock(order.TraderID)
+		account.Lock()
+		e.release(order)
+		account.Unlock()
+		return err
+	}
+	e.touchAccepted(order)
+	return nil
+}
+
+// route sends an accepted order into matching, or a stop order to wait in
+// the trigger book. One whose stop has already traded is picked up by the
+// next processTriggers.
+func (e *Exchange) route(order *models.Order) {
+	if order.IsStop() {
+		e.stopBooks[order.StockID].Add(order)
+		e.touch(order)
+	} else {
+		e.submitOrder(order)
+	}
+}
+
+// submitOrder sends a validated (or just triggered) order into matching
+func (e *Exchange) submitOrder(order *models.Order) {
+	// A stock that is not trading continuously collects orders for its
+	// next auction
+	if stock := e.stocks[order.StockID]; !stock.IsTrading() {
+		if order.IsImmediate() {
+			e.closeOrder(order, models.Cancelled)
+			log.Printf("Order %s cancelled: %s is %s", order.ID, order.StockID, stock.GetStatus())
+		} else {
+			e.rest(order)
+		}
+		return
+	}
+
+	// Fill-or-kill orders must be fillable in full before anything trades
+	if order.TimeInForce == models.FOK && e.availableQuantity(order) < order.Quantity {
+		e.closeOrder(order, models.Cancelled)
+		log.Printf("Fill-or-kill order %s killed: %d shares not available", order.ID, order.Quantity)
+		return
+	}
+
+	// Cross against the opposite side first, then rest whatever is left
+	e.matchOrder(order)
+	if order.IsActive() {
+		if order.IsImmediate() {
+			// Market, IOC and FOK orders never rest, the unfilled remainder is cancelled
+			e.closeOrder(order, models.Cancelled)
+			log.Printf("Order %s cancelled with %d shares unfilled", order.ID, order.Quantity)
+		} else {
+			e.rest(order)
+		}
+	}
+}
+
+// processTriggers activates stop orders whose stop price has been reached by
+// the stock's last price, and the exits of bracket entries that have filled.
+// Activated orders can trade and move the price again, so both are re-checked
+// after each one until nothing else fires. Nothing is activated unless the
+// stock is trading continuously.
+func (e *Exchange) processTriggers(stockID string) {
+	stock, exists := e.stocks[stockID]
+	if !exists {
+		return
+	}
+	stops := e.stopBooks[stockID]
+
+	for stock.IsTrading() {
+		if e.activateExits(stockID) {
+			continue
+		}
+
+		order := stops.PopTriggered(stock.GetPrice())
+		if order == nil {
+			return
+		}
+
+		now := time.Now()
+		order.TriggeredAt = &now
+		order.UpdatedAt = now
+		e.touch(order)
+		log.Printf("Stop order %s triggered at %s (stop %s)", order.ID, stock.GetPrice(), order.StopPrice)
+
+		if !order.IsMarket() {
+			if err := checkPriceBand(stock, order.Price); err != nil {
+				e.closeOrder(order, models.Cancelled)
+				log.Printf("Triggered stop order %s cancelled: %v", order.ID, err)
+				continue
+			}
+		}
+
+		// Funds and holdings may have changed since the order was placed,
+		// and a stop market buy is now priced against the book
+		account := e.accountLock(order.TraderID)
+		account.Lock()
+		e.release(order)
+		err := e.checkFunds(order)
+		if err == nil {
+			e.reserve(order)
+		}
+		account.Unlock()
+		if err != nil {
+			e.closeOrder(order, models.Cancelled)
+			log.Printf("Triggered stop order %s cancelled: %v", order.ID, err)
+			continue
+		}
+
+		e.submitOrder(order)
+	}
+}
+
+// UpdatePrice moves a stock's last price outside of trading (e.g. the price
+// updater) and activates any stop orders the new price reaches. A move past
+// the stock's price band stops at its edge, and the band stays where trading
+// left it. The price of a stock that is not trading continuously is left to
+// its next auction.
+func (e *Exchange) UpdatePrice(stockID string, price models.Money) {
+	stock, exists := e.stocks[stockID]
+	if !exists {
+		return
+	}
+
+	defer e.lockStock(stockID)()
+
+	if !stock.IsTrading() {
+		return
+	}
+	if lower, upper := stock.PriceBandLimits(); upper != 0 {
+		if price < lower {
+			price = lower
+		} else if price > upper {
+			price = upper
+		}
+	}
+	stock.SetPrice(price)
+	e.touch(e.stopBooks[stockID].Trail(stock, price)...)
+	e.processTriggers(stockID)
+}
+
+// validateOrder checks and fills in the order's own fields. Anything that
+// depends on the book or on funds is checked under the stock lock in PlaceOrder.
 func (e *Exchange) validateOrder(order *models.Order) error {
 	// Basic validation
 	if order.Quantity <= 0 {
 		return fmt.Errorf("quantity must be greater than 0")
 	}
-	if order.Price <= 0 {
+	if e.orders.taken(order.ID) {
+		return fmt.Errorf("duplicate order id: %s", order.ID)
+	}
+	if order.Kind == "" {
+		order.Kind = models.Limit
+	}
+	if order.TrailAmount < 0 || order.TrailPercent < 0 {
+		return fmt.Errorf("trail must be greater than 0")
+	}
+	switch order.Kind {
+	case models.Limit, models.Market:
+		if order.StopPrice != 0 {
+			return fmt.Errorf("stopPrice is only allowed for stop orders")
+		}
+		if order.IsTrailing() {
+			return fmt.Errorf("only stop orders can trail")
+		}
+	case models.Stop, models.StopLimit:
+		if order.IsTrailing() {
+			if err := checkTrail(order); err != nil {
+				return err
+			}
+		} else if order.StopPrice <= 0 {
+			return fmt.Errorf("stop price must be greater than 0")
+		}
+	default:
+		return fmt.Errorf("invalid order kind: %s", order.Kind)
+	}
+	if !order.IsMarket() && order.Price <= 0 {
 		return fmt.Errorf("price must be greater than 0")
 	}
+	if order.DisplayQuantity < 0 {
+		return fmt.Errorf("display quantity must be greater than 0")
+	}
+	if order.PostOnly != "" && !order.PostOnly.IsValid() {
+		return fmt.Errorf("invalid post-only mode: %s", order.PostOnly)
+	}
 
-	trader, exists := e.traders[order.TraderID]
+	if order.TimeInForce == "" {
+		order.TimeInForce = models.GTC
+	}
+	switch order.TimeInForce {
+	case models.GTC, models.IOC, models.FOK:
+		if order.ExpiresAt != nil {
+			return fmt.Errorf("expiresAt is only allowed for GTD orders")
+		}
+	case models.Day:
+		expiresAt := e.sessionCloseAfter(time.Now())
+		order.ExpiresAt = &expiresAt
+	case models.GTD:
+		if order.ExpiresAt == nil || !order.ExpiresAt.After(time.Now()) {
+			return fmt.Errorf("GTD orders require an expiresAt in the future")
+		}
+	default:
+		return fmt.Errorf("invalid time in force: %s", order.TimeInForce)
+	}
+
+	trader, exists := e.trader(order.TraderID)
+	traderMode := models.SelfTradePrevention("")
+	if exists {
+		account := e.accountLock(trader.ID)
+		account.RLock()
+		traderMode = trader.SelfTradePrevention
+		account.RUnlock()
+	}
 	if !exists && order.TraderID != "exchange" {
 		return fmt.Errorf("trader not found")
 	}
 
-	if order.Type == models.Buy && trader != nil {
-		requiredMoney := order.Price * float64(order.Quantity)
-		if trader.Money < requiredMoney {
-			return fmt.Errorf("insufficient funds")
+	// Orders without their own self-trade prevention mode take the trader's
+	if order.SelfTradePrevention == "" {
+		order.SelfTradePrevention = models.DefaultSelfTradePrevention
+		if traderMode != "" {
+			order.SelfTradePrevention = traderMode
 		}
 	}
+	if !order.SelfTradePrevention.IsValid() {
+		return fmt.Errorf("invalid self-trade prevention mode: %s", order.SelfTradePrevention)
+	}
 
-	if order.Type == models.Sell && trader != nil {
-		holdings := trader.Holdings[order.StockID]
+	stock, exists := e.stocks[order.StockID]
+	if !exists {
+		return fmt.Errorf("stock not found")
+	}
 
-		// Calculate shares already committed in pending sell orders
-		pendingSellQuantity := 0
-		for _, sellOrder := range e.sellOrders[order.StockID] {
-			if sellOrder.TraderID == order.TraderID && sellOrder.Status == models.Open {
-				pendingSellQuantity += sellOrder.Quantity
-			}
+	return checkTradingRules(stock, order)
+}
+
+// checkOrderEntry rejects orders a stock does not take in its current status
+func checkOrderEntry(stock *models.Stock, order *models.Order) error {
+	switch status := stock.GetStatus(); status {
+	case models.Closed:
+		return fmt.Errorf("market is closed")
+	case models.CancelOnly:
+		return fmt.Errorf("market is closing: only cancellations are accepted")
+	case models.Halted, models.OpeningAuction, models.ClosingAuction:
+		if order.IsImmediate() {
+			return fmt.Errorf("%s is in %s: only orders that can rest are accepted", stock.ID, status)
+		}
+	}
+	return nil
+}
+
+// postOnlyPrice returns the price a post-only order can rest at without
+// taking liquidity: the limit price if that does not cross the book, or one
+// tick short of the best opposite price if the order may be repriced. Only
+// continuous trading takes liquidity, so auctions accept any price. The
+// caller must hold the stock's lock.
+func (e *Exchange) postOnlyPrice(stock *models.Stock, order *models.Order, price models.Money) (models.Money, error) {
+	best := e.books[order.StockID].BestOpposite(order.Type)
+	if !stock.IsTrading() || best == nil {
+		return price, nil
+	}
+	if order.Type == models.Buy && price < best.Price || order.Type == models.Sell && price > best.Price {
+		return price, nil
+	}
+	if order.PostOnly == models.PostOnlyReject {
+		return 0, fmt.Errorf("post-only order would take liquidity at %s", best.Price)
+	}
+
+	repriced := stock.TickAbove(best.Price)
+	if order.Type == models.Buy {
+		repriced = stock.TickBelow(best.Price)
+	}
+	if repriced <= 0 {
+		return 0, fmt.Errorf("post-only order cannot be repriced below %s", best.Price)
+	}
+	log.Printf("Post-only order %s repriced from %s to %s", order.ID, price, repriced)
+	return repriced, nil
+}
+
+// checkTradingRules checks an order's prices are on the stock's tick grid
+// and its quantity is in whole lots within the stock's limits
+func checkTradingRules(stock *models.Stock, order *models.Order) error {
+	if !order.IsMarket() {
+		if err := checkTick(stock, "price", order.Price); err != nil {
+			return err
+		}
+	}
+	if order.IsStop() && !order.IsTrailing() {
+		if err := checkTick(stock, "stop price", order.StopPrice); err != nil {
+			return err
+		}
+	}
+	if order.IsIceberg() {
+		if err := checkIceberg(stock, order); err != nil {
+			return err
+		}
+	}
+	if (order.PostOnly != "" || order.Hidden) && (order.Kind != models.Limit || order.IsImmediate()) {
+		return fmt.Errorf("only limit orders that can rest on the book can be post-only or hidden")
+	}
+	if order.Hidden && order.IsIceberg() {
+		return fmt.Errorf("an order cannot be both hidden and an iceberg order")
+	}
+	return stock.ValidQuantity(order.Quantity)
+}
+
+// checkIceberg checks an iceberg order can rest and shows whole lots of
+// less than its full size
+func checkIceberg(stock *models.Stock, order *models.Order) error {
+	if order.IsImmediate() {
+		return fmt.Errorf("only orders that can rest on the book can be iceberg orders")
+	}
+	if order.DisplayQuantity >= order.Quantity {
+		return fmt.Errorf("display quantity must be less than the order quantity")
+	}
+	if order.DisplayQuantity%stock.Lot() != 0 {
+		return fmt.Errorf("display quantity %d is not a multiple of the lot size %d", order.DisplayQuantity, stock.Lot())
+	}
+	return nil
+}
+
+func checkTick(stock *models.Stock, field string, price models.Money) error {
+	if !stock.OnTick(price) {
+		return fmt.Errorf("%s %s is not a multiple of the tick size %s", field, price, stock.TickFor(price))
+	}
+	return nil
+}
+
+// checkFunds verifies the trader can pay for a buy order or deliver the
+// shares for a sell order out of what other open orders have not reserved.
+// The order's own reservation, if any, must be released first. The caller
+// must hold the order's stock lock and its trader's account lock.
+func (e *Exchange) checkFunds(order *models.Order) error {
+	trader, _ := e.trader(order.TraderID)
+	if trader == nil {
+		return nil // The exchange itself is not limited
+	}
+
+	// What the other leg of a one-cancels-other pair holds back covers this one too
+	leg := sharedLeg(order)
+
+	if order.Type == models.Buy {
+		availableCash := trader.AvailableCash()
+		if leg != nil {
+			availableCash += leg.ReservedCash
 		}
+		if availableCash < e.requiredCash(order) {
+			return fmt.Errorf("insufficient funds")
+		}
+	}
 
-		// Check if trader has enough available shares (holdings - pending sells)
-		availableShares := holdings - pendingSellQuantity
+	if order.Type == models.Sell {
+		// Shares already committed to pending sell orders, including untriggered stops
+		availableShares := trader.AvailableShares(order.StockID)
+		if leg != nil {
+			availableShares += leg.ReservedShares
+		}
 		if availableShares < order.Quantity {
 			return fmt.Errorf("insufficient holdings: have %d shares, %d already pending sale, only %d available",
-				holdings, pendingSellQuantity, availableShares)
+				trader.Holdings[order.StockID], trader.ReservedShares[order.StockID], availableShares)
 		}
 	}
 
 	return nil
 }
 
-func (e *Exchange) matchOrders(stockID string) {
-	buyOrders := e.buyOrders[stockID]
-	sellOrders := e.sellOrders[stockID]
+// sessionCloseAfter returns the first session close strictly after now
+func (e *Exchange) sessionCloseAfter(now time.Time) time.Time {
+	if e.calendar != nil {
+		if close, ok := e.calendar.NextClose(now); ok {
+			return close
+		}
+	}
 
-	for _, buyOrder := range buyOrders {
-		if buyOrder.Status != models.Open {
+	year, month, day := now.Date()
+	close := time.Date(year, month, day, 0, 0, 0, 0, now.Location()).Add(DefaultSessionClose)
+	if !close.After(now) {
+		close = close.AddDate(0, 0, 1)
+	}
+	return close
+}
+
+// walkBook visits the resting orders an incoming order would meet, in the
+// order matchOrder meets them, with how many of its shares each would take:
+// by trading, or for one of the trader's own orders under decrement and
+// cancel, by taking them off both orders. Cancel-oldest orders pass the
+// trader's own orders without taking anything; every other self-trade
+// prevention mode stops there. Nothing is met beyond the stock's price band
+// or once the incoming order would be used up.
+func (e *Exchange) walkBook(order *models.Order, visit func(resting *models.Order, quantity int)) {
+	stock := e.stocks[order.StockID]
+	remaining := order.Quantity
+	for _, resting := range e.books[order.StockID].Opposite(order.Type) {
+		if remaining == 0 || !crosses(order, resting) || !stock.InPriceBand(resting.Price) {
+			return
+		}
+		if resting.TraderID == order.TraderID && order.SelfTradePrevention != models.DecrementAndCancel {
+			if order.SelfTradePrevention != models.CancelOldest {
+				return
+			}
 			continue
 		}
+		quantity := min(remaining, resting.Quantity)
+		visit(resting, quantity)
+		remaining -= quantity
+	}
+}
 
-		for _, sellOrder := range sellOrders {
-			if sellOrder.Status != models.Open {
-				continue
-			}
+// availableQuantity returns how many of an incoming order's shares the book
+// would take right now, counting shares taken off by decrement and cancel
+func (e *Exchange) availableQuantity(order *models.Order) int {
+	available := 0
+	e.walkBook(order, func(resting *models.Order, quantity int) {
+		available += quantity
+	})
+	return available
+}
 
-			if sellOrder.TraderID == buyOrder.TraderID {
-				continue // Can't trade with yourself
-			}
+// estimateMarketCost walks the ask side the way matchOrder would and returns
+// what a market buy would pay for the liquidity currently available to it.
+// Shares taken off against the trader's own orders cost nothing.
+func (e *Exchange) estimateMarketCost(order *models.Order) models.Money {
+	cost := models.Money(0)
+	e.walkBook(order, func(resting *models.Order, quantity int) {
+		if resting.TraderID != order.TraderID {
+			cost += resting.Price.Mul(quantity)
+		}
+	})
+	return cost
+}
 
-			if buyOrder.Price >= sellOrder.Price {
-				// Execute trade at the buyer's price (since buyer is willing to pay more)
-				quantity := min(buyOrder.Quantity, sellOrder.Quantity)
-				executionPrice := buyOrder.Price
-				e.executeTrade(buyOrder, sellOrder, quantity, executionPrice)
+// matchOrder crosses an incoming order against the opposite side of its
+// stock's book, best price first and oldest first within a price level. A
+// resting iceberg order only trades its displayed peak at a time and then
+// rejoins the back of its level with a fresh peak. A trade that would print
+// outside the stock's price band halts the stock and stops matching.
+func (e *Exchange) matchOrder(order *models.Order) {
+	book := e.books[order.StockID]
+	stock := e.stocks[order.StockID]
+
+	for order.IsActive() && order.Quantity > 0 {
+		resting := book.BestOpposite(order.Type)
+		if resting == nil || !crosses(order, resting) {
+			break
+		}
 
-				if buyOrder.Quantity == 0 {
-					break
-				}
-			}
+		if resting.TraderID == order.TraderID {
+			// Can't trade with yourself
+			e.preventSelfTrade(order, resting)
+			continue
+		}
+
+		buyOrder, sellOrder := order, resting
+		if order.Type == models.Sell {
+			buyOrder, sellOrder = resting, order
+		}
+
+		// Execute trade at the resting (maker) order's price
+		quantity := min(order.Quantity, resting.Visible())
+		executionPrice := resting.Price
+		if !stock.InPriceBand(executionPrice) {
+			e.halt(order.StockID, executionPrice)
+			break
+		}
+		e.executeTrade(buyOrder, sellOrder, quantity, executionPrice, order.Type)
+
+		// Filled resting orders leave the book
+		if resting.Quantity == 0 {
+			book.Remove(resting.ID)
+		} else {
+			e.replenish(resting)
 		}
 	}
+}
 
-	// Clean up filled orders
-	e.cleanupOrders(stockID)
+// replenish refreshes a resting iceberg order whose displayed peak has
+// traded away from its hidden reserve. The new peak loses time priority and
+// goes to the back of its price level. The caller must hold the stock's lock.
+func (e *Exchange) replenish(order *models.Order) {
+	if !order.IsIceberg() || order.Displayed > 0 || order.Quantity == 0 {
+		return
+	}
+
+	book := e.books[order.StockID]
+	book.Remove(order.ID)
+	order.RefreshPeak()
+	book.Add(order)
+	e.touch(order)
+	log.Printf("Iceberg order %s refreshed: %d of %d shares displayed", order.ID, order.Displayed, order.Quantity)
 }
 
-func (e *Exchange) executeTrade(buyOrder, sellOrder *models.Order, quantity int, price float64) {
-	// Update orders
-	buyOrder.Quantity -= quantity
-	sellOrder.Quantity -= quantity
+// rest puts an order that did not fully trade on its stock's book, showing
+// the first peak of an iceberg order
+func (e *Exchange) rest(order *models.Order) {
+	order.RefreshPeak()
+	e.books[order.StockID].Add(order)
+	e.touch(order)
+}
 
-	if buyOrder.Quantity == 0 {
-		buyOrder.Status = models.Filled
-	}
-	if sellOrder.Quantity == 0 {
-		sellOrder.Status = models.Filled
+// preventSelfTrade applies the incoming order's self-trade prevention mode
+// when it meets a resting order of the same trader. Orders it cancels are
+// taken off the book and release their reservations; an incoming order that
+// is still active afterwards carries on matching.
+func (e *Exchange) preventSelfTrade(incoming, resting *models.Order) {
+	book := e.books[incoming.StockID]
+
+	switch incoming.SelfTradePrevention {
+	case models.CancelOldest:
+		book.Remove(resting.ID)
+		e.closeOrder(resting, models.Cancelled)
+	case models.CancelBoth:
+		book.Remove(resting.ID)
+		e.closeOrder(resting, models.Cancelled)
+		e.closeOrder(incoming, models.Cancelled)
+	case models.DecrementAndCancel:
+		quantity := min(incoming.Quantity, resting.Quantity)
+		account := e.accountLock(incoming.TraderID) // Both are the trader's
+		account.Lock()
+		for _, order := range []*models.Order{incoming, resting} {
+			// A market buy only reserved for the shares it will trade, so
+			// those taken off here free nothing
+			if !(order.IsMarket() && order.Type == models.Buy) {
+				e.consume(order, quantity)
+			}
+			order.Quantity -= quantity
+			order.OriginalQuantity -= quantity
+			order.UpdatedAt = time.Now()
+			e.touch(order)
+		}
+		account.Unlock()
+		for _, order := range []*models.Order{incoming, resting} {
+			// Linked orders keep the same size as if the shares had traded
+			if leg := order.OCOLeg(); leg != nil && leg.IsActive() && leg != incoming && leg != resting {
+				e.reduceLeg(leg, quantity)
+			}
+		}
+		if resting.Quantity == 0 {
+			book.Remove(resting.ID)
+			e.closeOrder(resting, models.Cancelled)
+		}
+		if incoming.Quantity == 0 {
+			e.closeOrder(incoming, models.Cancelled)
+		}
+	default:
+		e.closeOrder(incoming, models.Cancelled)
 	}
 
+	log.Printf("Self-trade between orders %s and %s prevented (%s)", incoming.ID, resting.ID, incoming.SelfTradePrevention)
+}
+
+// executeTrade fills both orders for the given quantity at price. The aggressor
+// is the side of the incoming order that took liquidity from the book.
+func (e *Exchange) executeTrade(buyOrder, sellOrder *models.Order, quantity int, price models.Money, aggressor models.OrderType) {
 	// Create transaction
 	transaction := models.Transaction{
-		ID:         fmt.Sprintf("tx-%d", time.Now().UnixNano()),
-		BuyerID:    buyOrder.TraderID,
-		SellerID:   sellOrder.TraderID,
-		StockID:    buyOrder.StockID,
-		Price:      price,
-		Quantity:   quantity,
-		ExecutedAt: time.Now(),
+		BuyerID:       buyOrder.TraderID,
+		SellerID:      sellOrder.TraderID,
+		BuyOrderID:    buyOrder.ID,
+		SellOrderID:   sellOrder.ID,
+		StockID:       buyOrder.StockID,
+		Price:         price,
+		Quantity:      quantity,
+		AggressorSide: aggressor,
+		ExecutedAt:    time.Now(),
 	}
-	e.transactions = append(e.transactions, transaction)
+	e.recordTransaction(&transaction)
+
+	// Update orders
+	fill := models.Fill{
+		TransactionID: transaction.ID,
+		Price:         price,
+		Quantity:      quantity,
+		ExecutedAt:    transaction.ExecutedAt,
+	}
+
+	// Update trader holdings and money, one trader at a time
+	for _, order := range []*models.Order{buyOrder, sellOrder} {
+		account := e.accountLock(order.TraderID)
+		account.Lock()
+		e.consume(order, quantity)
+		e.settle(order, quantity, price)
+		account.Unlock()
+	}
+
+	buyOrder.AddFill(fill)
+	sellOrder.AddFill(fill)
+	e.touch(buyOrder, sellOrder)
+	e.fillLinked(buyOrder, quantity)
+	e.fillLinked(sellOrder, quantity)
 
 	// Update stock price
 	if stock, exists := e.stocks[buyOrder.StockID]; exists {
 		stock.SetPrice(price)
+		e.touch(e.stopBooks[stock.ID].Trail(stock, price)...)
 	}
 
-	// Update trader holdings and money
-	if buyOrder.TraderID != "exchange" {
-		buyer := e.traders[buyOrder.TraderID]
-		buyer.Money -= price * float64(quantity)
-		buyer.Holdings[buyOrder.StockID] += quantity
-	} else {
-		// If exchange is buying (someone selling back), increase available stock amount
-		if stock, exists := e.stocks[sellOrder.StockID]; exists {
-			stock.Amount += quantity
-			log.Printf("Exchange bought %d shares of %s, total available: %d", quantity, stock.ID, stock.Amount)
-		}
-	}
+	log.Printf("Trade executed: %s bought %d shares of %s from %s at %s",
+		buyOrder.TraderID, quantity, buyOrder.StockID, sellOrder.TraderID, price)
+}
 
-	if sellOrder.TraderID != "exchange" {
-		seller := e.traders[sellOrder.TraderID]
-		seller.Money += price * float64(quantity)
-		seller.Holdings[sellOrder.StockID] -= quantity
-	} else {
-		// If exchange is selling (initial stock supply), reduce available stock amount
-		if stock, exists := e.stocks[buyOrder.StockID]; exists {
-			stock.Amount -= quantity
-			log.Printf("Exchange sold %d shares of %s, remaining: %d", quantity, stock.ID, stock.Amount)
-		}
+// recordTransaction gives a transaction an ID that stays unique when stocks
+// trade in the same nanosecond, and stores it with the operation's other
+// changes. The caller must hold the stock's lock.
+func (e *Exchange) recordTransaction(transaction *models.Transaction) {
+	e.txMu.Lock()
+	id := transaction.ExecutedAt.UnixNano()
+	if id <= e.lastTxID {
+		id = e.lastTxID + 1
 	}
+	e.lastTxID = id
+	e.txMu.Unlock()
 
-	log.Printf("Trade executed: %s bought %d shares of %s from %s at %.2f",
-		buyOrder.TraderID, quantity, buyOrder.StockID, sellOrder.TraderID, price)
+	transaction.ID = fmt.Sprintf("tx-%d", id)
+	changes := e.changes[transaction.StockID]
+	changes.transactions = append(changes.transactions, *transaction)
 }
 
-func (e *Exchange) cleanupOrders(stockID string) {
-	// Remove filled buy orders
-	activeBuyOrders := make([]*models.Order, 0)
-	for _, order := range e.buyOrders[stockID] {
-		if order.Status == models.Open {
-			activeBuyOrders = append(activeBuyOrders, order)
+// settle moves the money and shares of one side of a trade in or out of its
+// trader's account. The caller must hold the stock lock and the trader's
+// account lock.
+func (e *Exchange) settle(order *models.Order, quantity int, price models.Money) {
+	if order.TraderID != "exchange" {
+		trader, _ := e.trader(order.TraderID)
+		if order.Type == models.Buy {
+			trader.Money -= price.Mul(quantity)
+			trader.Holdings[order.StockID] += quantity
+		} else {
+			trader.Money += price.Mul(quantity)
+			trader.Holdings[order.StockID] -= quantity
 		}
+		return
 	}
-	e.buyOrders[stockID] = activeBuyOrders
 
-	// Remove filled sell orders
-	activeSellOrders := make([]*models.Order, 0)
-	for _, order := range e.sellOrders[stockID] {
-		if order.Status == models.Open {
-			activeSellOrders = append(activeSellOrders, order)
-		}
+	stock, exists := e.stocks[order.StockID]
+	if !exists {
+		return
+	}
+	if order.Type == models.Buy {
+		// If exchange is buying (someone selling back), increase available stock amount
+		amount := stock.AddAmount(quantity)
+		log.Printf("Exchange bought %d shares of %s, total available: %d", quantity, stock.ID, amount)
+	} else {
+		// If exchange is selling (initial stock supply), reduce available stock amount
+		amount := stock.AddAmount(-quantity)
+		log.Printf("Exchange sold %d shares of %s, remaining: %d", quantity, stock.ID, amount)
 	}
-	e.sellOrders[stockID] = activeSellOrders
 }
 
 func min(a, b int) int {
@@ -271,12 +1001,12 @@ func min(a, b int) int {
 	return b
 }
 
+// GetAllStocks returns copies of the stocks, each taken under its lock so it
+// is not read while an operation on it is halfway through
 func (e *Exchange) GetAllStocks() []*models.Stock {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-
 	stocks := make([]*models.Stock, 0, len(e.stocks))
-	for _, stock := range e.stocks {
+	for stockID := range e.stocks {
+		stock, _ := e.GetStock(stockID)
 		stocks = append(stocks, stock)
 	}
 
@@ -291,106 +1021,290 @@ func (e *Exchange) GetAllStocks() []*models.Stock {
 	return stocks
 }
 
-func (e *Exchange) GetStock(stockID string) (*models.Stock, bool) {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
+// publicStocks returns the stocks as traders may see them, for subscribers
+func (e *Exchange) publicStocks() []*models.Stock {
+	stocks := e.GetAllStocks()
+	for i, stock := range stocks {
+		stocks[i] = stock.Public()
+	}
+	return stocks
+}
 
+// GetStock returns a copy of a stock taken under its lock
+func (e *Exchange) GetStock(stockID string) (*models.Stock, bool) {
 	stock, exists := e.stocks[stockID]
-	return stock, exists
+	if !exists {
+		return nil, false
+	}
+
+	mu := e.stockLocks[stockID]
+	mu.Lock()
+	defer mu.Unlock()
+	return stock.Clone(), true
 }
 
 func (e *Exchange) GetOpenOrders(stockID string) []models.Order {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-
 	orders := make([]models.Order, 0) // Initialize with empty slice instead of nil
 
-	// Add buy orders
-	for _, order := range e.buyOrders[stockID] {
-		if order.Status == models.Open {
-			orders = append(orders, *order)
-		}
+	book, exists := e.books[stockID]
+	if !exists {
+		return orders
 	}
 
-	// Add sell orders
-	for _, order := range e.sellOrders[stockID] {
-		if order.Status == models.Open {
-			orders = append(orders, *order)
+	mu := e.stockLocks[stockID]
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Add buy orders, best bid first, then sell orders, best ask first.
+	// Iceberg orders only show their peak and hidden orders not at all.
+	for _, order := range append(book.Bids(), book.Asks()...) {
+		if !order.Hidden {
+			orders = append(orders, order.Public())
 		}
 	}
 
 	return orders
 }
 
+// GetLastTransactions returns a stock's latest transactions, newest first
 func (e *Exchange) GetLastTransactions(stockID string, limit int) []models.Transaction {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
+	e.writer.flush()
+	transactions, err := e.store.StockTransactions(stockID, limit)
+	if err != nil {
+		log.Printf("Failed to read transactions of %s: %v", stockID, err)
+	}
+	return transactions
+}
 
-	var stockTransactions []models.Transaction
+func (e *Exchange) CancelOrder(orderID string) error {
+	order, stopped, unlock := e.findOpenOrder(orderID)
+	if order == nil {
+		return fmt.Errorf("order not found or already closed")
+	}
+	defer unlock()
 
-	// Filter transactions for this stock
-	for i := len(e.transactions) - 1; i >= 0 && len(stockTransactions) < limit; i-- {
-		if e.transactions[i].StockID == stockID {
-			stockTransactions = append(stockTransactions, e.transactions[i])
-		}
+	if stopped {
+		e.stopBooks[order.StockID].Remove(orderID)
+	} else {
+		e.books[order.StockID].Remove(orderID)
 	}
+	e.closeOrder(order, models.Cancelled)
+
+	// Cancelling a partly filled bracket entry activates its exits
+	e.processTriggers(order.StockID)
+	e.publishIndicative(order.StockID)
 
-	return stockTransactions
+	return nil
 }
 
-func (e *Exchange) HasConflictingOrder(traderID, stockID string, orderType models.OrderType) bool {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
+// AmendOrder changes the price and/or remaining quantity of a resting order.
+// A zero price or quantity leaves that field unchanged. Reducing the quantity
+// keeps the order's place in the queue; a price change or quantity increase
+// sends it to the back, and a new price may cross the book straight away.
+func (e *Exchange) AmendOrder(orderID string, price models.Money, quantity int) (models.Order, error) {
+	if price < 0 {
+		return models.Order{}, fmt.Errorf("price must be greater than 0")
+	}
+	if quantity < 0 {
+		return models.Order{}, fmt.Errorf("quantity must be greater than 0")
+	}
+	if price == 0 && quantity == 0 {
+		return models.Order{}, fmt.Errorf("nothing to amend: provide a new price and/or quantity")
+	}
 
-	// Check opposite order type
-	if orderType == models.Buy {
-		for _, order := range e.sellOrders[stockID] {
-			if order.TraderID == traderID && order.Status == models.Open {
-				return true
-			}
+	order, stopped, unlock := e.findOpenOrder(orderID)
+	if order == nil {
+		return models.Order{}, fmt.Errorf("order not found or already closed")
+	}
+	defer unlock()
+
+	if err := checkOrderEntry(e.stocks[order.StockID], order); err != nil {
+		return models.Order{}, err
+	}
+	if order.OCOLeg() != nil {
+		return models.Order{}, fmt.Errorf("linked orders cannot be amended: cancel and replace them")
+	}
+	if price == 0 {
+		price = order.Price
+	}
+	if quantity == 0 {
+		quantity = order.Quantity
+	}
+	if price != order.Price && order.IsMarket() {
+		return models.Order{}, fmt.Errorf("cannot amend the price of a market order")
+	}
+
+	// A partly filled order may be left with less than the minimum quantity,
+	// so only what changes has to meet the stock's trading rules again
+	stock := e.stocks[order.StockID]
+	if price != order.Price {
+		if err := checkTick(stock, "price", price); err != nil {
+			return models.Order{}, err
 		}
-	} else {
-		for _, order := range e.buyOrders[stockID] {
-			if order.TraderID == traderID && order.Status == models.Open {
-				return true
+		if order.PostOnly != "" {
+			repriced, err := e.postOnlyPrice(stock, order, price)
+			if err != nil {
+				return models.Order{}, err
 			}
+			price = repriced
+		}
+		if err := checkPriceBand(stock, price); err != nil {
+			return models.Order{}, err
 		}
 	}
+	if quantity != order.Quantity {
+		if err := stock.ValidQuantity(quantity); err != nil {
+			return models.Order{}, err
+		}
+	}
+
+	// Re-run the funds and holdings checks against the amended order in
+	// place of the original's reservation
+	amended := *order
+	amended.Price = price
+	amended.Quantity = quantity
+	account := e.accountLock(order.TraderID)
+	account.Lock()
+	e.release(order)
+	err := e.checkFunds(&amended)
+	if err == nil {
+		e.reserve(&amended)
+	} else {
+		e.reserve(order)
+	}
+	account.Unlock()
+	if err != nil {
+		return models.Order{}, err
+	}
+	order.ReservedCash = amended.ReservedCash
+	order.ReservedShares = amended.ReservedShares
+	e.touch(order)
+
+	// A pure quantity decrease is applied in place and keeps queue priority
+	if price == order.Price && quantity <= order.Quantity {
+		order.Quantity = quantity
+		order.OriginalQuantity = order.FilledQuantity + quantity
+		order.Displayed = min(order.Displayed, quantity)
+		order.UpdatedAt = time.Now()
+		log.Printf("Order %s reduced to %d shares", order.ID, quantity)
+		e.publishIndicative(order.StockID)
+		return *order, nil
+	}
+
+	// Anything else loses priority: take the order out and re-enter it
+	if stopped {
+		e.stopBooks[order.StockID].Remove(order.ID)
+	} else {
+		e.books[order.StockID].Remove(order.ID)
+	}
+	order.Price = price
+	order.Quantity = quantity
+	order.OriginalQuantity = order.FilledQuantity + quantity
+	order.UpdatedAt = time.Now()
+	log.Printf("Order %s amended to %d shares at %s", order.ID, quantity, price)
+
+	if stopped {
+		e.stopBooks[order.StockID].Add(order)
+	} else {
+		e.submitOrder(order)
+	}
+	e.processTriggers(order.StockID)
+	e.publishIndicative(order.StockID)
 
-	return false
+	return *order, nil
 }
 
-func (e *Exchange) CancelOrder(orderID string) error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+// findOpenOrder looks an open order up in the order store and takes the lock
+// of its stock, which the caller releases with unlock. stopped reports whether
+// it is an untriggered stop waiting in the trigger book rather than resting
+// on the order book.
+func (e *Exchange) findOpenOrder(orderID string) (order *models.Order, stopped bool, unlock func()) {
+	order, unlock = e.lockOrder(orderID)
+	if order == nil {
+		return nil, false, nil
+	}
+	if !order.IsActive() {
+		unlock()
+		return nil, false, nil
+	}
+	return order, order.IsStop() && !order.IsTriggered(), unlock
+}
+
+// OrderExpiredEvent is broadcast when an order on the book expires. It only
+// carries what the book showed of the order.
+type OrderExpiredEvent struct {
+	OrderID   string           `json:"orderId"`
+	StockID   string           `json:"stockId"`
+	Type      models.OrderType `json:"type"`
+	Price     models.Money     `json:"price"`
+	Quantity  int              `json:"quantity"` // Shares the book showed
+	ExpiredAt time.Time        `json:"expiredAt"`
+}
 
-	// Search in all order books
+// ExpireOrders takes DAY and GTD orders whose expiry has passed off the book,
+// marks them expired and notifies subscribers. It returns how many expired.
+func (e *Exchange) ExpireOrders(now time.Time) int {
+	expired := 0
 	for stockID := range e.stocks {
-		// Check buy orders
-		for i, order := range e.buyOrders[stockID] {
-			if order.ID == orderID && order.Status == models.Open {
-				order.Status = models.Cancelled
-				e.buyOrders[stockID] = append(e.buyOrders[stockID][:i], e.buyOrders[stockID][i+1:]...)
-				return nil
-			}
+		expired += e.expireStockOrders(stockID, now)
+	}
+	return expired
+}
+
+// expireStockOrders expires one stock's orders under its lock
+func (e *Exchange) expireStockOrders(stockID string, now time.Time) int {
+	defer e.lockStock(stockID)()
+
+	expired := 0
+	book := e.books[stockID]
+	for _, order := range append(book.Bids(), book.Asks()...) {
+		// Expiring one leg of a one-cancels-other pair cancels the other
+		if !order.IsActive() || !order.IsExpired(now) {
+			continue
 		}
 
-		// Check sell orders
-		for i, order := range e.sellOrders[stockID] {
-			if order.ID == orderID && order.Status == models.Open {
-				order.Status = models.Cancelled
-				e.sellOrders[stockID] = append(e.sellOrders[stockID][:i], e.sellOrders[stockID][i+1:]...)
-				return nil
-			}
+		event := OrderExpiredEvent{
+			OrderID:   order.ID,
+			StockID:   order.StockID,
+			Type:      order.Type,
+			Price:     order.Price,
+			Quantity:  order.Visible(),
+			ExpiredAt: now,
+		}
+		book.Remove(order.ID)
+		e.closeOrder(order, models.Expired)
+		expired++
+
+		log.Printf("Order %s expired with %d shares unfilled", order.ID, order.Quantity)
+		if !order.Hidden {
+			e.broadcast(Update{Type: "orderExpired", Data: event})
 		}
 	}
 
-	return fmt.Errorf("order not found or already closed")
+	stops := e.stopBooks[stockID]
+	for _, order := range stops.Orders() {
+		if !order.IsActive() || !order.IsExpired(now) {
+			continue
+		}
+
+		stops.Remove(order.ID)
+		e.closeOrder(order, models.Expired)
+		expired++
+
+		// Stop orders are not on the book, so their expiry is not published
+		log.Printf("Stop order %s expired before triggering", order.ID)
+	}
+
+	if expired > 0 {
+		e.processTriggers(stockID)
+		e.publishIndicative(stockID)
+	}
+	return expired
 }
 
 func (e *Exchange) GetAllTraders() []TraderInfo {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
+	e.tradersMu.RLock()
+	defer e.tradersMu.RUnlock()
 
 	traders := make([]TraderInfo, 0, len(e.traders))
 	for _, trader := range e.traders {
@@ -412,75 +1326,88 @@ func (e *Exchange) GetAllTraders() []TraderInfo {
 }
 
 func (e *Exchange) GetTrader(traderID string) (*models.Trader, bool) {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
+	return e.trader(traderID)
+}
 
-	trader, exists := e.traders[traderID]
-	return trader, exists
+// GetTraderSnapshot returns a copy of a trader's account that is safe to read
+// while the exchange keeps trading
+func (e *Exchange) GetTraderSnapshot(traderID string) (*models.Trader, bool) {
+	trader, exists := e.trader(traderID)
+	if !exists {
+		return nil, false
+	}
+	account := e.accountLock(traderID)
+	account.RLock()
+	defer account.RUnlock()
+
+	return cloneTrader(trader), true
 }
 
-func (e *Exchange) GetTraderOpenOrders(traderID string) []models.Order {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
+// SetSelfTradePrevention changes a trader's default self-trade prevention
+// mode. Orders already placed keep the mode they were accepted with.
+func (e *Exchange) SetSelfTradePrevention(traderID string, mode models.SelfTradePrevention) error {
+	defer e.writer.write() // Once the account lock is released
+	trader, exists := e.trader(traderID)
+	if !exists {
+		return fmt.Errorf("trader not found")
+	}
+	if !mode.IsValid() {
+		return fmt.Errorf("invalid self-trade prevention mode: %s", mode)
+	}
+	account := e.accountLock(traderID)
+	account.Lock()
+	defer account.Unlock()
 
-	orders := make([]models.Order, 0) // Initialize with empty slice instead of nil
+	trader.SelfTradePrevention = mode
+	e.recordTrader(EventTraderUpdated, trader)
+	return nil
+}
 
-	// Check all stocks
-	for stockID := range e.stocks {
-		// Buy orders
-		for _, order := range e.buyOrders[stockID] {
-			if order.TraderID == traderID && order.Status == models.Open {
-				orders = append(orders, *order)
-			}
-		}
+func (e *Exchange) GetTraderOpenOrders(traderID string) []models.Order {
+	orders := make([]models.Order, 0) // Initialize with empty slice instead of nil
 
-		// Sell orders
-		for _, order := range e.sellOrders[stockID] {
-			if order.TraderID == traderID && order.Status == models.Open {
-				orders = append(orders, *order)
-			}
+	// Resting orders and stop orders waiting for their trigger
+	for _, order := range e.traderOrderList(traderID) {
+		if snapshot := e.snapshot(order); snapshot.IsActive() {
+			orders = append(orders, snapshot)
 		}
 	}
 
 	return orders
 }
 
-func (e *Exchange) GetTraderTransactions(traderID string, limit int) []models.Transaction {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-
-	var traderTransactions []models.Transaction
+// traderOrderList returns the trader's open orders in arrival order. The
+// orders themselves must be read under their stock's lock, e.g. with snapshot.
+func (e *Exchange) traderOrderList(traderID string) []*models.Order {
+	return e.orders.traderOrders(traderID)
+}
 
-	// Filter transactions for this trader
-	for i := len(e.transactions) - 1; i >= 0 && len(traderTransactions) < limit; i-- {
-		tx := e.transactions[i]
-		if tx.BuyerID == traderID || tx.SellerID == traderID {
-			traderTransactions = append(traderTransactions, tx)
-		}
+// GetTraderTransactions returns a trader's latest transactions, newest first
+func (e *Exchange) GetTraderTransactions(traderID string, limit int) []models.Transaction {
+	e.writer.flush()
+	transactions, err := e.store.TraderTransactions(traderID, limit)
+	if err != nil {
+		log.Printf("Failed to read transactions of %s: %v", traderID, err)
 	}
-
-	return traderTransactions
+	return transactions
 }
 
-func (e *Exchange) CalculateProfitLoss(traderID string) float64 {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-
-	trader, exists := e.traders[traderID]
+func (e *Exchange) CalculateProfitLoss(traderID string) models.Money {
+	trader, exists := e.trader(traderID)
 	if !exists {
 		return 0
 	}
+	account := e.accountLock(traderID)
+	account.RLock()
+	defer account.RUnlock()
 
-	// Calculate current portfolio value
-	portfolioValue := trader.Money
-
-	for stockID, quantity := range trader.Holdings {
-		if stock, exists := e.stocks[stockID]; exists {
-			portfolioValue += stock.CurrentPrice * float64(quantity)
-		}
-	}
+	return e.profitLoss(trader)
+}
 
-	return portfolioValue - trader.InitialMoney // Use actual initial money
+// profitLoss values a trader's portfolio at the last prices. The caller must
+// hold the trader's account lock.
+func (e *Exchange) profitLoss(trader *models.Trader) models.Money {
+	return e.valuation(trader, time.Time{}).ProfitLoss
 }
 
 // WebSocket support
@@ -495,9 +1422,9 @@ func (e *Exchange) Subscribe() *Subscription {
 		done: make(chan struct{}),
 	}
 
-	e.mu.Lock()
+	e.subMu.Lock()
 	e.subscriptions[sub] = true
-	e.mu.Unlock()
+	e.subMu.Unlock()
 
 	// Send periodic updates
 	go func() {
@@ -511,7 +1438,7 @@ func (e *Exchange) Subscribe() *Subscription {
 				return
 			case <-ticker.C:
 				select {
-				case sub.ch <- Update{Type: "stocks", Data: e.GetAllStocks()}:
+				case sub.ch <- Update{Type: "stocks", Data: e.publicStocks()}:
 				case <-sub.done:
 					return
 				default:
@@ -524,9 +1451,24 @@ func (e *Exchange) Subscribe() *Subscription {
 	return sub
 }
 
+// broadcast pushes an update to every subscriber without blocking. It holds
+// e.subMu so no subscription is torn down mid-send.
+func (e *Exchange) broadcast(update Update) {
+	e.subMu.RLock()
+	defer e.subMu.RUnlock()
+
+	for sub := range e.subscriptions {
+		select {
+		case sub.ch <- update:
+		default:
+			// Channel full, skip update
+		}
+	}
+}
+
 func (e *Exchange) Unsubscribe(sub *Subscription) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+	e.subMu.Lock()
+	defer e.subMu.Unlock()
 
 	if _, exists := e.subscriptions[sub]; exists {
 		delete(e.subscriptions, sub)
@@ -552,85 +1494,26 @@ type TraderInfo struct {
 	Name string `json:"name"`
 }
 
-// GetStockHistory returns historical price data for a stock
-func (e *Exchange) GetStockHistory(stockID string, days int) []models.PriceQuote {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-
-	// For now, generate sample historical data
-	// In a real implementation, this would query a database
-	history := make([]models.PriceQuote, days)
-
-	stock, exists := e.stocks[stockID]
-	if !exists {
-		return history
-	}
-
-	currentPrice := stock.GetPrice()
-
-	for i := 0; i < days; i++ {
-		// Generate realistic price movement
-		change := (0.5 - rand.Float64()) * 10                        // Random change between -5 and +5
-		priceVariation := currentPrice * (0.95 + rand.Float64()*0.1) // ±5% variation
-
-		history[i] = models.PriceQuote{
-			Timestamp: time.Now().AddDate(0, 0, -(days - i - 1)),
-			Price:     priceVariation + change,
-			Volume:    50 + rand.Intn(200), // Random volume between 50-250
-		}
-	}
-
-	return history
-}
-
-// GetTraderPerformance returns historical performance data for a trader
-func (e *Exchange) GetTraderPerformance(traderID string, days int) []models.PerformanceData {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-
-	performance := make([]models.PerformanceData, days)
-
-	trader, exists := e.traders[traderID]
-	if !exists {
-		return performance
-	}
-
-	// Calculate current values
-	profitLoss := e.CalculateProfitLoss(traderID)
-	portfolioValue := trader.Money + profitLoss
-
-	for i := 0; i < days; i++ {
-		// Generate realistic performance progression
-		dayProgress := float64(i) / float64(days)
-		valueChange := (0.5 - rand.Float64()) * 2000 // Daily variation
-
-		performance[i] = models.PerformanceData{
-			Date:           time.Now().AddDate(0, 0, -(days - i - 1)),
-			PortfolioValue: portfolioValue*(0.8+dayProgress*0.4) + valueChange,
-			ProfitLoss:     profitLoss*dayProgress + valueChange*0.5,
-			CashBalance:    trader.Money,
-		}
-	}
-
-	return performance
-}
-
 // GetTraderPortfolio returns current portfolio distribution
 func (e *Exchange) GetTraderPortfolio(traderID string) models.PortfolioData {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-
-	trader, exists := e.traders[traderID]
+	trader, exists := e.trader(traderID)
+	cash := models.Money(0)
+	if exists {
+		account := e.accountLock(traderID)
+		account.RLock()
+		cash = trader.Money
+		account.RUnlock()
+	}
 	if !exists {
 		return models.PortfolioData{}
 	}
 
 	holdings := make([]models.PortfolioHolding, 0)
-	totalValue := trader.Money
+	totalValue := cash
 
 	// Calculate holdings from transactions
 	stockHoldings := make(map[string]int)
-	for _, tx := range e.transactions {
+	for _, tx := range e.GetTraderTransactions(traderID, 0) {
 		if tx.BuyerID == traderID {
 			stockHoldings[tx.StockID] += tx.Quantity
 		} else if tx.SellerID == traderID {
@@ -643,7 +1526,7 @@ func (e *Exchange) GetTraderPortfolio(traderID string) models.PortfolioData {
 		if quantity > 0 {
 			stock, exists := e.stocks[stockID]
 			if exists {
-				value := float64(quantity) * stock.GetPrice()
+				value := stock.GetPrice().Mul(quantity)
 				totalValue += value
 
 				holdings = append(holdings, models.PortfolioHolding{
@@ -658,67 +1541,60 @@ func (e *Exchange) GetTraderPortfolio(traderID string) models.PortfolioData {
 
 	// Calculate percentages
 	for i := range holdings {
-		holdings[i].Percentage = (holdings[i].Value / totalValue) * 100
+		holdings[i].Percentage = (holdings[i].Value.Float64() / totalValue.Float64()) * 100
 	}
 
 	return models.PortfolioData{
 		Holdings:    holdings,
 		TotalValue:  totalValue,
-		CashBalance: trader.Money,
+		CashBalance: cash,
 	}
 }
 
-// GetTraderActivity returns trading activity over periods
+// GetTraderActivity returns a trader's recorded trading activity in each of
+// the last months, oldest first: the orders that traded on either side and
+// the shares and value traded
 func (e *Exchange) GetTraderActivity(traderID string, months int) []models.ActivityLog {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
+	now := time.Now()
+	first := time.Date(now.Year(), now.Month()-time.Month(months-1), 1, 0, 0, 0, 0, now.Location())
 
 	activity := make([]models.ActivityLog, months)
-	monthNames := []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
-
-	for i := 0; i < months; i++ {
-		month := time.Now().AddDate(0, -(months - i - 1), 0)
-		monthName := monthNames[month.Month()-1]
-
-		buyOrders := 0
-		sellOrders := 0
-		volume := 0
-		value := 0.0
-
-		// Count orders for this trader in this month
-		// In a real implementation, this would filter by actual dates
-		for _, tx := range e.transactions {
-			if tx.BuyerID == traderID || tx.SellerID == traderID {
-				if tx.BuyerID == traderID {
-					buyOrders++
-				} else {
-					sellOrders++
-				}
-				volume += tx.Quantity
-				value += tx.Price * float64(tx.Quantity)
-			}
-		}
-
-		// Add some randomness for demo purposes
-		buyOrders += 5 + rand.Intn(20)
-		sellOrders += 3 + rand.Intn(15)
+	buyOrders := make([]map[string]bool, months)
+	sellOrders := make([]map[string]bool, months)
+	for i := range activity {
+		activity[i].Period = first.AddDate(0, i, 0).Month().String()[:3]
+		buyOrders[i] = make(map[string]bool)
+		sellOrders[i] = make(map[string]bool)
+	}
 
-		activity[i] = models.ActivityLog{
-			Period:     monthName,
-			BuyOrders:  buyOrders,
-			SellOrders: sellOrders,
-			Volume:     volume + 100 + rand.Intn(500),
-			Value:      value + 5000 + rand.Float64()*20000,
+	for _, tx := range e.GetTraderTransactions(traderID, 0) {
+		executed := tx.ExecutedAt.In(now.Location())
+		i := (executed.Year()-first.Year())*12 + int(executed.Month()-first.Month())
+		if i < 0 || i >= months {
+			continue
+		}
+		if tx.BuyerID == traderID {
+			buyOrders[i][tx.BuyOrderID] = true
+		}
+		if tx.SellerID == traderID {
+			sellOrders[i][tx.SellOrderID] = true
 		}
+		activity[i].Volume += tx.Quantity
+		activity[i].Value += tx.Price.Mul(tx.Quantity)
 	}
 
+	for i := range activity {
+		activity[i].BuyOrders = len(buyOrders[i])
+		activity[i].SellOrders = len(sellOrders[i])
+	}
 	return activity
 }
 
 // RegisterTrader adds a new trader to the exchange
-func (e *Exchange) RegisterTrader(traderID, name string, initialMoney float64) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+func (e *Exchange) RegisterTrader(traderID, name string, initialMoney models.Money) {
+	defer e.writer.write() // Once the traders lock is released
+	e.tradersMu.Lock()
+	defer e.tradersMu.Unlock()
 
 	// Check if trader already exists
 	if _, exists := e.traders[traderID]; exists {
@@ -729,5 +1605,6 @@ func (e *Exchange) RegisterTrader(traderID, name string, initialMoney float64) {
 	// Create new trader
 	trader := models.NewTrader(traderID, name, initialMoney)
 	e.traders[traderID] = trader
-	log.Printf("✅ Registered new trader: %s (%s) with $%.2f", name, traderID, initialMoney)
+	e.recordTrader(EventTraderRegistered, trader)
+	log.Printf("✅ Registered new trader: %s (%s) with $%s", name, traderID, initialMoney)
 }
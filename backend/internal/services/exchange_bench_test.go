@@ -0,0 +1,74 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"stock-exchange/internal/models"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// createBenchExchange lists the given number of stocks and gives each its own
+// buyer and seller with enough money and shares for the whole run, so the
+// stocks share no trader
+func createBenchExchange(symbols int) *Exchange {
+	exchange := NewExchange()
+
+	for i := 0; i < symbols; i++ {
+		stockID := fmt.Sprintf("%d", i+1)
+		exchange.addStock(&models.Stock{ID: stockID, Name: "Stock " + stockID, CurrentPrice: money(100.0)})
+
+		buyer := models.NewTrader("buyer"+stockID, "Buyer "+stockID, money(1e15))
+		seller := models.NewTrader("seller"+stockID, "Seller "+stockID, 0)
+		seller.Holdings[stockID] = 1 << 40
+		exchange.traders[buyer.ID] = buyer
+		exchange.traders[seller.ID] = seller
+	}
+
+	return exchange
+}
+
+// BenchmarkConcurrentOrderPlacement runs TestConcurrentOrderPlacement-style
+// load, a resting sell followed by a crossing buy, from parallel goroutines
+// spread over a growing number of stocks. Each stock matches under its own
+// lock, so with enough cores orders/s grows with the number of symbols
+// instead of staying flat behind one exchange-wide lock.
+func BenchmarkConcurrentOrderPlacement(b *testing.B) {
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(io.Discard)
+
+	for _, symbols := range []int{1, 2, 4, 8, 16} {
+		b.Run(fmt.Sprintf("symbols=%d", symbols), func(b *testing.B) {
+			exchange := createBenchExchange(symbols)
+
+			var workers, orderSeq uint64
+			b.SetParallelism(symbols)
+			b.ResetTimer()
+			start := time.Now()
+
+			b.RunParallel(func(pb *testing.PB) {
+				// Each goroutine sticks to one stock
+				stockID := fmt.Sprintf("%d", atomic.AddUint64(&workers, 1)%uint64(symbols)+1)
+
+				for pb.Next() {
+					id := atomic.AddUint64(&orderSeq, 1)
+					sell := createTestOrder(fmt.Sprintf("sell%d", id), "seller"+stockID, stockID, models.Sell, 100.0, 1)
+					buy := createTestOrder(fmt.Sprintf("buy%d", id), "buyer"+stockID, stockID, models.Buy, 100.0, 1)
+
+					if err := exchange.PlaceOrder(sell); err != nil {
+						b.Error(err)
+						return
+					}
+					if err := exchange.PlaceOrder(buy); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+
+			b.ReportMetric(float64(2*b.N)/time.Since(start).Seconds(), "orders/s")
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package services
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
 	"stock-exchange/internal/models"
+	"stock-exchange/internal/storage"
 	"sync"
 	"testing"
 	"time"
@@ -16,27 +19,23 @@ func createTestExchange() *Exchange {
 	stock1 := &models.Stock{
 		ID:           "1",
 		Name:         "Apple Inc.",
-		CurrentPrice: 150.0,
+		CurrentPrice: money(150.0),
 		Amount:       1000,
 	}
 
 	stock2 := &models.Stock{
 		ID:           "2",
 		Name:         "Microsoft Corp.",
-		CurrentPrice: 300.0,
+		CurrentPrice: money(300.0),
 		Amount:       500,
 	}
 
-	exchange.stocks["1"] = stock1
-	exchange.stocks["2"] = stock2
-	exchange.buyOrders["1"] = make([]*models.Order, 0)
-	exchange.sellOrders["1"] = make([]*models.Order, 0)
-	exchange.buyOrders["2"] = make([]*models.Order, 0)
-	exchange.sellOrders["2"] = make([]*models.Order, 0)
+	exchange.addStock(stock1)
+	exchange.addStock(stock2)
 
 	// Add test traders
-	trader1 := models.NewTrader("trader1", "John Doe", 10000.0)
-	trader2 := models.NewTrader("trader2", "Jane Smith", 15000.0)
+	trader1 := models.NewTrader("trader1", "John Doe", money(10000.0))
+	trader2 := models.NewTrader("trader2", "Jane Smith", money(15000.0))
 
 	exchange.traders["trader1"] = trader1
 	exchange.traders["trader2"] = trader2
@@ -50,13 +49,23 @@ func createTestOrder(id, traderID, stockID string, orderType models.OrderType, p
 		TraderID:  traderID,
 		StockID:   stockID,
 		Type:      orderType,
-		Price:     price,
+		Price:     models.MoneyFromFloat(price),
 		Quantity:  quantity,
 		Status:    models.Open,
 		CreatedAt: time.Now(),
 	}
 }
 
+// money converts a dollar amount to models.Money
+func money(amount float64) models.Money {
+	return models.MoneyFromFloat(amount)
+}
+
+// transactionLog returns every transaction in the exchange's store, oldest first
+func transactionLog(e *Exchange) []models.Transaction {
+	return e.store.(*storage.MemoryStore).Dump(math.MaxUint64, 0).Transactions
+}
+
 // Test NewExchange
 func TestNewExchange(t *testing.T) {
 	exchange := NewExchange()
@@ -73,16 +82,12 @@ func TestNewExchange(t *testing.T) {
 		t.Error("traders map not initialized")
 	}
 
-	if exchange.buyOrders == nil {
-		t.Error("buyOrders map not initialized")
+	if exchange.books == nil {
+		t.Error("books map not initialized")
 	}
 
-	if exchange.sellOrders == nil {
-		t.Error("sellOrders map not initialized")
-	}
-
-	if exchange.transactions == nil {
-		t.Error("transactions slice not initialized")
+	if exchange.store == nil {
+		t.Error("store not initialized")
 	}
 
 	if exchange.subscriptions == nil {
@@ -102,8 +107,8 @@ func TestPlaceOrder_ValidOrders(t *testing.T) {
 	}
 
 	// Check if order was added to buy orders
-	if len(exchange.buyOrders["1"]) != 1 {
-		t.Errorf("Expected 1 buy order, got %d", len(exchange.buyOrders["1"]))
+	if len(exchange.books["1"].Bids()) != 1 {
+		t.Errorf("Expected 1 buy order, got %d", len(exchange.books["1"].Bids()))
 	}
 
 	// Give trader some shares first
@@ -117,8 +122,8 @@ func TestPlaceOrder_ValidOrders(t *testing.T) {
 	}
 
 	// Check if order was added to sell orders
-	if len(exchange.sellOrders["1"]) != 1 {
-		t.Errorf("Expected 1 sell order, got %d", len(exchange.sellOrders["1"]))
+	if len(exchange.books["1"].Asks()) != 1 {
+		t.Errorf("Expected 1 sell order, got %d", len(exchange.books["1"].Asks()))
 	}
 }
 
@@ -215,8 +220,8 @@ func TestOrderMatching(t *testing.T) {
 	}
 
 	// Check if trade was executed
-	if len(exchange.transactions) != 1 {
-		t.Errorf("Expected 1 transaction, got %d", len(exchange.transactions))
+	if len(transactionLog(exchange)) != 1 {
+		t.Errorf("Expected 1 transaction, got %d", len(transactionLog(exchange)))
 	}
 
 	// Check if orders were filled
@@ -232,14 +237,14 @@ func TestOrderMatching(t *testing.T) {
 	buyer := exchange.traders["trader1"]
 	seller := exchange.traders["trader2"]
 
-	expectedBuyerMoney := 10000.0 - (150.0 * 10) // Initial money - purchase cost
+	expectedBuyerMoney := money(10000.0 - (140.0 * 10)) // Initial money - purchase cost at the resting ask
 	if buyer.Money != expectedBuyerMoney {
-		t.Errorf("Expected buyer money: %.2f, got: %.2f", expectedBuyerMoney, buyer.Money)
+		t.Errorf("Expected buyer money: %s, got: %s", expectedBuyerMoney, buyer.Money)
 	}
 
-	expectedSellerMoney := 15000.0 + (150.0 * 10) // Initial money + sale proceeds
+	expectedSellerMoney := money(15000.0 + (140.0 * 10)) // Initial money + sale proceeds
 	if seller.Money != expectedSellerMoney {
-		t.Errorf("Expected seller money: %.2f, got: %.2f", expectedSellerMoney, seller.Money)
+		t.Errorf("Expected seller money: %s, got: %s", expectedSellerMoney, seller.Money)
 	}
 
 	// Check holdings
@@ -278,13 +283,69 @@ func TestPartialOrderMatching(t *testing.T) {
 		t.Errorf("Expected buy order to be filled, got status: %v", buyOrder.Status)
 	}
 
-	if sellOrder.Status != models.Open {
-		t.Errorf("Expected sell order to remain open, got status: %v", sellOrder.Status)
+	if sellOrder.Status != models.PartiallyFilled {
+		t.Errorf("Expected sell order to be partially filled, got status: %v", sellOrder.Status)
 	}
 
 	if sellOrder.Quantity != 10 { // 20 - 10 executed
 		t.Errorf("Expected sell order quantity to be 10, got: %d", sellOrder.Quantity)
 	}
+
+	if sellOrder.OriginalQuantity != 20 || sellOrder.FilledQuantity != 10 {
+		t.Errorf("Expected 10 of 20 filled, got %d of %d", sellOrder.FilledQuantity, sellOrder.OriginalQuantity)
+	}
+}
+
+// Test Order Lifecycle - fills, average price and the order store
+func TestOrderLifecycle(t *testing.T) {
+	exchange := createTestExchange()
+
+	exchange.traders["trader2"].Holdings["1"] = 50
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 140.0, 5))
+	exchange.PlaceOrder(createTestOrder("sell2", "trader2", "1", models.Sell, 146.0, 15))
+
+	buyOrder := createTestOrder("buy1", "trader1", "1", models.Buy, 150.0, 10)
+	if err := exchange.PlaceOrder(buyOrder); err != nil {
+		t.Fatalf("Failed to place buy order: %v", err)
+	}
+
+	if buyOrder.OriginalQuantity != 10 || buyOrder.FilledQuantity != 10 || buyOrder.Quantity != 0 {
+		t.Errorf("Expected 10 of 10 filled with 0 remaining, got %d of %d with %d remaining",
+			buyOrder.FilledQuantity, buyOrder.OriginalQuantity, buyOrder.Quantity)
+	}
+
+	if buyOrder.AvgFillPrice != money(143.0) { // (140*5 + 146*5) / 10
+		t.Errorf("Expected average fill price 143.0, got %s", buyOrder.AvgFillPrice)
+	}
+
+	if len(buyOrder.Fills) != 2 {
+		t.Fatalf("Expected 2 fills, got %d", len(buyOrder.Fills))
+	}
+
+	for i, fill := range buyOrder.Fills {
+		if fill.TransactionID != transactionLog(exchange)[i].ID {
+			t.Errorf("Expected fill %d to reference transaction %s, got %s", i, transactionLog(exchange)[i].ID, fill.TransactionID)
+		}
+	}
+
+	if buyOrder.UpdatedAt.Before(buyOrder.CreatedAt) {
+		t.Error("Expected updatedAt to be stamped by the fills")
+	}
+
+	// Filled and cancelled orders leave the open orders but stay in the store
+	exchange.CancelOrder("sell2")
+	for _, id := range []string{"buy1", "sell1", "sell2"} {
+		if _, exists := exchange.GetOrder(id); !exists {
+			t.Errorf("Expected %s to be kept in the store", id)
+		}
+		if _, open := exchange.orders.get(id); open {
+			t.Errorf("Expected %s to be dropped from the open orders", id)
+		}
+	}
+
+	if sell2, _ := exchange.GetOrder("sell2"); sell2.Status != models.Cancelled || sell2.FilledQuantity != 5 {
+		t.Error("Expected sell2 to be kept as cancelled with 5 filled")
+	}
 }
 
 // Test Self-Trading Prevention
@@ -309,13 +370,13 @@ func TestSelfTradingPrevention(t *testing.T) {
 	}
 
 	// Check that no trade was executed
-	if len(exchange.transactions) != 0 {
-		t.Errorf("Expected 0 transactions (self-trading), got %d", len(exchange.transactions))
+	if len(transactionLog(exchange)) != 0 {
+		t.Errorf("Expected 0 transactions (self-trading), got %d", len(transactionLog(exchange)))
 	}
 
-	// Both orders should remain open
-	if buyOrder.Status != models.Open {
-		t.Errorf("Expected buy order to remain open, got status: %v", buyOrder.Status)
+	// By default the incoming order is cancelled and the resting one stays
+	if buyOrder.Status != models.Cancelled {
+		t.Errorf("Expected buy order to be cancelled, got status: %v", buyOrder.Status)
 	}
 
 	if sellOrder.Status != models.Open {
@@ -323,6 +384,157 @@ func TestSelfTradingPrevention(t *testing.T) {
 	}
 }
 
+// Test Self-Trade Prevention modes
+func TestSelfTradePreventionModes(t *testing.T) {
+	tests := []struct {
+		name              string
+		mode              models.SelfTradePrevention
+		buyQuantity       int
+		expectedBuy       models.OrderStatus
+		expectedBuyFilled int
+		expectedSell      models.OrderStatus
+		expectedSellQty   int
+		expectedTrades    int
+	}{
+		{"Cancel newest", models.CancelNewest, 10, models.Cancelled, 0, models.Open, 10, 0},
+		{"Cancel oldest", models.CancelOldest, 10, models.Filled, 10, models.Cancelled, 10, 1},
+		{"Cancel both", models.CancelBoth, 10, models.Cancelled, 0, models.Cancelled, 10, 0},
+		{"Decrement and cancel resting", models.DecrementAndCancel, 15, models.Filled, 5, models.Cancelled, 0, 1},
+		{"Decrement and cancel incoming", models.DecrementAndCancel, 4, models.Cancelled, 0, models.Open, 6, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exchange := createTestExchange()
+			exchange.traders["trader1"].Holdings["1"] = 50
+			exchange.traders["trader2"].Holdings["1"] = 50
+
+			// trader1's own ask is best, trader2's is behind it
+			exchange.PlaceOrder(createTestOrder("sell1", "trader1", "1", models.Sell, 140.0, 10))
+			exchange.PlaceOrder(createTestOrder("sell2", "trader2", "1", models.Sell, 145.0, 10))
+
+			buyOrder := createTestOrder("buy1", "trader1", "1", models.Buy, 150.0, tt.buyQuantity)
+			buyOrder.SelfTradePrevention = tt.mode
+			if err := exchange.PlaceOrder(buyOrder); err != nil {
+				t.Fatalf("Failed to place buy order: %v", err)
+			}
+
+			sellOrder, _ := exchange.GetOrder("sell1")
+			if buyOrder.Status != tt.expectedBuy || buyOrder.FilledQuantity != tt.expectedBuyFilled {
+				t.Errorf("Expected buy %v with %d filled, got %v with %d",
+					tt.expectedBuy, tt.expectedBuyFilled, buyOrder.Status, buyOrder.FilledQuantity)
+			}
+			if sellOrder.Status != tt.expectedSell || sellOrder.Quantity != tt.expectedSellQty {
+				t.Errorf("Expected sell %v with %d shares, got %v with %d",
+					tt.expectedSell, tt.expectedSellQty, sellOrder.Status, sellOrder.Quantity)
+			}
+			if len(transactionLog(exchange)) != tt.expectedTrades {
+				t.Errorf("Expected %d transactions, got %d", tt.expectedTrades, len(transactionLog(exchange)))
+			}
+			for _, tx := range transactionLog(exchange) {
+				if tx.BuyerID == tx.SellerID {
+					t.Error("Trader traded with themselves")
+				}
+			}
+
+			// Cancelled and decremented orders give their reservations back
+			trader := exchange.traders["trader1"]
+			if trader.ReservedCash != buyOrder.ReservedCash {
+				t.Errorf("Expected %s cash reserved, got %s", buyOrder.ReservedCash, trader.ReservedCash)
+			}
+			if trader.ReservedShares["1"] != sellOrder.ReservedShares {
+				t.Errorf("Expected %d shares reserved, got %d", sellOrder.ReservedShares, trader.ReservedShares["1"])
+			}
+		})
+	}
+}
+
+// Test Self-Trade Prevention - a decrement-and-cancel market buy is priced
+// past the trader's own ask, as matching trades past it
+func TestSelfTradePreventionDecrementMarketBuy(t *testing.T) {
+	tests := []struct {
+		name          string
+		money         float64
+		expectError   bool
+		expectedMoney float64
+	}{
+		{"Cannot pay for the ask behind", 0, true, 0},
+		{"Pays for the ask behind", 505.0, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exchange := createTestExchange()
+			trader := exchange.traders["trader1"]
+			trader.Money = money(tt.money)
+			trader.Holdings["1"] = 5
+			exchange.traders["trader2"].Holdings["1"] = 5
+
+			exchange.PlaceOrder(createTestOrder("sell1", "trader1", "1", models.Sell, 100.0, 5))
+			exchange.PlaceOrder(createTestOrder("sell2", "trader2", "1", models.Sell, 101.0, 5))
+
+			buyOrder := createTestOrder("buy1", "trader1", "1", models.Buy, 0, 10)
+			buyOrder.Kind = models.Market
+			buyOrder.SelfTradePrevention = models.DecrementAndCancel
+			err := exchange.PlaceOrder(buyOrder)
+			if tt.expectError != (err != nil) {
+				t.Fatalf("Expected error %v, got %v", tt.expectError, err)
+			}
+
+			if trader.Money != money(tt.expectedMoney) || trader.Money < 0 {
+				t.Errorf("Expected trader1 to have %.2f, got %s", tt.expectedMoney, trader.Money)
+			}
+			if trader.ReservedCash != 0 {
+				t.Errorf("Expected no cash left reserved, got %s", trader.ReservedCash)
+			}
+			if !tt.expectError && buyOrder.FilledQuantity != 5 {
+				t.Errorf("Expected 5 shares bought from trader2, got %d", buyOrder.FilledQuantity)
+			}
+		})
+	}
+
+	// A fill-or-kill order counts the shares decremented against its own ask
+	exchange := createTestExchange()
+	exchange.traders["trader1"].Holdings["1"] = 5
+	exchange.traders["trader2"].Holdings["1"] = 5
+	exchange.PlaceOrder(createTestOrder("sell1", "trader1", "1", models.Sell, 100.0, 5))
+	exchange.PlaceOrder(createTestOrder("sell2", "trader2", "1", models.Sell, 101.0, 5))
+	fok := createTestOrder("buy1", "trader1", "1", models.Buy, 101.0, 10)
+	fok.TimeInForce = models.FOK
+	fok.SelfTradePrevention = models.DecrementAndCancel
+	exchange.PlaceOrder(fok)
+	if fok.FilledQuantity != 5 {
+		t.Errorf("Expected the fill-or-kill order to trade 5 shares past its own ask, got %d (%v)", fok.FilledQuantity, fok.Status)
+	}
+}
+
+// Test Self-Trade Prevention - orders take the trader's mode by default
+func TestSelfTradePreventionTraderDefault(t *testing.T) {
+	exchange := createTestExchange()
+
+	if err := exchange.SetSelfTradePrevention("trader1", models.CancelBoth); err != nil {
+		t.Fatalf("SetSelfTradePrevention failed: %v", err)
+	}
+	if err := exchange.SetSelfTradePrevention("trader1", "skip"); err == nil {
+		t.Error("Expected error for an invalid mode")
+	}
+	if err := exchange.SetSelfTradePrevention("missing", models.CancelBoth); err == nil {
+		t.Error("Expected error for an unknown trader")
+	}
+
+	order := createTestOrder("buy1", "trader1", "1", models.Buy, 100.0, 1)
+	exchange.PlaceOrder(order)
+	if order.SelfTradePrevention != models.CancelBoth {
+		t.Errorf("Expected trader default cancel_both, got %v", order.SelfTradePrevention)
+	}
+
+	order = createTestOrder("buy2", "trader2", "1", models.Buy, 100.0, 1)
+	exchange.PlaceOrder(order)
+	if order.SelfTradePrevention != models.DefaultSelfTradePrevention {
+		t.Errorf("Expected exchange default, got %v", order.SelfTradePrevention)
+	}
+}
+
 // Test Price Execution Logic
 func TestPriceExecution(t *testing.T) {
 	exchange := createTestExchange()
@@ -344,20 +556,850 @@ func TestPriceExecution(t *testing.T) {
 		t.Fatalf("Failed to place buy order: %v", err)
 	}
 
-	// Check that trade executed at buyer's price (150)
-	if len(exchange.transactions) != 1 {
-		t.Fatalf("Expected 1 transaction, got %d", len(exchange.transactions))
+	// Check that trade executed at the resting seller's price (140)
+	if len(transactionLog(exchange)) != 1 {
+		t.Fatalf("Expected 1 transaction, got %d", len(transactionLog(exchange)))
+	}
+
+	transaction := transactionLog(exchange)[0]
+	if transaction.Price != money(140.0) {
+		t.Errorf("Expected execution price 140.0, got %s", transaction.Price)
 	}
 
-	transaction := exchange.transactions[0]
-	if transaction.Price != 150.0 {
-		t.Errorf("Expected execution price 150.0, got %.2f", transaction.Price)
+	if transaction.AggressorSide != models.Buy {
+		t.Errorf("Expected buy side to be the aggressor, got %v", transaction.AggressorSide)
 	}
 
 	// Check stock price was updated
 	stock := exchange.stocks["1"]
-	if stock.CurrentPrice != 150.0 {
-		t.Errorf("Expected stock price to be updated to 150.0, got %.2f", stock.CurrentPrice)
+	if stock.CurrentPrice != money(140.0) {
+		t.Errorf("Expected stock price to be updated to 140.0, got %s", stock.CurrentPrice)
+	}
+}
+
+// Test Maker Price - an aggressive sell hitting a resting bid trades at the bid
+func TestMakerPriceAggressiveSell(t *testing.T) {
+	exchange := createTestExchange()
+
+	exchange.traders["trader2"].Holdings["1"] = 50
+
+	buyOrder := createTestOrder("buy1", "trader1", "1", models.Buy, 150.0, 10)
+	if err := exchange.PlaceOrder(buyOrder); err != nil {
+		t.Fatalf("Failed to place buy order: %v", err)
+	}
+
+	sellOrder := createTestOrder("sell1", "trader2", "1", models.Sell, 140.0, 10)
+	if err := exchange.PlaceOrder(sellOrder); err != nil {
+		t.Fatalf("Failed to place sell order: %v", err)
+	}
+
+	if len(transactionLog(exchange)) != 1 {
+		t.Fatalf("Expected 1 transaction, got %d", len(transactionLog(exchange)))
+	}
+
+	transaction := transactionLog(exchange)[0]
+	if transaction.Price != money(150.0) {
+		t.Errorf("Expected execution price 150.0, got %s", transaction.Price)
+	}
+
+	if transaction.AggressorSide != models.Sell {
+		t.Errorf("Expected sell side to be the aggressor, got %v", transaction.AggressorSide)
+	}
+
+	if transaction.BuyOrderID != "buy1" || transaction.SellOrderID != "sell1" {
+		t.Errorf("Expected transaction to reference buy1/sell1, got %s/%s", transaction.BuyOrderID, transaction.SellOrderID)
+	}
+}
+
+// Test Price Priority - the cheapest ask is taken first regardless of arrival order
+func TestPricePriority(t *testing.T) {
+	exchange := createTestExchange()
+
+	exchange.traders["trader2"].Holdings["1"] = 50
+
+	// Older, more expensive ask
+	expensive := createTestOrder("sell1", "trader2", "1", models.Sell, 148.0, 10)
+	if err := exchange.PlaceOrder(expensive); err != nil {
+		t.Fatalf("Failed to place sell order: %v", err)
+	}
+
+	// Newer, cheaper ask
+	cheap := createTestOrder("sell2", "trader2", "1", models.Sell, 142.0, 10)
+	if err := exchange.PlaceOrder(cheap); err != nil {
+		t.Fatalf("Failed to place sell order: %v", err)
+	}
+
+	buyOrder := createTestOrder("buy1", "trader1", "1", models.Buy, 150.0, 10)
+	if err := exchange.PlaceOrder(buyOrder); err != nil {
+		t.Fatalf("Failed to place buy order: %v", err)
+	}
+
+	if cheap.Status != models.Filled {
+		t.Errorf("Expected cheaper sell order to be filled, got status: %v", cheap.Status)
+	}
+
+	if expensive.Status != models.Open || expensive.Quantity != 10 {
+		t.Errorf("Expected expensive sell order to be untouched, got status %v and quantity %d", expensive.Status, expensive.Quantity)
+	}
+}
+
+// Test Time Priority - orders at the same price fill in arrival order
+func TestTimePriority(t *testing.T) {
+	exchange := createTestExchange()
+
+	exchange.traders["trader1"].Holdings["1"] = 20
+
+	first := createTestOrder("buy1", "trader2", "1", models.Buy, 145.0, 5)
+	second := createTestOrder("buy2", "trader2", "1", models.Buy, 145.0, 5)
+	for _, order := range []*models.Order{first, second} {
+		if err := exchange.PlaceOrder(order); err != nil {
+			t.Fatalf("Failed to place buy order: %v", err)
+		}
+	}
+
+	sellOrder := createTestOrder("sell1", "trader1", "1", models.Sell, 140.0, 7)
+	if err := exchange.PlaceOrder(sellOrder); err != nil {
+		t.Fatalf("Failed to place sell order: %v", err)
+	}
+
+	if first.Status != models.Filled {
+		t.Errorf("Expected first buy order to be filled, got status: %v", first.Status)
+	}
+
+	if second.Quantity != 3 {
+		t.Errorf("Expected second buy order to have 3 shares left, got: %d", second.Quantity)
+	}
+
+	bids := exchange.books["1"].Bids()
+	if len(bids) != 1 || bids[0].ID != "buy2" {
+		t.Errorf("Expected only buy2 to remain on the book, got %d orders", len(bids))
+	}
+}
+
+// Test Sweep - an aggressive order walks several price levels best first
+func TestSweepMultipleLevels(t *testing.T) {
+	exchange := createTestExchange()
+
+	exchange.traders["trader2"].Holdings["1"] = 50
+
+	prices := []float64{146.0, 144.0, 145.0}
+	for i, price := range prices {
+		order := createTestOrder(fmt.Sprintf("sell%d", i), "trader2", "1", models.Sell, price, 5)
+		if err := exchange.PlaceOrder(order); err != nil {
+			t.Fatalf("Failed to place sell order: %v", err)
+		}
+	}
+
+	buyOrder := createTestOrder("buy1", "trader1", "1", models.Buy, 145.0, 12)
+	if err := exchange.PlaceOrder(buyOrder); err != nil {
+		t.Fatalf("Failed to place buy order: %v", err)
+	}
+
+	// 144 and 145 are crossed, 146 is above the limit
+	if len(transactionLog(exchange)) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(transactionLog(exchange)))
+	}
+
+	// The remainder rests as the best bid
+	if best := exchange.books["1"].BestBid(); best == nil || best.ID != "buy1" || best.Quantity != 2 {
+		t.Errorf("Expected buy1 to rest with 2 shares as best bid")
+	}
+
+	if best := exchange.books["1"].BestAsk(); best == nil || best.Price != money(146.0) {
+		t.Errorf("Expected 146.0 to be the best ask")
+	}
+}
+
+// Test Market Order - sweeps asks best first and pays each resting price
+func TestMarketOrderSweepsBook(t *testing.T) {
+	exchange := createTestExchange()
+
+	exchange.traders["trader2"].Holdings["1"] = 50
+
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 141.0, 5))
+	exchange.PlaceOrder(createTestOrder("sell2", "trader2", "1", models.Sell, 140.0, 5))
+
+	marketOrder := createTestOrder("buy1", "trader1", "1", models.Buy, 0, 8)
+	marketOrder.Kind = models.Market
+	if err := exchange.PlaceOrder(marketOrder); err != nil {
+		t.Fatalf("Failed to place market order: %v", err)
+	}
+
+	if marketOrder.Status != models.Filled {
+		t.Errorf("Expected market order to be filled, got status: %v", marketOrder.Status)
+	}
+
+	expectedMoney := money(10000.0 - (140.0*5 + 141.0*3))
+	if money := exchange.traders["trader1"].Money; money != expectedMoney {
+		t.Errorf("Expected buyer money %s, got %s", expectedMoney, money)
+	}
+}
+
+// Test Market Order - unfilled remainder is cancelled instead of resting
+func TestMarketOrderRemainderCancelled(t *testing.T) {
+	exchange := createTestExchange()
+
+	exchange.traders["trader2"].Holdings["1"] = 50
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 140.0, 5))
+
+	marketOrder := createTestOrder("buy1", "trader1", "1", models.Buy, 0, 8)
+	marketOrder.Kind = models.Market
+	if err := exchange.PlaceOrder(marketOrder); err != nil {
+		t.Fatalf("Failed to place market order: %v", err)
+	}
+
+	if marketOrder.Status != models.Cancelled {
+		t.Errorf("Expected market order remainder to be cancelled, got status: %v", marketOrder.Status)
+	}
+
+	if marketOrder.Quantity != 3 {
+		t.Errorf("Expected 3 unfilled shares, got %d", marketOrder.Quantity)
+	}
+
+	if len(exchange.books["1"].Bids()) != 0 {
+		t.Error("Expected market order not to rest on the book")
+	}
+}
+
+// Test Market Order - buying power is checked against the cost of walking the book
+func TestMarketOrderValidation(t *testing.T) {
+	exchange := createTestExchange()
+
+	// No liquidity on the ask side
+	marketOrder := createTestOrder("buy1", "trader1", "1", models.Buy, 0, 10)
+	marketOrder.Kind = models.Market
+	if err := exchange.PlaceOrder(marketOrder); err == nil || err.Error() != "no liquidity available for market order" {
+		t.Errorf("Expected no liquidity error, got %v", err)
+	}
+
+	exchange.traders["trader2"].Holdings["1"] = 100
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 140.0, 100))
+
+	// 100 shares at 140 is more than trader1's 10000
+	marketOrder = createTestOrder("buy2", "trader1", "1", models.Buy, 0, 100)
+	marketOrder.Kind = models.Market
+	if err := exchange.PlaceOrder(marketOrder); err == nil || err.Error() != "insufficient funds" {
+		t.Errorf("Expected insufficient funds error, got %v", err)
+	}
+
+	// Unknown kinds are rejected
+	badOrder := createTestOrder("buy3", "trader1", "1", models.Buy, 140.0, 1)
+	badOrder.Kind = "stop-loss-ish"
+	if err := exchange.PlaceOrder(badOrder); err == nil {
+		t.Error("Expected error for invalid order kind")
+	}
+}
+
+// Test IOC - the unfilled remainder is cancelled instead of resting
+func TestImmediateOrCancel(t *testing.T) {
+	exchange := createTestExchange()
+
+	exchange.traders["trader2"].Holdings["1"] = 50
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 140.0, 5))
+
+	buyOrder := createTestOrder("buy1", "trader1", "1", models.Buy, 145.0, 8)
+	buyOrder.TimeInForce = models.IOC
+	if err := exchange.PlaceOrder(buyOrder); err != nil {
+		t.Fatalf("Failed to place IOC order: %v", err)
+	}
+
+	if buyOrder.Status != models.Cancelled || buyOrder.Quantity != 3 {
+		t.Errorf("Expected IOC order cancelled with 3 unfilled, got %v with %d", buyOrder.Status, buyOrder.Quantity)
+	}
+
+	if len(transactionLog(exchange)) != 1 {
+		t.Errorf("Expected 1 transaction, got %d", len(transactionLog(exchange)))
+	}
+
+	if len(exchange.books["1"].Bids()) != 0 {
+		t.Error("Expected IOC order not to rest on the book")
+	}
+}
+
+// Test FOK - killed without touching the book when it cannot fill in full
+func TestFillOrKill(t *testing.T) {
+	exchange := createTestExchange()
+
+	exchange.traders["trader2"].Holdings["1"] = 50
+	sellOrder := createTestOrder("sell1", "trader2", "1", models.Sell, 140.0, 5)
+	exchange.PlaceOrder(sellOrder)
+
+	killed := createTestOrder("buy1", "trader1", "1", models.Buy, 145.0, 8)
+	killed.TimeInForce = models.FOK
+	if err := exchange.PlaceOrder(killed); err != nil {
+		t.Fatalf("Failed to place FOK order: %v", err)
+	}
+
+	if killed.Status != models.Cancelled || killed.Quantity != 8 {
+		t.Errorf("Expected FOK order killed with 8 unfilled, got %v with %d", killed.Status, killed.Quantity)
+	}
+
+	if len(transactionLog(exchange)) != 0 || sellOrder.Quantity != 5 {
+		t.Error("Expected the book to be untouched by a killed FOK order")
+	}
+
+	filled := createTestOrder("buy2", "trader1", "1", models.Buy, 145.0, 5)
+	filled.TimeInForce = models.FOK
+	if err := exchange.PlaceOrder(filled); err != nil {
+		t.Fatalf("Failed to place FOK order: %v", err)
+	}
+
+	if filled.Status != models.Filled {
+		t.Errorf("Expected FOK order to fill, got %v", filled.Status)
+	}
+}
+
+// Test GTD/DAY - expired orders leave the book and subscribers are notified
+func TestExpireOrders(t *testing.T) {
+	exchange := createTestExchange()
+
+	expiresAt := time.Now().Add(time.Hour)
+	gtdOrder := createTestOrder("buy1", "trader1", "1", models.Buy, 100.0, 5)
+	gtdOrder.TimeInForce = models.GTD
+	gtdOrder.ExpiresAt = &expiresAt
+	if err := exchange.PlaceOrder(gtdOrder); err != nil {
+		t.Fatalf("Failed to place GTD order: %v", err)
+	}
+
+	dayOrder := createTestOrder("buy2", "trader1", "1", models.Buy, 100.0, 5)
+	dayOrder.TimeInForce = models.Day
+	if err := exchange.PlaceOrder(dayOrder); err != nil {
+		t.Fatalf("Failed to place DAY order: %v", err)
+	}
+
+	if dayOrder.ExpiresAt == nil || !dayOrder.ExpiresAt.After(time.Now()) {
+		t.Fatal("Expected DAY order to expire at the next session close")
+	}
+
+	sub := exchange.Subscribe()
+	defer exchange.Unsubscribe(sub)
+
+	// Nothing has expired yet
+	if expired := exchange.ExpireOrders(time.Now()); expired != 0 {
+		t.Errorf("Expected 0 expired orders, got %d", expired)
+	}
+
+	if expired := exchange.ExpireOrders(expiresAt); expired != 1 {
+		t.Errorf("Expected 1 expired order, got %d", expired)
+	}
+
+	if gtdOrder.Status != models.Expired {
+		t.Errorf("Expected GTD order to be expired, got %v", gtdOrder.Status)
+	}
+
+	if len(exchange.books["1"].Bids()) != 1 {
+		t.Errorf("Expected only the DAY order to remain, got %d", len(exchange.books["1"].Bids()))
+	}
+
+	select {
+	case update := <-sub.GetChannel():
+		if update.Type != "orderExpired" {
+			t.Errorf("Expected update type 'orderExpired', got '%s'", update.Type)
+		}
+		// Only what the book showed is published, not the trader
+		if event, ok := update.Data.(OrderExpiredEvent); !ok || event.OrderID != gtdOrder.ID || event.Quantity != gtdOrder.Quantity {
+			t.Errorf("Expected the GTD order's public fields, got %+v", update.Data)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected an orderExpired update")
+	}
+
+	exchange.ExpireOrders(*dayOrder.ExpiresAt)
+	if dayOrder.Status != models.Expired {
+		t.Errorf("Expected DAY order to be expired at session close, got %v", dayOrder.Status)
+	}
+}
+
+// Test time-in-force validation
+func TestTimeInForceValidation(t *testing.T) {
+	exchange := createTestExchange()
+
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name          string
+		timeInForce   models.TimeInForce
+		expiresAt     *time.Time
+		expectedError string
+	}{
+		{"GTD without expiry", models.GTD, nil, "GTD orders require an expiresAt in the future"},
+		{"GTD in the past", models.GTD, &past, "GTD orders require an expiresAt in the future"},
+		{"GTC with expiry", models.GTC, &past, "expiresAt is only allowed for GTD orders"},
+		{"Unknown", "FOREVER", nil, "invalid time in force: FOREVER"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := createTestOrder("buy1", "trader1", "1", models.Buy, 100.0, 1)
+			order.TimeInForce = tt.timeInForce
+			order.ExpiresAt = tt.expiresAt
+
+			err := exchange.PlaceOrder(order)
+			if err == nil || err.Error() != tt.expectedError {
+				t.Errorf("Expected error '%s', got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+// Test Stop Order - a sell stop triggers on a trade through its stop and sweeps as a market order
+func TestStopOrderTriggeredByTrade(t *testing.T) {
+	exchange := createTestExchange()
+
+	exchange.traders["trader1"].Holdings["1"] = 10
+	exchange.traders["trader2"].Holdings["1"] = 10
+
+	// Resting bids for the stop to hit
+	exchange.PlaceOrder(createTestOrder("bid1", "trader2", "1", models.Buy, 139.0, 5))
+	exchange.PlaceOrder(createTestOrder("bid2", "trader2", "1", models.Buy, 138.0, 5))
+
+	stopOrder := createTestOrder("stop1", "trader1", "1", models.Sell, 0, 8)
+	stopOrder.Kind = models.Stop
+	stopOrder.StopPrice = money(140.0)
+	if err := exchange.PlaceOrder(stopOrder); err != nil {
+		t.Fatalf("Failed to place stop order: %v", err)
+	}
+
+	if stopOrder.IsTriggered() || len(transactionLog(exchange)) != 0 {
+		t.Fatal("Expected stop order to wait while the last price is above the stop")
+	}
+
+	// A separate sell trades at 139, through the stop
+	exchange.PlaceOrder(createTestOrder("sell1", "trader1", "1", models.Sell, 139.0, 1))
+
+	if !stopOrder.IsTriggered() {
+		t.Fatal("Expected stop order to trigger after a trade at 139")
+	}
+
+	// 4 left at 139, then 4 of the 5 at 138
+	if stopOrder.Status != models.Filled {
+		t.Errorf("Expected triggered stop to fill, got %v", stopOrder.Status)
+	}
+
+	if price := exchange.stocks["1"].GetPrice(); price != money(138.0) {
+		t.Errorf("Expected last price 138.0, got %s", price)
+	}
+}
+
+// Test Stop-Limit Order - triggered by a price update and rests as a limit order
+func TestStopLimitTriggeredByPriceUpdate(t *testing.T) {
+	exchange := createTestExchange()
+
+	stopOrder := createTestOrder("stop1", "trader1", "1", models.Buy, 161.0, 5)
+	stopOrder.Kind = models.StopLimit
+	stopOrder.StopPrice = money(160.0)
+	if err := exchange.PlaceOrder(stopOrder); err != nil {
+		t.Fatalf("Failed to place stop-limit order: %v", err)
+	}
+
+	orders := exchange.GetTraderOpenOrders("trader1")
+	if len(orders) != 1 || orders[0].ID != "stop1" {
+		t.Fatal("Expected the untriggered stop to show in the trader's open orders")
+	}
+
+	exchange.UpdatePrice("1", money(159.0))
+	if stopOrder.IsTriggered() {
+		t.Fatal("Expected stop-limit not to trigger below its stop")
+	}
+
+	exchange.UpdatePrice("1", money(160.5))
+	if !stopOrder.IsTriggered() {
+		t.Fatal("Expected stop-limit to trigger at 160.5")
+	}
+
+	if best := exchange.books["1"].BestBid(); best == nil || best.ID != "stop1" {
+		t.Error("Expected triggered stop-limit to rest as the best bid")
+	}
+}
+
+// Test Stop Cascade - one triggered stop moves the price and triggers the next, in stop order
+func TestStopCascade(t *testing.T) {
+	exchange := createTestExchange()
+
+	exchange.traders["trader1"].Holdings["1"] = 20
+	exchange.traders["trader2"].Holdings["1"] = 20
+
+	exchange.PlaceOrder(createTestOrder("bid1", "trader2", "1", models.Buy, 145.0, 5))
+	exchange.PlaceOrder(createTestOrder("bid2", "trader2", "1", models.Buy, 140.0, 5))
+	exchange.PlaceOrder(createTestOrder("bid3", "trader2", "1", models.Buy, 135.0, 5))
+
+	first := createTestOrder("stop1", "trader1", "1", models.Sell, 0, 10)
+	first.Kind = models.Stop
+	first.StopPrice = money(146.0)
+	second := createTestOrder("stop2", "trader1", "1", models.Sell, 0, 5)
+	second.Kind = models.Stop
+	second.StopPrice = money(141.0)
+	for _, order := range []*models.Order{second, first} {
+		if err := exchange.PlaceOrder(order); err != nil {
+			t.Fatalf("Failed to place stop order: %v", err)
+		}
+	}
+
+	// Price drop to 146 fires stop1, which sweeps 145 and 140 and so fires stop2
+	exchange.UpdatePrice("1", money(146.0))
+
+	if first.Status != models.Filled || second.Status != models.Filled {
+		t.Fatalf("Expected both stops to fill, got %v and %v", first.Status, second.Status)
+	}
+
+	if len(transactionLog(exchange)) != 3 {
+		t.Fatalf("Expected 3 transactions, got %d", len(transactionLog(exchange)))
+	}
+
+	expectedSellers := []string{"stop1", "stop1", "stop2"}
+	for i, tx := range transactionLog(exchange) {
+		if tx.SellOrderID != expectedSellers[i] {
+			t.Errorf("Expected transaction %d to come from %s, got %s", i, expectedSellers[i], tx.SellOrderID)
+		}
+	}
+
+	if transactionLog(exchange)[2].Price != money(135.0) {
+		t.Errorf("Expected second stop to trade at 135, got %s", transactionLog(exchange)[2].Price)
+	}
+}
+
+// Test Stop Order validation and cancellation
+func TestStopOrderValidationAndCancel(t *testing.T) {
+	exchange := createTestExchange()
+
+	noStop := createTestOrder("stop1", "trader1", "1", models.Buy, 0, 5)
+	noStop.Kind = models.Stop
+	if err := exchange.PlaceOrder(noStop); err == nil || err.Error() != "stop price must be greater than 0" {
+		t.Errorf("Expected stop price error, got %v", err)
+	}
+
+	limitWithStop := createTestOrder("limit1", "trader1", "1", models.Buy, 150.0, 5)
+	limitWithStop.StopPrice = money(155.0)
+	if err := exchange.PlaceOrder(limitWithStop); err == nil {
+		t.Error("Expected error for a stop price on a limit order")
+	}
+
+	stopOrder := createTestOrder("stop2", "trader1", "1", models.Buy, 0, 5)
+	stopOrder.Kind = models.Stop
+	stopOrder.StopPrice = money(170.0)
+	if err := exchange.PlaceOrder(stopOrder); err != nil {
+		t.Fatalf("Failed to place stop order: %v", err)
+	}
+
+	if err := exchange.CancelOrder("stop2"); err != nil {
+		t.Fatalf("Failed to cancel stop order: %v", err)
+	}
+
+	exchange.UpdatePrice("1", money(175.0))
+	if stopOrder.IsTriggered() || stopOrder.Status != models.Cancelled {
+		t.Error("Expected cancelled stop order never to trigger")
+	}
+}
+
+// Test AmendOrder - a quantity decrease keeps queue priority
+func TestAmendOrder_DecreaseKeepsPriority(t *testing.T) {
+	exchange := createTestExchange()
+
+	exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 145.0, 10))
+	exchange.PlaceOrder(createTestOrder("buy2", "trader2", "1", models.Buy, 145.0, 10))
+
+	amended, err := exchange.AmendOrder("buy1", 0, 4)
+	if err != nil {
+		t.Fatalf("Failed to amend order: %v", err)
+	}
+
+	if amended.Quantity != 4 || amended.Price != money(145.0) {
+		t.Errorf("Expected 4 shares at 145.0, got %d at %s", amended.Quantity, amended.Price)
+	}
+
+	if best := exchange.books["1"].BestBid(); best.ID != "buy1" {
+		t.Errorf("Expected buy1 to keep its place at the front, got %s", best.ID)
+	}
+}
+
+// Test AmendOrder - a quantity increase or price change sends the order to the back
+func TestAmendOrder_LosesPriority(t *testing.T) {
+	exchange := createTestExchange()
+
+	exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 145.0, 10))
+	exchange.PlaceOrder(createTestOrder("buy2", "trader2", "1", models.Buy, 145.0, 10))
+
+	if _, err := exchange.AmendOrder("buy1", 0, 12); err != nil {
+		t.Fatalf("Failed to amend order: %v", err)
+	}
+
+	bids := exchange.books["1"].Bids()
+	if bids[0].ID != "buy2" || bids[1].ID != "buy1" {
+		t.Errorf("Expected buy1 behind buy2 after a quantity increase")
+	}
+
+	if _, err := exchange.AmendOrder("buy2", money(144.0), 0); err != nil {
+		t.Fatalf("Failed to amend order: %v", err)
+	}
+
+	bids = exchange.books["1"].Bids()
+	if bids[0].ID != "buy1" || bids[1].ID != "buy2" || bids[1].Price != money(144.0) {
+		t.Errorf("Expected buy2 to move to the 144.0 level behind buy1")
+	}
+}
+
+// Test AmendOrder - a new price that crosses the book trades immediately
+func TestAmendOrder_PriceCrosses(t *testing.T) {
+	exchange := createTestExchange()
+
+	exchange.traders["trader2"].Holdings["1"] = 10
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 150.0, 10))
+	exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 145.0, 10))
+
+	amended, err := exchange.AmendOrder("buy1", money(150.0), 0)
+	if err != nil {
+		t.Fatalf("Failed to amend order: %v", err)
+	}
+
+	if amended.Status != models.Filled {
+		t.Errorf("Expected amended order to fill, got %v", amended.Status)
+	}
+
+	if len(transactionLog(exchange)) != 1 || transactionLog(exchange)[0].AggressorSide != models.Buy {
+		t.Error("Expected the amended buy to trade as the aggressor")
+	}
+}
+
+// Test AmendOrder - funds and holdings are re-validated
+func TestAmendOrder_Validation(t *testing.T) {
+	exchange := createTestExchange()
+
+	exchange.traders["trader2"].Holdings["1"] = 10
+	exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 100.0, 10))
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 200.0, 10))
+
+	if _, err := exchange.AmendOrder("buy1", 0, 1000); err == nil || err.Error() != "insufficient funds" {
+		t.Errorf("Expected insufficient funds, got %v", err)
+	}
+
+	if _, err := exchange.AmendOrder("sell1", 0, 11); err == nil {
+		t.Error("Expected insufficient holdings when increasing a sell beyond holdings")
+	}
+
+	if _, err := exchange.AmendOrder("sell1", 0, 10); err != nil {
+		t.Errorf("Expected amending to the same quantity to pass the holdings check: %v", err)
+	}
+
+	if _, err := exchange.AmendOrder("missing", money(100.0), 0); err == nil {
+		t.Error("Expected error for an unknown order")
+	}
+
+	if _, err := exchange.AmendOrder("buy1", 0, 0); err == nil {
+		t.Error("Expected error when nothing is amended")
+	}
+}
+
+// Test tick size, lot size and quantity limits on new and amended orders
+func TestTradingRules(t *testing.T) {
+	exchange := createTestExchange()
+	stock := exchange.stocks["1"]
+	stock.LotSize = 10
+	stock.MinQuantity = 10
+	stock.MaxQuantity = 100
+
+	tests := []struct {
+		name     string
+		price    float64
+		quantity int
+		valid    bool
+	}{
+		{"on tick and lot", 140.05, 20, true},
+		{"price between ticks", 140.02, 20, false},
+		{"quantity not a whole lot", 140.0, 15, false},
+		{"quantity above max", 140.0, 110, false},
+	}
+	for _, tt := range tests {
+		err := exchange.PlaceOrder(createTestOrder(tt.name, "trader1", "1", models.Buy, tt.price, tt.quantity))
+		if tt.valid && err != nil {
+			t.Errorf("%s: expected order to be accepted, got %v", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%s: expected order to be rejected", tt.name)
+		}
+	}
+
+	// Below $100 the default table allows whole cents
+	exchange.traders["trader2"].Holdings["1"] = 10
+	if err := exchange.PlaceOrder(createTestOrder("cheap", "trader2", "1", models.Sell, 99.99, 10)); err != nil {
+		t.Errorf("Expected a cent price below $100 to be accepted: %v", err)
+	}
+
+	stop := createTestOrder("stop1", "trader1", "1", models.Buy, 0, 10)
+	stop.Kind = models.Stop
+	stop.StopPrice = money(160.03)
+	if err := exchange.PlaceOrder(stop); err == nil {
+		t.Error("Expected a stop price between ticks to be rejected")
+	}
+
+	if _, err := exchange.AmendOrder("on tick and lot", money(141.01), 0); err == nil {
+		t.Error("Expected amending to a price between ticks to be rejected")
+	}
+	if _, err := exchange.AmendOrder("on tick and lot", 0, 25); err == nil {
+		t.Error("Expected amending to a partial lot to be rejected")
+	}
+	if _, err := exchange.AmendOrder("on tick and lot", money(141.0), 30); err != nil {
+		t.Errorf("Expected a valid amendment to be accepted: %v", err)
+	}
+}
+
+// Test iceberg orders - each peak trades, then rejoins the back of the queue
+func TestIcebergOrder_Refresh(t *testing.T) {
+	exchange := createTestExchange()
+	exchange.traders["trader2"].Holdings["1"] = 100
+	trader3 := models.NewTrader("trader3", "Bob Brown", money(10000.0))
+	trader3.Holdings["1"] = 100
+	exchange.traders["trader3"] = trader3
+
+	iceberg := createTestOrder("ice1", "trader2", "1", models.Sell, 150.0, 30)
+	iceberg.DisplayQuantity = 10
+	if err := exchange.PlaceOrder(iceberg); err != nil {
+		t.Fatalf("Failed to place iceberg order: %v", err)
+	}
+	exchange.PlaceOrder(createTestOrder("sell2", "trader3", "1", models.Sell, 150.0, 5))
+
+	// The buy takes the 10 share peak, then sell2 now ahead of the new peak
+	exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 150.0, 12))
+	if len(transactionLog(exchange)) != 2 {
+		t.Fatalf("Expected 2 trades, got %d", len(transactionLog(exchange)))
+	}
+	if transactionLog(exchange)[0].SellOrderID != "ice1" || transactionLog(exchange)[0].Quantity != 10 {
+		t.Errorf("Expected the first trade to take the iceberg's peak, got %d from %s",
+			transactionLog(exchange)[0].Quantity, transactionLog(exchange)[0].SellOrderID)
+	}
+	if transactionLog(exchange)[1].SellOrderID != "sell2" || transactionLog(exchange)[1].Quantity != 2 {
+		t.Errorf("Expected the refreshed peak to lose priority to sell2, got %d from %s",
+			transactionLog(exchange)[1].Quantity, transactionLog(exchange)[1].SellOrderID)
+	}
+	if iceberg.Quantity != 20 || iceberg.Displayed != 10 {
+		t.Errorf("Expected 20 shares left with 10 displayed, got %d with %d displayed", iceberg.Quantity, iceberg.Displayed)
+	}
+
+	// A large buy works through every peak in turn
+	exchange.PlaceOrder(createTestOrder("buy2", "trader1", "1", models.Buy, 150.0, 23))
+	if iceberg.Status != models.Filled || exchange.books["1"].Len() != 0 {
+		t.Errorf("Expected the iceberg and sell2 to be filled, got %v with %d orders left", iceberg.Status, exchange.books["1"].Len())
+	}
+}
+
+// Test iceberg orders - market data only shows the displayed peak
+func TestIcebergOrder_PublicView(t *testing.T) {
+	exchange := createTestExchange()
+
+	iceberg := createTestOrder("ice1", "trader1", "1", models.Buy, 140.0, 50)
+	iceberg.DisplayQuantity = 5
+	exchange.PlaceOrder(iceberg)
+
+	orders := exchange.GetOpenOrders("1")
+	if len(orders) != 1 {
+		t.Fatalf("Expected 1 open order, got %d", len(orders))
+	}
+	public := orders[0]
+	if public.Quantity != 5 || public.OriginalQuantity != 5 || public.DisplayQuantity != 0 || public.ReservedCash != 0 {
+		t.Errorf("Expected only the 5 share peak to be shown, got %+v", public)
+	}
+
+	// The trader still sees the whole order
+	own, _ := exchange.GetOrder("ice1")
+	if own.Quantity != 50 || own.DisplayQuantity != 5 {
+		t.Errorf("Expected the owner to see all 50 shares, got %d", own.Quantity)
+	}
+}
+
+// Test iceberg orders - validation
+func TestIcebergOrder_Validation(t *testing.T) {
+	exchange := createTestExchange()
+	exchange.stocks["1"].LotSize = 10
+
+	tests := []struct {
+		name        string
+		quantity    int
+		display     int
+		timeInForce models.TimeInForce
+	}{
+		{"display not below quantity", 100, 100, models.GTC},
+		{"display not a whole lot", 100, 15, models.GTC},
+		{"immediate order", 100, 10, models.IOC},
+	}
+	for _, tt := range tests {
+		order := createTestOrder(tt.name, "trader1", "1", models.Buy, 140.0, tt.quantity)
+		order.DisplayQuantity = tt.display
+		order.TimeInForce = tt.timeInForce
+		if err := exchange.PlaceOrder(order); err == nil {
+			t.Errorf("%s: expected the iceberg order to be rejected", tt.name)
+		}
+	}
+}
+
+// Test post-only orders - rejected or repriced when they would take liquidity
+func TestPostOnlyOrder(t *testing.T) {
+	exchange := createTestExchange()
+	exchange.traders["trader2"].Holdings["1"] = 100
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 150.0, 10))
+
+	reject := createTestOrder("buy1", "trader1", "1", models.Buy, 150.0, 5)
+	reject.PostOnly = models.PostOnlyReject
+	if err := exchange.PlaceOrder(reject); err == nil {
+		t.Error("Expected a crossing post-only order to be rejected")
+	}
+
+	reprice := createTestOrder("buy2", "trader1", "1", models.Buy, 155.0, 5)
+	reprice.PostOnly = models.PostOnlyReprice
+	if err := exchange.PlaceOrder(reprice); err != nil {
+		t.Fatalf("Expected a crossing post-only order to be repriced: %v", err)
+	}
+	if reprice.Price != money(149.95) || reprice.Status != models.Open || len(transactionLog(exchange)) != 0 {
+		t.Errorf("Expected buy2 to rest at 149.95 without trading, got %v at %s", reprice.Status, reprice.Price)
+	}
+	if reserved := exchange.traders["trader1"].ReservedCash; reserved != money(5*149.95) {
+		t.Errorf("Expected the reservation to follow the new price, got %s", reserved)
+	}
+
+	passive := createTestOrder("buy3", "trader1", "1", models.Buy, 149.0, 5)
+	passive.PostOnly = models.PostOnlyReject
+	if err := exchange.PlaceOrder(passive); err != nil {
+		t.Errorf("Expected a passive post-only order to be accepted: %v", err)
+	}
+	if _, err := exchange.AmendOrder("buy3", money(151.0), 0); err == nil {
+		t.Error("Expected amending a post-only order across the book to be rejected")
+	}
+
+	market := createTestOrder("buy4", "trader1", "1", models.Buy, 0, 5)
+	market.Kind = models.Market
+	market.PostOnly = models.PostOnlyReject
+	if err := exchange.PlaceOrder(market); err == nil {
+		t.Error("Expected a post-only market order to be rejected")
+	}
+}
+
+// Test hidden orders - not shown on the book and behind displayed orders
+func TestHiddenOrder(t *testing.T) {
+	exchange := createTestExchange()
+	exchange.traders["trader2"].Holdings["1"] = 100
+
+	hidden := createTestOrder("hidden1", "trader1", "1", models.Buy, 150.0, 5)
+	hidden.Hidden = true
+	if err := exchange.PlaceOrder(hidden); err != nil {
+		t.Fatalf("Failed to place hidden order: %v", err)
+	}
+	exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 150.0, 5))
+
+	orders := exchange.GetOpenOrders("1")
+	if len(orders) != 1 || orders[0].ID != "buy1" {
+		t.Errorf("Expected only the displayed order on the book, got %d orders", len(orders))
+	}
+
+	// The displayed order trades first even though it arrived later
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 150.0, 7))
+	buy1, _ := exchange.GetOrder("buy1")
+	if buy1.Status != models.Filled || hidden.FilledQuantity != 2 {
+		t.Errorf("Expected buy1 filled before the hidden order, got %v and %d", buy1.Status, hidden.FilledQuantity)
+	}
+
+	iceberg := createTestOrder("hidden2", "trader1", "1", models.Buy, 140.0, 10)
+	iceberg.Hidden = true
+	iceberg.DisplayQuantity = 5
+	if err := exchange.PlaceOrder(iceberg); err == nil {
+		t.Error("Expected a hidden iceberg order to be rejected")
 	}
 }
 
@@ -379,8 +1421,8 @@ func TestCancelOrder(t *testing.T) {
 	}
 
 	// Check that order was removed from order book
-	if len(exchange.buyOrders["1"]) != 0 {
-		t.Errorf("Expected 0 buy orders after cancellation, got %d", len(exchange.buyOrders["1"]))
+	if len(exchange.books["1"].Bids()) != 0 {
+		t.Errorf("Expected 0 buy orders after cancellation, got %d", len(exchange.books["1"].Bids()))
 	}
 
 	// Try to cancel non-existent order
@@ -419,6 +1461,12 @@ func TestGetStock(t *testing.T) {
 		t.Errorf("Expected stock ID '1', got '%s'", stock.ID)
 	}
 
+	// The stock is a copy
+	stock.SetPrice(money(1.0))
+	if exchange.stocks["1"].GetPrice() != money(150.0) {
+		t.Error("Expected changing the returned stock to leave the exchange's alone")
+	}
+
 	// Test non-existent stock
 	_, exists = exchange.GetStock("999")
 	if exists {
@@ -426,6 +1474,38 @@ func TestGetStock(t *testing.T) {
 	}
 }
 
+// Test stock reads while the exchange sells its supply (run with -race)
+func TestConcurrentStockReads(t *testing.T) {
+	exchange := createTestExchange()
+	supply := createTestOrder("init-1", "exchange", "1", models.Sell, 150.0, 1000)
+	unlock := exchange.lockStock("1")
+	if err := exchange.accept(supply); err != nil {
+		t.Fatalf("Failed to list the supply: %v", err)
+	}
+	exchange.rest(supply)
+	unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			exchange.PlaceOrder(createTestOrder(fmt.Sprintf("buy%d", i), "trader1", "1", models.Buy, 150.0, 1))
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		if _, err := json.Marshal(exchange.GetAllStocks()); err != nil {
+			t.Fatalf("Failed to marshal stocks: %v", err)
+		}
+	}
+	wg.Wait()
+
+	if stock, _ := exchange.GetStock("1"); stock.Amount != 980 {
+		t.Errorf("Expected the exchange to hold 980 shares after selling 20, got %d", stock.Amount)
+	}
+}
+
 // Test Concurrent Order Placement (Race Conditions)
 func TestConcurrentOrderPlacement(t *testing.T) {
 	exchange := createTestExchange()
@@ -481,9 +1561,62 @@ func TestConcurrentOrderPlacement(t *testing.T) {
 	}
 
 	// Verify that some trades occurred
-	if len(exchange.transactions) == 0 {
+	if len(transactionLog(exchange)) == 0 {
+		t.Error("Expected some transactions from concurrent orders")
+	}
+}
+
+// Test Concurrent Order Placement - parallel matching on several stocks keeps accounts consistent
+func TestConcurrentOrderPlacement_MultipleStocks(t *testing.T) {
+	exchange := createTestExchange()
+
+	// Both traders trade both stocks at once
+	exchange.traders["trader1"].Holdings["1"] = 100
+	exchange.traders["trader1"].Holdings["2"] = 100
+	exchange.traders["trader2"].Holdings["1"] = 100
+	exchange.traders["trader2"].Holdings["2"] = 100
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		for _, stockID := range []string{"1", "2"} {
+			wg.Add(2)
+
+			go func(i int, stockID string) {
+				defer wg.Done()
+				exchange.PlaceOrder(createTestOrder(fmt.Sprintf("buy%s-%d", stockID, i), "trader1", stockID, models.Buy, 100.0, 2))
+			}(i, stockID)
+
+			go func(i int, stockID string) {
+				defer wg.Done()
+				exchange.PlaceOrder(createTestOrder(fmt.Sprintf("sell%s-%d", stockID, i), "trader2", stockID, models.Sell, 100.0, 2))
+			}(i, stockID)
+		}
+	}
+	wg.Wait()
+
+	trader1 := exchange.traders["trader1"]
+	trader2 := exchange.traders["trader2"]
+
+	// Every trade moves money and shares from one trader to the other
+	if trader1.Money+trader2.Money != money(25000.0) {
+		t.Errorf("Expected total money 25000, got %s", trader1.Money+trader2.Money)
+	}
+	for _, stockID := range []string{"1", "2"} {
+		if trader1.Holdings[stockID]+trader2.Holdings[stockID] != 200 {
+			t.Errorf("Expected 200 shares of %s in total, got %d", stockID, trader1.Holdings[stockID]+trader2.Holdings[stockID])
+		}
+	}
+
+	// All 40 buys and 40 sells pair up at the same price
+	if len(transactionLog(exchange)) == 0 {
 		t.Error("Expected some transactions from concurrent orders")
 	}
+	if trader1.Money != money(10000.0-8000.0) {
+		t.Errorf("Expected trader1 to have bought 80 shares at 100, money %s", trader1.Money)
+	}
+	if trader1.ReservedCash != 0 || len(trader2.ReservedShares) != 0 {
+		t.Errorf("Expected no reservations left, got %s cash and %v shares", trader1.ReservedCash, trader2.ReservedShares)
+	}
 }
 
 // Test WebSocket Subscription
@@ -497,11 +1630,11 @@ func TestWebSocketSubscription(t *testing.T) {
 	}
 
 	// Check that subscription was added
-	exchange.mu.RLock()
+	exchange.subMu.RLock()
 	if len(exchange.subscriptions) != 1 {
 		t.Errorf("Expected 1 subscription, got %d", len(exchange.subscriptions))
 	}
-	exchange.mu.RUnlock()
+	exchange.subMu.RUnlock()
 
 	// Wait for at least one update
 	select {
@@ -517,11 +1650,11 @@ func TestWebSocketSubscription(t *testing.T) {
 	exchange.Unsubscribe(sub)
 
 	// Check that subscription was removed
-	exchange.mu.RLock()
+	exchange.subMu.RLock()
 	if len(exchange.subscriptions) != 0 {
 		t.Errorf("Expected 0 subscriptions after unsubscribe, got %d", len(exchange.subscriptions))
 	}
-	exchange.mu.RUnlock()
+	exchange.subMu.RUnlock()
 }
 
 // Test Profit/Loss Calculation
@@ -534,17 +1667,17 @@ func TestCalculateProfitLoss(t *testing.T) {
 
 	// Calculate P&L (should be 0 initially since portfolio value = initial money)
 	initialPL := exchange.CalculateProfitLoss("trader1")
-	expectedPL := (10000.0 + (10 * 150.0)) - 10000.0 // current portfolio - initial money
+	expectedPL := money((10000.0 + (10 * 150.0)) - 10000.0) // current portfolio - initial money
 	if initialPL != expectedPL {
-		t.Errorf("Expected P&L %.2f, got %.2f", expectedPL, initialPL)
+		t.Errorf("Expected P&L %s, got %s", expectedPL, initialPL)
 	}
 
 	// Change stock price and recalculate
-	exchange.stocks["1"].SetPrice(200.0)
+	exchange.stocks["1"].SetPrice(money(200.0))
 	newPL := exchange.CalculateProfitLoss("trader1")
-	expectedNewPL := (10000.0 + (10 * 200.0)) - 10000.0
+	expectedNewPL := money((10000.0 + (10 * 200.0)) - 10000.0)
 	if newPL != expectedNewPL {
-		t.Errorf("Expected P&L %.2f after price change, got %.2f", expectedNewPL, newPL)
+		t.Errorf("Expected P&L %s after price change, got %s", expectedNewPL, newPL)
 	}
 }
 
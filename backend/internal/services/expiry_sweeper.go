@@ -0,0 +1,41 @@
+package services
+
+import (
+	"log"
+	"time"
+)
+
+// ExpirySweeper periodically expires DAY and GTD orders that are past their expiry
+type ExpirySweeper struct {
+	exchange *Exchange
+	ticker   *time.Ticker
+	done     chan bool
+}
+
+func NewExpirySweeper(exchange *Exchange, interval time.Duration) *ExpirySweeper {
+	return &ExpirySweeper{
+		exchange: exchange,
+		ticker:   time.NewTicker(interval),
+		done:     make(chan bool),
+	}
+}
+
+func (es *ExpirySweeper) Start() {
+	go func() {
+		for {
+			select {
+			case now := <-es.ticker.C:
+				if expired := es.exchange.ExpireOrders(now); expired > 0 {
+					log.Printf("⏰ Expiry sweep completed. %d orders expired", expired)
+				}
+			case <-es.done:
+				return
+			}
+		}
+	}()
+}
+
+func (es *ExpirySweeper) Stop() {
+	es.ticker.Stop()
+	es.done <- true
+}
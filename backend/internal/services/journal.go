@@ -0,0 +1,416 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"stock-exchange/internal/models"
+	"sync"
+	"time"
+)
+
+// The journal is an append-only file with one JSON line per entry. Each
+// entry holds the events of one change to the exchange, so replaying the
+// entries in order rebuilds its state. Entries are written as they happen
+// and fsynced in batches: by a background syncer every sync interval, and
+// straight away once JournalBatchSize entries are waiting. A crash can lose
+// the last batch, but never leaves half an entry behind: a torn last line is
+// dropped when the journal is reopened.
+//
+// Once a snapshot covers the start of the journal, Truncate drops the entries
+// it covers. Sequence numbers carry on from the snapshot, so the first entry
+// left is the one after it.
+
+// JournalBatchSize is how many entries can wait for an fsync before the
+// writer syncs them itself
+const JournalBatchSize = 256
+
+// EventType says what changed in a journal event
+type EventType string
+
+const (
+	EventStockListed      EventType = "stockListed"      // Stock: a stock and its trading rules
+	EventPriceUpdate      EventType = "priceUpdate"      // StockState: the stock's price moved
+	EventStockStatus      EventType = "stockStatus"      // StockState: its status, amount or closing price changed
+	EventMarketPhase      EventType = "marketPhase"      // Phase: the market moved to a new phase
+	EventTraderRegistered EventType = "traderRegistered" // Trader
+	EventTraderUpdated    EventType = "traderUpdated"    // Trader: the trader's settings changed
+	EventOrderAccepted    EventType = "orderAccepted"    // Order
+	EventOrderUpdated     EventType = "orderUpdated"     // Order: amended, triggered, reduced, requeued...
+	EventOrderCancelled   EventType = "orderCancelled"   // Order: cancelled or expired
+	EventFill             EventType = "fill"             // Transaction: the trade that filled two orders
+	EventValuation        EventType = "valuation"        // ValuedAt: every trader was valued at the last prices
+)
+
+// JournalEntry is one change to the exchange
+type JournalEntry struct {
+	Seq    uint64    `json:"seq"`
+	Time   time.Time `json:"time"`
+	Events []Event   `json:"events"`
+}
+
+// Event is one part of a change. Orders and stocks are journaled as they
+// are after the change; fills carry the transaction, which replay settles
+// between the two traders.
+type Event struct {
+	Type        EventType           `json:"type"`
+	Stock       *models.Stock       `json:"stock,omitempty"`
+	StockState  *models.StockState  `json:"stockState,omitempty"`
+	Phase       models.MarketPhase  `json:"phase,omitempty"`
+	Trader      *TraderRecord       `json:"trader,omitempty"`
+	Order       *OrderRecord        `json:"order,omitempty"`
+	Transaction *models.Transaction `json:"transaction,omitempty"`
+	ValuedAt    *time.Time          `json:"valuedAt,omitempty"`
+}
+
+// TraderRecord is a trader's account as it was opened, and its settings.
+// Cash and holdings follow from the fills, reservations from the open orders.
+type TraderRecord struct {
+	ID                  string                     `json:"id"`
+	Name                string                     `json:"name"`
+	InitialMoney        models.Money               `json:"initialMoney"`
+	SelfTradePrevention models.SelfTradePrevention `json:"selfTradePrevention,omitempty"`
+}
+
+// OrderRecord is an order with where the exchange keeps it
+type OrderRecord struct {
+	models.Order
+	ArrivalSeq uint64 `json:"arrival"`
+	QueueSeq   uint64 `json:"queued,omitempty"`
+}
+
+func newOrderRecord(order *models.Order) *OrderRecord {
+	return &OrderRecord{Order: *order, ArrivalSeq: order.Arrival(), QueueSeq: order.Queued()}
+}
+
+// Journal appends entries to a journal file
+type Journal struct {
+	path     string
+	file     *os.File
+	writer   *bufio.Writer
+	seq      uint64 // Of the last entry written
+	unsynced int
+	ticker   *time.Ticker
+	done     chan struct{}
+	mu       sync.Mutex
+}
+
+// OpenJournal opens the journal at path, creating it if needed, and starts
+// fsyncing what is appended every syncInterval
+func OpenJournal(path string, syncInterval time.Duration) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	seq, end, err := scanJournal(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Truncate(end); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(end, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	j := &Journal{
+		path:   path,
+		file:   file,
+		writer: bufio.NewWriter(file),
+		seq:    seq,
+		ticker: time.NewTicker(syncInterval),
+		done:   make(chan struct{}),
+	}
+	go j.syncLoop()
+	return j, nil
+}
+
+// scanJournal finds the sequence number of the last entry and where it
+// ends. Only the last line may be torn; a bad line before it is corruption.
+func scanJournal(file *os.File) (seq uint64, end int64, err error) {
+	reader := bufio.NewReader(file)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		var entry struct {
+			Seq uint64 `json:"seq"`
+		}
+		complete := readErr == nil && json.Unmarshal(line, &entry) == nil
+		if !complete {
+			if readErr == nil {
+				// A bad line is only a torn write if nothing follows it
+				if _, peekErr := reader.Peek(1); peekErr != io.EOF {
+					return 0, 0, fmt.Errorf("journal is corrupt after entry %d", seq)
+				}
+			} else if readErr != io.EOF {
+				return 0, 0, readErr
+			}
+			if len(line) > 0 {
+				log.Printf("Dropping a torn journal entry after entry %d", seq)
+			}
+			return seq, end, nil
+		}
+		seq = entry.Seq
+		end += int64(len(line))
+	}
+}
+
+// Seq returns the sequence number of the last entry written
+func (j *Journal) Seq() uint64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.seq
+}
+
+// Append writes an entry with the given events and returns its sequence
+// number
+func (j *Journal) Append(events ...Event) (uint64, error) {
+	encoded, err := json.Marshal(events)
+	if err != nil {
+		return 0, err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return 0, fmt.Errorf("journal is closed")
+	}
+	line, err := json.Marshal(struct {
+		Seq    uint64          `json:"seq"`
+		Time   time.Time       `json:"time"`
+		Events json.RawMessage `json:"events"`
+	}{j.seq + 1, time.Now(), encoded})
+	if err != nil {
+		return 0, err
+	}
+	if _, err := j.writer.Write(append(line, '\n')); err != nil {
+		return 0, err
+	}
+	j.seq++
+
+	j.unsynced++
+	if j.unsynced >= JournalBatchSize {
+		return j.seq, j.sync()
+	}
+	return j.seq, nil
+}
+
+// continueFrom makes the next entry follow seq when the journal holds
+// nothing after it, as after a truncation
+func (j *Journal) continueFrom(seq uint64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.seq = max(j.seq, seq)
+}
+
+// Replay calls apply for every entry after seq, in order. It reads the file
+// from the start, so it is meant for startup, before anything is appended.
+// Entries missing after seq are an error.
+func (j *Journal) Replay(after uint64, apply func(JournalEntry) error) error {
+	file, err := os.Open(j.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	next := after + 1
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Skip what the caller already has without decoding the events
+		var header struct {
+			Seq uint64 `json:"seq"`
+		}
+		if err := json.Unmarshal(line, &header); err != nil {
+			return err
+		}
+		if header.Seq <= after {
+			continue
+		}
+		if header.Seq != next {
+			return fmt.Errorf("journal is missing entries %d to %d", next, header.Seq-1)
+		}
+		next++
+
+		var entry JournalEntry
+		if err := json.NewDecoder(bytes.NewReader(line)).Decode(&entry); err != nil {
+			return fmt.Errorf("journal entry %d: %v", header.Seq, err)
+		}
+		if err := apply(entry); err != nil {
+			return fmt.Errorf("journal entry %d: %v", entry.Seq, err)
+		}
+	}
+}
+
+// Truncate drops the entries up to and including seq, rewriting the journal
+// file with the rest and swapping it in atomically
+func (j *Journal) Truncate(through uint64) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return fmt.Errorf("journal is closed")
+	}
+	if err := j.writer.Flush(); err != nil {
+		return err
+	}
+
+	// Find where the first entry to keep starts
+	if _, err := j.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(j.file)
+	var start int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var header struct {
+			Seq uint64 `json:"seq"`
+		}
+		if err := json.Unmarshal(line, &header); err != nil {
+			return err
+		}
+		if header.Seq > through {
+			break
+		}
+		start += int64(len(line))
+	}
+	if start == 0 {
+		_, err := j.file.Seek(0, io.SeekEnd)
+		return err
+	}
+
+	if _, err := j.file.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	err := writeFileAtomic(j.path, func(w io.Writer) error {
+		_, err := io.Copy(w, j.file)
+		return err
+	})
+
+	// Carry on appending to whichever file is at path now
+	file, openErr := os.OpenFile(j.path, os.O_RDWR|os.O_APPEND, 0o644)
+	if openErr != nil {
+		j.file.Seek(0, io.SeekEnd)
+		if err == nil {
+			err = openErr
+		}
+		return err
+	}
+	j.file.Close()
+	j.file = file
+	j.writer.Reset(file)
+	return err
+}
+
+// writeFileAtomic writes a file through a temporary file that is fsynced and
+// renamed over path, so path always holds either the old or the new content
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	err = write(writer)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// Make the rename itself durable
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// Sync writes out and fsyncs everything appended so far
+func (j *Journal) Sync() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return fmt.Errorf("journal is closed")
+	}
+	return j.sync()
+}
+
+func (j *Journal) sync() error {
+	if err := j.writer.Flush(); err != nil {
+		return err
+	}
+	if err := j.file.Sync(); err != nil {
+		return err
+	}
+	j.unsynced = 0
+	return nil
+}
+
+func (j *Journal) syncLoop() {
+	for {
+		select {
+		case <-j.ticker.C:
+			j.mu.Lock()
+			if j.file != nil && j.unsynced > 0 {
+				if err := j.sync(); err != nil {
+					log.Printf("Failed to sync the journal: %v", err)
+				}
+			}
+			j.mu.Unlock()
+		case <-j.done:
+			return
+		}
+	}
+}
+
+// Close syncs what is left and closes the journal
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return nil
+	}
+
+	j.ticker.Stop()
+	close(j.done)
+	err := j.sync()
+	if closeErr := j.file.Close(); err == nil {
+		err = closeErr
+	}
+	j.file = nil
+	return err
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"stock-exchange/internal/models"
+	"time"
+)
+
+// Linked orders are handled under their stock's lock like everything else on
+// the book, so the legs of a pair can never both fill.
+//
+// The two legs of a one-cancels-other (OCO) pair are for the same trader,
+// stock and quantity. Every fill on one leg takes the same quantity off the
+// other, and once either leg is done, filled or not, the other is cancelled.
+//
+// A bracket order is an entry order with a take-profit limit exit and a
+// stop-loss stop exit on the other side, which form an OCO pair. The exits
+// wait as pending orders until the entry has finished; they then go into the
+// market for the quantity the entry filled, or are cancelled if it filled
+// nothing. Activation is queued and run by processTriggers, so exits never
+// enter matching while their entry is still being matched.
+
+// exitQueue holds bracket entries whose exits are waiting to be activated,
+// guarded by the stock's lock
+type exitQueue struct {
+	entries []*models.Order
+}
+
+// PlaceOCO places two orders as a one-cancels-other pair. Both are accepted
+// or neither is.
+func (e *Exchange) PlaceOCO(first, second *models.Order) error {
+	for _, order := range []*models.Order{first, second} {
+		if err := e.validateOrder(order); err != nil {
+			return err
+		}
+		if !canWait(order) {
+			return fmt.Errorf("only orders that can rest on the book can be one-cancels-other legs")
+		}
+	}
+	if first.ID == second.ID {
+		return fmt.Errorf("duplicate order id: %s", first.ID)
+	}
+	if first.TraderID != second.TraderID || first.StockID != second.StockID {
+		return fmt.Errorf("one-cancels-other legs must be for the same trader and stock")
+	}
+	if first.Quantity != second.Quantity {
+		return fmt.Errorf("one-cancels-other legs must have the same quantity")
+	}
+
+	defer e.lockStock(first.StockID)()
+
+	models.LinkOCO(first, second)
+	for _, order := range []*models.Order{first, second} {
+		if err := e.checkEntry(order); err != nil {
+			return err
+		}
+	}
+
+	// The second leg's funds check counts what the first reserves. Both legs
+	// are the same trader's.
+	account := e.accountLock(first.TraderID)
+	account.Lock()
+	err := e.checkFunds(first)
+	if err == nil {
+		e.reserve(first)
+		if err = e.checkFunds(second); err == nil {
+			e.reserve(second)
+		} else {
+			e.release(first)
+		}
+	}
+	account.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := e.accept(first); err != nil {
+		account.Lock()
+		e.release(second)
+		account.Unlock()
+		return err
+	}
+	if err := e.accept(second); err != nil {
+		e.closeOrder(first, models.Cancelled)
+		return err
+	}
+	log.Printf("One-cancels-other orders %s and %s placed", first.ID, second.ID)
+
+	// The first leg may trade straight away and take the second with it
+	e.route(first)
+	if second.IsActive() {
+		e.route(second)
+	}
+
+	e.processTriggers(first.StockID)
+	e.publishIndicative(first.StockID)
+	return nil
+}
+
+// PlaceBracket places an entry order with a take-profit limit exit and a
+// stop-loss stop or stop-limit exit, which wait until the entry has filled
+func (e *Exchange) PlaceBracket(entry, takeProfit, stopLoss *models.Order) error {
+	if err := e.validateOrder(entry); err != nil {
+		return err
+	}
+	if err := e.checkExits(entry, takeProfit, stopLoss); err != nil {
+		return err
+	}
+
+	defer e.lockStock(entry.StockID)()
+
+	models.LinkOCO(takeProfit, stopLoss)
+	entry.AddExits(takeProfit, stopLoss)
+
+	if err := e.checkEntry(entry); err != nil {
+		return err
+	}
+	account := e.accountLock(entry.TraderID)
+	account.Lock()
+	err := e.checkFunds(entry)
+	if err == nil {
+		e.reserve(entry)
+	}
+	account.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := e.accept(entry); err != nil {
+		return err
+	}
+
+	// The exits reserve nothing until they are activated
+	for _, exit := range entry.Exits() {
+		exit.Status = models.Pending
+		if err := e.accept(exit); err != nil {
+			e.closeOrder(entry, models.Cancelled)
+			return err
+		}
+	}
+	log.Printf("Bracket order %s placed with take-profit %s at %s and stop-loss %s at %s",
+		entry.ID, takeProfit.ID, takeProfit.Price, stopLoss.ID, stopLoss.StopPrice)
+
+	e.route(entry)
+
+	e.processTriggers(entry.StockID)
+	e.publishIndicative(entry.StockID)
+	return nil
+}
+
+// checkExits validates the exits of a bracket order against its validated
+// entry: they close the entry's position on the other side, with the
+// take-profit beyond the stop-loss
+func (e *Exchange) checkExits(entry, takeProfit, stopLoss *models.Order) error {
+	if takeProfit.Kind != models.Limit && takeProfit.Kind != "" {
+		return fmt.Errorf("the take-profit exit must be a limit order")
+	}
+	if !stopLoss.IsStop() {
+		return fmt.Errorf("the stop-loss exit must be a stop or stop_limit order")
+	}
+
+	exitSide := models.Sell
+	if entry.Type == models.Sell {
+		exitSide = models.Buy
+	}
+	ids := map[string]bool{entry.ID: true}
+	for _, exit := range []*models.Order{takeProfit, stopLoss} {
+		if ids[exit.ID] {
+			return fmt.Errorf("duplicate order id: %s", exit.ID)
+		}
+		ids[exit.ID] = true
+		if exit.TraderID != entry.TraderID || exit.StockID != entry.StockID || exit.Type != exitSide {
+			return fmt.Errorf("bracket exits must close the entry: same trader and stock, opposite side")
+		}
+		if exit.Quantity != entry.Quantity {
+			return fmt.Errorf("bracket exits must have the entry's quantity")
+		}
+		if err := e.validateOrder(exit); err != nil {
+			return err
+		}
+		if !canWait(exit) {
+			return fmt.Errorf("bracket exits must be able to rest on the book")
+		}
+	}
+
+	// A trailing stop-loss has no stop price until it is activated
+	if stopLoss.IsTrailing() {
+		return nil
+	}
+	if entry.Type == models.Buy && takeProfit.Price <= stopLoss.StopPrice ||
+		entry.Type == models.Sell && takeProfit.Price >= stopLoss.StopPrice {
+		return fmt.Errorf("take-profit price %s must be beyond the stop-loss price %s", takeProfit.Price, stopLoss.StopPrice)
+	}
+	return nil
+}
+
+// canWait reports whether an order can wait on the order book or, for a stop
+// order, the trigger book
+func canWait(order *models.Order) bool {
+	if order.IsStop() {
+		return order.TimeInForce != models.IOC && order.TimeInForce != models.FOK
+	}
+	return !order.IsImmediate()
+}
+
+// fillLinked follows a fill of quantity shares on an order to the orders
+// linked to it. The caller must hold the stock's lock but no account lock.
+func (e *Exchange) fillLinked(order *models.Order, quantity int) {
+	if leg := order.OCOLeg(); leg != nil && leg.IsActive() {
+		e.reduceLeg(leg, quantity)
+	}
+	if !order.IsActive() {
+		e.closeLinked(order)
+	}
+}
+
+// reduceLeg takes quantity shares off an OCO leg after its other leg filled
+// them, cancelling it once nothing is left
+func (e *Exchange) reduceLeg(leg *models.Order, quantity int) {
+	quantity = min(quantity, leg.Quantity)
+	account := e.accountLock(leg.TraderID)
+	account.Lock()
+	e.consume(leg, quantity)
+	leg.Quantity -= quantity
+	leg.OriginalQuantity -= quantity
+	leg.UpdatedAt = time.Now()
+	account.Unlock()
+	e.touch(leg)
+
+	if leg.Quantity == 0 {
+		e.unlist(leg)
+		e.closeOrder(leg, models.Cancelled)
+		log.Printf("One-cancels-other order %s cancelled: %s filled", leg.ID, leg.OCOLegID)
+	}
+}
+
+// closeLinked settles the orders linked to an order that is no longer
+// active: the other leg of its OCO pair is cancelled and the exits of a
+// bracket entry are queued for activation, or cancelled if it never filled
+func (e *Exchange) closeLinked(order *models.Order) {
+	if leg := order.OCOLeg(); leg != nil && leg.IsActive() {
+		e.unlist(leg)
+		e.closeOrder(leg, models.Cancelled)
+		log.Printf("One-cancels-other order %s cancelled with %s", leg.ID, order.ID)
+	}
+
+	exits := order.Exits()
+	if len(exits) == 0 || exits[0].Status != models.Pending {
+		return
+	}
+	if order.FilledQuantity == 0 {
+		for _, exit := range exits {
+			exit.SetStatus(models.Cancelled)
+			e.touch(exit)
+		}
+		log.Printf("Bracket exits of %s cancelled: the entry did not fill", order.ID)
+		return
+	}
+
+	for _, exit := range exits {
+		exit.Quantity = order.FilledQuantity
+		exit.OriginalQuantity = order.FilledQuantity
+		e.touch(exit)
+	}
+	queue := e.exits[order.StockID]
+	queue.entries = append(queue.entries, order)
+}
+
+// activateExits puts the exits of the next queued bracket entry into the
+// market. It reports whether there was an entry to handle. The caller must
+// hold the stock's lock.
+func (e *Exchange) activateExits(stockID string) bool {
+	queue := e.exits[stockID]
+	if len(queue.entries) == 0 {
+		return false
+	}
+	entry := queue.entries[0]
+	queue.entries = queue.entries[1:]
+
+	// Both exits are opened before either can trade, so the first fill
+	// reduces the other
+	exits := entry.Exits()
+	account := e.accountLock(entry.TraderID)
+	account.Lock()
+	var err error
+	for _, exit := range exits {
+		exit.SetStatus(models.Open)
+		e.touch(exit)
+		if exit.IsTrailing() {
+			startTrail(e.stocks[stockID], exit)
+		}
+		if err = e.checkFunds(exit); err != nil {
+			break
+		}
+		e.reserve(exit)
+	}
+	account.Unlock()
+	if err != nil {
+		for _, exit := range exits {
+			e.closeOrder(exit, models.Cancelled)
+		}
+		log.Printf("Bracket exits of %s cancelled: %v", entry.ID, err)
+		return true
+	}
+
+	log.Printf("Bracket exits of %s activated for %d shares", entry.ID, entry.FilledQuantity)
+	for _, exit := range exits {
+		if exit.IsActive() {
+			e.route(exit)
+		}
+	}
+	return true
+}
+
+// unlist takes an order off its stock's order book or trigger book
+func (e *Exchange) unlist(order *models.Order) {
+	if e.books[order.StockID].Remove(order.ID) == nil {
+		e.stopBooks[order.StockID].Remove(order.ID)
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"stock-exchange/internal/models"
+	"testing"
+)
+
+// createTestExits returns a take-profit sell at 160 and a stop-loss sell at 140
+func createTestExits(traderID string, quantity int) (takeProfit, stopLoss *models.Order) {
+	takeProfit = createTestOrder("tp1", traderID, "1", models.Sell, 160.0, quantity)
+	stopLoss = createTestOrder("sl1", traderID, "1", models.Sell, 0, quantity)
+	stopLoss.Kind = models.Stop
+	stopLoss.StopPrice = money(140.0)
+	return takeProfit, stopLoss
+}
+
+// Test OCO orders - fills on one leg reduce and then cancel the other
+func TestOCO_FillCancelsOtherLeg(t *testing.T) {
+	exchange := createTestExchange()
+	trader1 := exchange.traders["trader1"]
+	trader1.Holdings["1"] = 10
+
+	takeProfit, stopLoss := createTestExits("trader1", 10)
+	if err := exchange.PlaceOCO(takeProfit, stopLoss); err != nil {
+		t.Fatalf("Failed to place OCO orders: %v", err)
+	}
+
+	// Only one leg can fill, so the 10 shares are reserved once
+	if trader1.ReservedShares["1"] != 10 {
+		t.Errorf("Expected the legs to share a reservation of 10 shares, got %d", trader1.ReservedShares["1"])
+	}
+
+	exchange.PlaceOrder(createTestOrder("buy1", "trader2", "1", models.Buy, 160.0, 4))
+	if takeProfit.FilledQuantity != 4 || stopLoss.Quantity != 6 {
+		t.Errorf("Expected the stop-loss to be reduced to 6, got %d", stopLoss.Quantity)
+	}
+	if trader1.ReservedShares["1"] != 6 {
+		t.Errorf("Expected 6 shares left reserved, got %d", trader1.ReservedShares["1"])
+	}
+
+	exchange.PlaceOrder(createTestOrder("buy2", "trader2", "1", models.Buy, 160.0, 10))
+	if takeProfit.Status != models.Filled || stopLoss.Status != models.Cancelled {
+		t.Errorf("Expected the take-profit filled and the stop-loss cancelled, got %v and %v", takeProfit.Status, stopLoss.Status)
+	}
+	if len(exchange.stopBooks["1"].Orders()) != 0 || trader1.ReservedShares["1"] != 0 {
+		t.Errorf("Expected the stop-loss off the trigger book with nothing reserved")
+	}
+	if buy2, _ := exchange.GetOrder("buy2"); buy2.FilledQuantity != 6 {
+		t.Errorf("Expected only the 6 remaining shares to trade, got %d", buy2.FilledQuantity)
+	}
+}
+
+// Test OCO orders - cancelling one leg cancels both, and both legs are checked together
+func TestOCO_CancelAndValidation(t *testing.T) {
+	exchange := createTestExchange()
+	trader1 := exchange.traders["trader1"]
+	trader1.Holdings["1"] = 10
+
+	takeProfit, stopLoss := createTestExits("trader1", 10)
+	exchange.PlaceOCO(takeProfit, stopLoss)
+	if err := exchange.CancelOrder("sl1"); err != nil {
+		t.Fatalf("Failed to cancel the stop-loss: %v", err)
+	}
+	if takeProfit.Status != models.Cancelled || exchange.books["1"].Len() != 0 {
+		t.Errorf("Expected cancelling one leg to cancel the other, got %v", takeProfit.Status)
+	}
+	if trader1.ReservedShares["1"] != 0 {
+		t.Errorf("Expected the reservation to be released, got %d", trader1.ReservedShares["1"])
+	}
+
+	first := createTestOrder("a1", "trader1", "1", models.Sell, 160.0, 10)
+	second := createTestOrder("a2", "trader1", "1", models.Sell, 170.0, 5)
+	if err := exchange.PlaceOCO(first, second); err == nil {
+		t.Error("Expected legs of different sizes to be rejected")
+	}
+
+	trader1.Holdings["1"] = 5
+	first = createTestOrder("b1", "trader1", "1", models.Sell, 160.0, 10)
+	second = createTestOrder("b2", "trader1", "1", models.Sell, 170.0, 10)
+	if err := exchange.PlaceOCO(first, second); err == nil {
+		t.Error("Expected legs the trader cannot cover to be rejected")
+	}
+	if _, exists := exchange.GetOrder("b1"); exists {
+		t.Error("Expected neither leg to be accepted")
+	}
+
+	trader1.Holdings["1"] = 10
+	first = createTestOrder("c1", "trader1", "1", models.Sell, 160.0, 10)
+	second = createTestOrder("c2", "trader1", "1", models.Sell, 170.0, 10)
+	exchange.PlaceOCO(first, second)
+	if _, err := exchange.AmendOrder("c1", money(165.0), 0); err == nil {
+		t.Error("Expected amending an OCO leg to be rejected")
+	}
+}
+
+// Test bracket orders - the exits activate once the entry fills and cancel each other
+func TestBracket_ExitsAfterEntryFills(t *testing.T) {
+	exchange := createTestExchange()
+	exchange.traders["trader2"].Holdings["1"] = 100
+	trader1 := exchange.traders["trader1"]
+
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 150.0, 10))
+
+	entry := createTestOrder("entry1", "trader1", "1", models.Buy, 150.0, 10)
+	takeProfit, stopLoss := createTestExits("trader1", 10)
+	if err := exchange.PlaceBracket(entry, takeProfit, stopLoss); err != nil {
+		t.Fatalf("Failed to place bracket order: %v", err)
+	}
+
+	if entry.Status != models.Filled {
+		t.Fatalf("Expected the entry to fill, got %v", entry.Status)
+	}
+	if takeProfit.Status != models.Open || stopLoss.Status != models.Open {
+		t.Fatalf("Expected both exits to be active, got %v and %v", takeProfit.Status, stopLoss.Status)
+	}
+	if entry.ExitIDs[0] != "tp1" || takeProfit.ParentID != "entry1" || stopLoss.OCOLegID != "tp1" {
+		t.Error("Expected the entry and exits to be linked")
+	}
+	if trader1.ReservedShares["1"] != 10 {
+		t.Errorf("Expected the exits to share a reservation of 10 shares, got %d", trader1.ReservedShares["1"])
+	}
+
+	// The price falls through the stop-loss, which sells into trader2's bid
+	exchange.PlaceOrder(createTestOrder("buy1", "trader2", "1", models.Buy, 139.0, 10))
+	exchange.UpdatePrice("1", money(139.0))
+
+	if stopLoss.Status != models.Filled || takeProfit.Status != models.Cancelled {
+		t.Errorf("Expected the stop-loss filled and the take-profit cancelled, got %v and %v", stopLoss.Status, takeProfit.Status)
+	}
+	if trader1.Holdings["1"] != 0 || trader1.ReservedShares["1"] != 0 {
+		t.Errorf("Expected the position closed with nothing reserved, got %d held and %d reserved",
+			trader1.Holdings["1"], trader1.ReservedShares["1"])
+	}
+}
+
+// Test bracket orders - the exits follow what the entry filled before it ended
+func TestBracket_EntryCancelled(t *testing.T) {
+	exchange := createTestExchange()
+	exchange.traders["trader2"].Holdings["1"] = 100
+
+	entry := createTestOrder("entry1", "trader1", "1", models.Buy, 150.0, 10)
+	takeProfit, stopLoss := createTestExits("trader1", 10)
+	exchange.PlaceBracket(entry, takeProfit, stopLoss)
+
+	if takeProfit.Status != models.Pending || exchange.books["1"].Len() != 1 {
+		t.Fatalf("Expected the exits to wait off the book, got %v", takeProfit.Status)
+	}
+
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 150.0, 4))
+	if takeProfit.Status != models.Pending {
+		t.Errorf("Expected the exits to wait while the entry is partly filled, got %v", takeProfit.Status)
+	}
+
+	exchange.CancelOrder("entry1")
+	if takeProfit.Status != models.Open || takeProfit.Quantity != 4 || stopLoss.Quantity != 4 {
+		t.Errorf("Expected the exits to be activated for the 4 filled shares, got %v for %d", takeProfit.Status, takeProfit.Quantity)
+	}
+
+	// An entry that never filled takes its exits with it
+	entry = createTestOrder("entry2", "trader1", "1", models.Buy, 145.0, 10)
+	takeProfit = createTestOrder("tp2", "trader1", "1", models.Sell, 160.0, 10)
+	stopLoss = createTestOrder("sl2", "trader1", "1", models.Sell, 0, 10)
+	stopLoss.Kind = models.Stop
+	stopLoss.StopPrice = money(140.0)
+	exchange.PlaceBracket(entry, takeProfit, stopLoss)
+	exchange.CancelOrder("entry2")
+	if takeProfit.Status != models.Cancelled || stopLoss.Status != models.Cancelled {
+		t.Errorf("Expected both exits cancelled, got %v and %v", takeProfit.Status, stopLoss.Status)
+	}
+
+	// The take-profit must be beyond the stop-loss
+	entry = createTestOrder("entry3", "trader1", "1", models.Buy, 145.0, 10)
+	takeProfit = createTestOrder("tp3", "trader1", "1", models.Sell, 135.0, 10)
+	stopLoss = createTestOrder("sl3", "trader1", "1", models.Sell, 0, 10)
+	stopLoss.Kind = models.Stop
+	stopLoss.StopPrice = money(140.0)
+	if err := exchange.PlaceBracket(entry, takeProfit, stopLoss); err == nil {
+		t.Error("Expected a take-profit below the stop-loss to be rejected")
+	}
+}
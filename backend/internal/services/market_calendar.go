@@ -0,0 +1,165 @@
+package services
+
+import (
+	"fmt"
+	"stock-exchange/internal/models"
+	"strings"
+	"time"
+)
+
+// CalendarConfig is the "market" section of config.json. Times of day are
+// "15:04" in TimeZone; trading days default to Monday to Friday.
+type CalendarConfig struct {
+	TimeZone    string   `json:"timeZone"`
+	PreOpen     string   `json:"preOpen"`
+	Open        string   `json:"open"`
+	Closing     string   `json:"closing"`
+	Close       string   `json:"close"`
+	TradingDays []string `json:"tradingDays"` // e.g. "Monday"
+	Holidays    []string `json:"holidays"`    // e.g. "2026-12-25"
+}
+
+// MarketCalendar decides which phase the market is in at any time. Each
+// trading day runs pre-open, open, closing and then closed; weekends and
+// holidays are closed all day.
+type MarketCalendar struct {
+	location    *time.Location
+	phases      [4]timeOfDay // Starts of pre-open, open, closing and close
+	tradingDays map[time.Weekday]bool
+	holidays    map[string]bool
+}
+
+type timeOfDay struct {
+	hour, minute int
+}
+
+// dayPhases are the phases that start at each of MarketCalendar.phases
+var dayPhases = [4]models.MarketPhase{models.PhasePreOpen, models.PhaseOpen, models.PhaseClosing, models.PhaseClosed}
+
+// NewMarketCalendar builds a calendar from its configuration
+func NewMarketCalendar(config CalendarConfig) (*MarketCalendar, error) {
+	location, err := time.LoadLocation(config.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid market time zone %q: %v", config.TimeZone, err)
+	}
+
+	calendar := &MarketCalendar{
+		location:    location,
+		tradingDays: make(map[time.Weekday]bool),
+		holidays:    make(map[string]bool),
+	}
+
+	for i, value := range []string{config.PreOpen, config.Open, config.Closing, config.Close} {
+		start, err := time.Parse("15:04", value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s time %q: expected HH:MM", dayPhases[i], value)
+		}
+		calendar.phases[i] = timeOfDay{hour: start.Hour(), minute: start.Minute()}
+		if i > 0 && !calendar.phases[i-1].before(calendar.phases[i]) {
+			return nil, fmt.Errorf("market phases must start in the order pre-open, open, closing, close")
+		}
+	}
+
+	days := config.TradingDays
+	if len(days) == 0 {
+		days = []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday"}
+	}
+	for _, name := range days {
+		day, ok := parseWeekday(name)
+		if !ok {
+			return nil, fmt.Errorf("invalid trading day: %s", name)
+		}
+		calendar.tradingDays[day] = true
+	}
+
+	for _, holiday := range config.Holidays {
+		date, err := time.Parse("2006-01-02", holiday)
+		if err != nil {
+			return nil, fmt.Errorf("invalid holiday %q: expected YYYY-MM-DD", holiday)
+		}
+		calendar.holidays[date.Format("2006-01-02")] = true
+	}
+
+	return calendar, nil
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(name, day.String()) {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+func (t timeOfDay) before(other timeOfDay) bool {
+	return t.hour < other.hour || (t.hour == other.hour && t.minute < other.minute)
+}
+
+// Location returns the time zone the calendar runs in
+func (c *MarketCalendar) Location() *time.Location {
+	return c.location
+}
+
+// IsTradingDay reports whether the market opens at all on the day of t
+func (c *MarketCalendar) IsTradingDay(t time.Time) bool {
+	local := t.In(c.location)
+	return c.tradingDays[local.Weekday()] && !c.holidays[local.Format("2006-01-02")]
+}
+
+// boundaries returns when each phase starts on the day of t
+func (c *MarketCalendar) boundaries(t time.Time) [4]time.Time {
+	year, month, day := t.In(c.location).Date()
+	var starts [4]time.Time
+	for i, start := range c.phases {
+		starts[i] = time.Date(year, month, day, start.hour, start.minute, 0, 0, c.location)
+	}
+	return starts
+}
+
+// PhaseAt returns the market phase at t
+func (c *MarketCalendar) PhaseAt(t time.Time) models.MarketPhase {
+	if !c.IsTradingDay(t) {
+		return models.PhaseClosed
+	}
+
+	phase := models.PhaseClosed // Before pre-open
+	for i, start := range c.boundaries(t) {
+		if !t.Before(start) {
+			phase = dayPhases[i]
+		}
+	}
+	return phase
+}
+
+// NextChange returns the next phase the market moves to after t and when.
+// It gives up after a year without a trading day.
+func (c *MarketCalendar) NextChange(t time.Time) (models.MarketPhase, time.Time, bool) {
+	current := c.PhaseAt(t)
+	for day := 0; day <= 366; day++ {
+		date := t.In(c.location).AddDate(0, 0, day)
+		if !c.IsTradingDay(date) {
+			continue
+		}
+		for i, start := range c.boundaries(date) {
+			if start.After(t) && dayPhases[i] != current {
+				return dayPhases[i], start, true
+			}
+		}
+	}
+	return "", time.Time{}, false
+}
+
+// NextClose returns the first close of a trading day strictly after t
+func (c *MarketCalendar) NextClose(t time.Time) (time.Time, bool) {
+	for day := 0; day <= 366; day++ {
+		date := t.In(c.location).AddDate(0, 0, day)
+		if !c.IsTradingDay(date) {
+			continue
+		}
+		if close := c.boundaries(date)[3]; close.After(t) {
+			return close, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"stock-exchange/internal/models"
+	"testing"
+	"time"
+)
+
+func createTestCalendar(t *testing.T) *MarketCalendar {
+	calendar, err := NewMarketCalendar(CalendarConfig{
+		TimeZone: "America/New_York",
+		PreOpen:  "09:00",
+		Open:     "09:30",
+		Closing:  "15:50",
+		Close:    "16:00",
+		Holidays: []string{"2026-12-25"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create calendar: %v", err)
+	}
+	return calendar
+}
+
+// Test MarketCalendar phases through a trading day, a weekend and a holiday
+func TestMarketCalendar_PhaseAt(t *testing.T) {
+	calendar := createTestCalendar(t)
+	newYork := calendar.Location()
+
+	tests := []struct {
+		time  time.Time
+		phase models.MarketPhase
+	}{
+		{time.Date(2026, 10, 19, 8, 59, 0, 0, newYork), models.PhaseClosed},
+		{time.Date(2026, 10, 19, 9, 0, 0, 0, newYork), models.PhasePreOpen},
+		{time.Date(2026, 10, 19, 9, 30, 0, 0, newYork), models.PhaseOpen},
+		{time.Date(2026, 10, 19, 15, 55, 0, 0, newYork), models.PhaseClosing},
+		{time.Date(2026, 10, 19, 16, 0, 0, 0, newYork), models.PhaseClosed},
+		{time.Date(2026, 10, 19, 14, 0, 0, 0, time.UTC), models.PhaseOpen},  // 10:00 in New York
+		{time.Date(2026, 10, 17, 12, 0, 0, 0, newYork), models.PhaseClosed}, // Saturday
+		{time.Date(2026, 12, 25, 12, 0, 0, 0, newYork), models.PhaseClosed}, // Holiday
+	}
+
+	for _, tt := range tests {
+		if phase := calendar.PhaseAt(tt.time); phase != tt.phase {
+			t.Errorf("PhaseAt(%s) = %s, expected %s", tt.time, phase, tt.phase)
+		}
+	}
+}
+
+// Test MarketCalendar next phase change and next close skip non-trading days
+func TestMarketCalendar_NextChange(t *testing.T) {
+	calendar := createTestCalendar(t)
+	newYork := calendar.Location()
+
+	// Friday after the close: next is Monday's pre-open
+	phase, at, ok := calendar.NextChange(time.Date(2026, 10, 16, 17, 0, 0, 0, newYork))
+	if !ok || phase != models.PhasePreOpen || !at.Equal(time.Date(2026, 10, 19, 9, 0, 0, 0, newYork)) {
+		t.Errorf("Expected pre-open on Monday 09:00, got %s at %s", phase, at)
+	}
+
+	phase, at, ok = calendar.NextChange(time.Date(2026, 10, 19, 10, 0, 0, 0, newYork))
+	if !ok || phase != models.PhaseClosing || !at.Equal(time.Date(2026, 10, 19, 15, 50, 0, 0, newYork)) {
+		t.Errorf("Expected closing at 15:50, got %s at %s", phase, at)
+	}
+
+	// Christmas Eve after the close: the next close is on the 28th
+	close, ok := calendar.NextClose(time.Date(2026, 12, 24, 16, 30, 0, 0, newYork))
+	if !ok || !close.Equal(time.Date(2026, 12, 28, 16, 0, 0, 0, newYork)) {
+		t.Errorf("Expected the next close on December 28, got %s", close)
+	}
+}
+
+// Test NewMarketCalendar rejects invalid configuration
+func TestMarketCalendar_InvalidConfig(t *testing.T) {
+	valid := CalendarConfig{TimeZone: "UTC", PreOpen: "09:00", Open: "09:30", Closing: "15:50", Close: "16:00"}
+
+	invalid := []func(*CalendarConfig){
+		func(c *CalendarConfig) { c.TimeZone = "Mars/Olympus" },
+		func(c *CalendarConfig) { c.Open = "9.30" },
+		func(c *CalendarConfig) { c.Closing = "09:15" },
+		func(c *CalendarConfig) { c.TradingDays = []string{"Funday"} },
+		func(c *CalendarConfig) { c.Holidays = []string{"25/12/2026"} },
+	}
+	for i, change := range invalid {
+		config := valid
+		change(&config)
+		if _, err := NewMarketCalendar(config); err == nil {
+			t.Errorf("Expected config %d to be rejected", i)
+		}
+	}
+
+	if _, err := NewMarketCalendar(valid); err != nil {
+		t.Errorf("Expected a valid config to be accepted: %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"time"
+)
+
+// MarketClock periodically moves the market to the phase its calendar gives
+type MarketClock struct {
+	exchange *Exchange
+	ticker   *time.Ticker
+	done     chan bool
+}
+
+func NewMarketClock(exchange *Exchange, interval time.Duration) *MarketClock {
+	return &MarketClock{
+		exchange: exchange,
+		ticker:   time.NewTicker(interval),
+		done:     make(chan bool),
+	}
+}
+
+func (mc *MarketClock) Start() {
+	go func() {
+		for {
+			select {
+			case now := <-mc.ticker.C:
+				mc.exchange.AdvanceSession(now)
+			case <-mc.done:
+				return
+			}
+		}
+	}()
+}
+
+func (mc *MarketClock) Stop() {
+	mc.ticker.Stop()
+	mc.done <- true
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"sort"
+	"stock-exchange/internal/models"
+)
+
+// PriceLevel holds the resting orders at a single price, oldest first
+type PriceLevel struct {
+	Price  models.Money
+	Orders []*models.Order
+}
+
+// OrderBook keeps the resting orders of a single stock in price-time priority.
+// Bids are sorted from the highest price down, asks from the lowest price up,
+// and orders at the same price are kept in arrival (FIFO) order, with hidden
+// orders queued behind all displayed ones.
+type OrderBook struct {
+	StockID string
+	bids    []*PriceLevel
+	asks    []*PriceLevel
+	seq     uint64
+}
+
+func NewOrderBook(stockID string) *OrderBook {
+	return &OrderBook{
+		StockID: stockID,
+		bids:    make([]*PriceLevel, 0),
+		asks:    make([]*PriceLevel, 0),
+	}
+}
+
+// levels returns the side of the book an order of the given type rests on
+func (b *OrderBook) levels(orderType models.OrderType) *[]*PriceLevel {
+	if orderType == models.Buy {
+		return &b.bids
+	}
+	return &b.asks
+}
+
+// better reports whether price a has priority over price b on the given side
+func better(orderType models.OrderType, a, b models.Money) bool {
+	if orderType == models.Buy {
+		return a > b
+	}
+	return a < b
+}
+
+// Add puts an order at the back of the queue for its price level, or for a
+// displayed order, ahead of any hidden orders at that price
+func (b *OrderBook) Add(order *models.Order) {
+	b.seq++
+	order.SetQueued(b.seq)
+	levels := b.levels(order.Type)
+
+	// Find the first level that does not have priority over this price
+	i := sort.Search(len(*levels), func(i int) bool {
+		return !better(order.Type, (*levels)[i].Price, order.Price)
+	})
+
+	if i < len(*levels) && (*levels)[i].Price == order.Price {
+		level := (*levels)[i]
+		at := len(level.Orders)
+		for !order.Hidden && at > 0 && level.Orders[at-1].Hidden {
+			at--
+		}
+		level.Orders = append(level.Orders, nil)
+		copy(level.Orders[at+1:], level.Orders[at:])
+		level.Orders[at] = order
+		return
+	}
+
+	level := &PriceLevel{Price: order.Price, Orders: []*models.Order{order}}
+	*levels = append(*levels, nil)
+	copy((*levels)[i+1:], (*levels)[i:])
+	(*levels)[i] = level
+}
+
+// Remove takes an order out of the book and returns it, or nil if it is not resting
+func (b *OrderBook) Remove(orderID string) *models.Order {
+	for _, orderType := range []models.OrderType{models.Buy, models.Sell} {
+		levels := b.levels(orderType)
+		for li, level := range *levels {
+			for oi, order := range level.Orders {
+				if order.ID != orderID {
+					continue
+				}
+
+				level.Orders = append(level.Orders[:oi], level.Orders[oi+1:]...)
+				if len(level.Orders) == 0 {
+					*levels = append((*levels)[:li], (*levels)[li+1:]...)
+				}
+				return order
+			}
+		}
+	}
+	return nil
+}
+
+// Get returns a resting order by ID
+func (b *OrderBook) Get(orderID string) (*models.Order, bool) {
+	for _, order := range b.Bids() {
+		if order.ID == orderID {
+			return order, true
+		}
+	}
+	for _, order := range b.Asks() {
+		if order.ID == orderID {
+			return order, true
+		}
+	}
+	return nil, false
+}
+
+// BestBid returns the highest-priority buy order, or nil if there are no bids
+func (b *OrderBook) BestBid() *models.Order {
+	if len(b.bids) == 0 {
+		return nil
+	}
+	return b.bids[0].Orders[0]
+}
+
+// BestAsk returns the highest-priority sell order, or nil if there are no asks
+func (b *OrderBook) BestAsk() *models.Order {
+	if len(b.asks) == 0 {
+		return nil
+	}
+	return b.asks[0].Orders[0]
+}
+
+// BestOpposite returns the order an incoming order of the given type would
+// trade against first, or nil if that side is empty
+func (b *OrderBook) BestOpposite(orderType models.OrderType) *models.Order {
+	if orderType == models.Buy {
+		return b.BestAsk()
+	}
+	return b.BestBid()
+}
+
+// Crossed reports whether the best bid is priced at or above the best ask,
+// which only happens while orders are collected for an auction
+func (b *OrderBook) Crossed() bool {
+	bid, ask := b.BestBid(), b.BestAsk()
+	return bid != nil && ask != nil && bid.Price >= ask.Price
+}
+
+// Bids returns all resting buy orders in priority order
+func (b *OrderBook) Bids() []*models.Order {
+	return flatten(b.bids)
+}
+
+// Asks returns all resting sell orders in priority order
+func (b *OrderBook) Asks() []*models.Order {
+	return flatten(b.asks)
+}
+
+// Side returns the resting orders of the given type in priority order
+func (b *OrderBook) Side(orderType models.OrderType) []*models.Order {
+	return flatten(*b.levels(orderType))
+}
+
+// Opposite returns the orders an incoming order of the given type would trade against
+func (b *OrderBook) Opposite(orderType models.OrderType) []*models.Order {
+	if orderType == models.Buy {
+		return b.Asks()
+	}
+	return b.Bids()
+}
+
+// Len returns the number of resting orders on both sides
+func (b *OrderBook) Len() int {
+	count := 0
+	for _, level := range b.bids {
+		count += len(level.Orders)
+	}
+	for _, level := range b.asks {
+		count += len(level.Orders)
+	}
+	return count
+}
+
+func flatten(levels []*PriceLevel) []*models.Order {
+	orders := make([]*models.Order, 0)
+	for _, level := range levels {
+		orders = append(orders, level.Orders...)
+	}
+	return orders
+}
+
+// crosses reports whether an incoming order is willing to trade at the resting order's price
+func crosses(incoming, resting *models.Order) bool {
+	if incoming.IsMarket() {
+		return true
+	}
+	if incoming.Type == models.Buy {
+		return incoming.Price >= resting.Price
+	}
+	return incoming.Price <= resting.Price
+}
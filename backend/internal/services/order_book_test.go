@@ -0,0 +1,88 @@
+package services
+
+import (
+	"stock-exchange/internal/models"
+	"testing"
+)
+
+// Test OrderBook ordering - bids high to low, asks low to high, FIFO within a level
+func TestOrderBook_PriceTimePriority(t *testing.T) {
+	book := NewOrderBook("1")
+
+	book.Add(createTestOrder("b1", "trader1", "1", models.Buy, 100.0, 1))
+	book.Add(createTestOrder("b2", "trader1", "1", models.Buy, 101.0, 1))
+	book.Add(createTestOrder("b3", "trader1", "1", models.Buy, 100.0, 1))
+	book.Add(createTestOrder("s1", "trader2", "1", models.Sell, 103.0, 1))
+	book.Add(createTestOrder("s2", "trader2", "1", models.Sell, 102.0, 1))
+	book.Add(createTestOrder("s3", "trader2", "1", models.Sell, 103.0, 1))
+
+	expectedBids := []string{"b2", "b1", "b3"}
+	for i, order := range book.Bids() {
+		if order.ID != expectedBids[i] {
+			t.Errorf("Expected bid %d to be %s, got %s", i, expectedBids[i], order.ID)
+		}
+	}
+
+	expectedAsks := []string{"s2", "s1", "s3"}
+	for i, order := range book.Asks() {
+		if order.ID != expectedAsks[i] {
+			t.Errorf("Expected ask %d to be %s, got %s", i, expectedAsks[i], order.ID)
+		}
+	}
+
+	if book.BestBid().ID != "b2" {
+		t.Errorf("Expected best bid b2, got %s", book.BestBid().ID)
+	}
+
+	if book.BestAsk().ID != "s2" {
+		t.Errorf("Expected best ask s2, got %s", book.BestAsk().ID)
+	}
+
+	if book.Len() != 6 {
+		t.Errorf("Expected 6 resting orders, got %d", book.Len())
+	}
+}
+
+// Test OrderBook ordering - hidden orders queue behind displayed ones at their price
+func TestOrderBook_HiddenPriority(t *testing.T) {
+	book := NewOrderBook("1")
+
+	hidden := createTestOrder("h1", "trader1", "1", models.Buy, 100.0, 1)
+	hidden.Hidden = true
+	book.Add(hidden)
+	book.Add(createTestOrder("b1", "trader1", "1", models.Buy, 100.0, 1))
+	book.Add(createTestOrder("b2", "trader1", "1", models.Buy, 99.0, 1))
+	book.Add(createTestOrder("b3", "trader1", "1", models.Buy, 100.0, 1))
+
+	expected := []string{"b1", "b3", "h1", "b2"}
+	for i, order := range book.Bids() {
+		if order.ID != expected[i] {
+			t.Errorf("Expected bid %d to be %s, got %s", i, expected[i], order.ID)
+		}
+	}
+}
+
+// Test OrderBook removal - empty price levels are dropped
+func TestOrderBook_Remove(t *testing.T) {
+	book := NewOrderBook("1")
+
+	book.Add(createTestOrder("b1", "trader1", "1", models.Buy, 101.0, 1))
+	book.Add(createTestOrder("b2", "trader1", "1", models.Buy, 100.0, 1))
+
+	if removed := book.Remove("b1"); removed == nil || removed.ID != "b1" {
+		t.Fatal("Expected b1 to be removed")
+	}
+
+	if book.BestBid().ID != "b2" {
+		t.Errorf("Expected b2 to become best bid, got %s", book.BestBid().ID)
+	}
+
+	if removed := book.Remove("missing"); removed != nil {
+		t.Error("Expected nil when removing an unknown order")
+	}
+
+	book.Remove("b2")
+	if book.BestBid() != nil {
+		t.Error("Expected empty bid side")
+	}
+}
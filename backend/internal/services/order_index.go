@@ -0,0 +1,171 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"stock-exchange/internal/models"
+	"sync"
+	"sync/atomic"
+)
+
+// shards is how many locks the order index and the traders' accounts are
+// each spread over, so operations on different stocks for different traders
+// rarely wait for each other
+const shards = 64
+
+// shardOf picks a key's shard with FNV-1a
+func shardOf(key string) int {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return int(hash % shards)
+}
+
+// orderIndex keeps the open and pending orders by ID, each trader's in
+// arrival order, and every order ID ever taken. An order's ID picks the
+// shard holding it and its ID, a trader's ID the shard holding their list.
+// The orders themselves are guarded by their stock's lock.
+type orderIndex struct {
+	shards   [shards]orderShard
+	arrivals atomic.Uint64 // Arrival number of the latest accepted order
+}
+
+type orderShard struct {
+	orders       map[string]*models.Order   // Open orders by ID
+	traderOrders map[string][]*models.Order // Each trader's open orders in arrival order
+	ids          map[string]bool            // Every order ID taken, open or closed
+	mu           sync.RWMutex
+}
+
+func newOrderIndex() *orderIndex {
+	index := &orderIndex{}
+	for i := range index.shards {
+		shard := &index.shards[i]
+		shard.orders = make(map[string]*models.Order)
+		shard.traderOrders = make(map[string][]*models.Order)
+		shard.ids = make(map[string]bool)
+	}
+	return index
+}
+
+// add numbers an accepted order's arrival and indexes it, rejecting an ID
+// that is already taken
+func (x *orderIndex) add(order *models.Order) error {
+	shard := &x.shards[shardOf(order.ID)]
+	shard.mu.Lock()
+	if shard.ids[order.ID] {
+		shard.mu.Unlock()
+		return fmt.Errorf("duplicate order id: %s", order.ID)
+	}
+	order.SetArrival(x.arrivals.Add(1))
+	shard.orders[order.ID] = order
+	shard.ids[order.ID] = true
+	shard.mu.Unlock()
+
+	x.list(order)
+	return nil
+}
+
+// restore indexes an order again as journaled, keeping its arrival number
+func (x *orderIndex) restore(order *models.Order) {
+	shard := &x.shards[shardOf(order.ID)]
+	shard.mu.Lock()
+	shard.orders[order.ID] = order
+	shard.ids[order.ID] = true
+	shard.mu.Unlock()
+
+	x.list(order)
+	for arrivals := x.arrivals.Load(); arrivals < order.Arrival(); arrivals = x.arrivals.Load() {
+		if x.arrivals.CompareAndSwap(arrivals, order.Arrival()) {
+			break
+		}
+	}
+}
+
+// list adds an order to its trader's open orders. Orders on different stocks
+// can be indexed out of turn, so it goes in by arrival.
+func (x *orderIndex) list(order *models.Order) {
+	shard := &x.shards[shardOf(order.TraderID)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	orders := append(shard.traderOrders[order.TraderID], order)
+	for i := len(orders) - 1; i > 0 && orders[i-1].Arrival() > order.Arrival(); i-- {
+		orders[i], orders[i-1] = orders[i-1], orders[i]
+	}
+	shard.traderOrders[order.TraderID] = orders
+}
+
+// take marks an order ID as taken by an order that is already closed
+func (x *orderIndex) take(orderID string) {
+	shard := &x.shards[shardOf(orderID)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	shard.ids[orderID] = true
+}
+
+// taken reports whether an order ID has been taken, by an open order or a
+// closed one
+func (x *orderIndex) taken(orderID string) bool {
+	shard := &x.shards[shardOf(orderID)]
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	return shard.ids[orderID]
+}
+
+// get looks an open order up by ID
+func (x *orderIndex) get(orderID string) (*models.Order, bool) {
+	shard := &x.shards[shardOf(orderID)]
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	order, exists := shard.orders[orderID]
+	return order, exists
+}
+
+// remove drops an order that is done. Its ID stays taken.
+func (x *orderIndex) remove(order *models.Order) {
+	shard := &x.shards[shardOf(order.ID)]
+	shard.mu.Lock()
+	delete(shard.orders, order.ID)
+	shard.mu.Unlock()
+
+	shard = &x.shards[shardOf(order.TraderID)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	orders := shard.traderOrders[order.TraderID]
+	for i, open := range orders {
+		if open == order {
+			shard.traderOrders[order.TraderID] = append(orders[:i:i], orders[i+1:]...)
+			break
+		}
+	}
+	if len(shard.traderOrders[order.TraderID]) == 0 {
+		delete(shard.traderOrders, order.TraderID)
+	}
+}
+
+// traderOrders returns a trader's open orders in arrival order
+func (x *orderIndex) traderOrders(traderID string) []*models.Order {
+	shard := &x.shards[shardOf(traderID)]
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	orders := shard.traderOrders[traderID]
+	return orders[:len(orders):len(orders)]
+}
+
+// all returns every open order in arrival order
+func (x *orderIndex) all() []*models.Order {
+	var orders []*models.Order
+	for i := range x.shards {
+		shard := &x.shards[i]
+		shard.mu.RLock()
+		for _, order := range shard.orders {
+			orders = append(orders, order)
+		}
+		shard.mu.RUnlock()
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].Arrival() < orders[j].Arrival() })
+	return orders
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"stock-exchange/internal/models"
+	"testing"
+)
+
+// Test the order index - IDs stay taken, traders' orders stay in arrival order
+func TestOrderIndex(t *testing.T) {
+	index := newOrderIndex()
+	buy1 := createTestOrder("buy1", "trader1", "1", models.Buy, 150.0, 10)
+	sell1 := createTestOrder("sell1", "trader1", "2", models.Sell, 300.0, 5)
+	index.add(buy1)
+	index.add(sell1)
+	if buy1.Arrival() != 1 || sell1.Arrival() != 2 {
+		t.Errorf("Expected arrivals 1 and 2, got %d and %d", buy1.Arrival(), sell1.Arrival())
+	}
+	if err := index.add(createTestOrder("buy1", "trader2", "1", models.Buy, 150.0, 10)); err == nil {
+		t.Error("Expected a duplicate ID to be rejected")
+	}
+
+	// An order numbered earlier but listed later goes before the ones after it
+	late := createTestOrder("late", "trader1", "1", models.Buy, 149.0, 10)
+	late.SetArrival(1)
+	index.restore(late)
+	if orders := index.traderOrders("trader1"); len(orders) != 3 || orders[0] != buy1 || orders[1] != late || orders[2] != sell1 {
+		t.Errorf("Expected trader1's orders in arrival order, got %v", orders)
+	}
+
+	index.remove(buy1)
+	if _, open := index.get("buy1"); open || !index.taken("buy1") {
+		t.Errorf("Expected buy1 to be dropped with its ID kept, got open %v", open)
+	}
+	if orders := index.all(); len(orders) != 2 || orders[0] != late || orders[1] != sell1 {
+		t.Errorf("Expected the two open orders in arrival order, got %v", orders)
+	}
+	if index.arrivals.Load() != 2 {
+		t.Errorf("Expected restoring an older order to keep the arrival count, got %d", index.arrivals.Load())
+	}
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"log"
+	"stock-exchange/internal/models"
+	"stock-exchange/internal/storage"
+)
+
+const (
+	DefaultOrderPageSize = 50
+	MaxOrderPageSize     = 200
+)
+
+// GetOrder returns any order the exchange has accepted, open or closed, as
+// of the last operation that changed it
+func (e *Exchange) GetOrder(orderID string) (models.Order, bool) {
+	e.writer.flush()
+	order, exists, err := e.store.Order(orderID)
+	if err != nil {
+		log.Printf("Failed to read order %s: %v", orderID, err)
+	}
+	return order, exists
+}
+
+// QueryOrders returns a page of a trader's order history, newest first
+func (e *Exchange) QueryOrders(query storage.OrderQuery) (storage.OrderPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = DefaultOrderPageSize
+	}
+	if query.Limit > MaxOrderPageSize {
+		query.Limit = MaxOrderPageSize
+	}
+	e.writer.flush()
+	return e.store.QueryOrders(query)
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"fmt"
+	"stock-exchange/internal/models"
+	"stock-exchange/internal/storage"
+	"testing"
+	"time"
+)
+
+// Test GetOrder - closed orders can still be looked up
+func TestGetOrder(t *testing.T) {
+	exchange := createTestExchange()
+
+	exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 100.0, 5))
+	exchange.CancelOrder("buy1")
+
+	order, exists := exchange.GetOrder("buy1")
+	if !exists {
+		t.Fatal("Expected cancelled order to be found")
+	}
+
+	if order.Status != models.Cancelled {
+		t.Errorf("Expected cancelled status, got %v", order.Status)
+	}
+
+	if _, exists := exchange.GetOrder("missing"); exists {
+		t.Error("Expected unknown order not to be found")
+	}
+
+	// Closed orders cannot be cancelled again
+	if err := exchange.CancelOrder("buy1"); err == nil {
+		t.Error("Expected error cancelling an already cancelled order")
+	}
+}
+
+// Test QueryOrders - filters by status, stock, side and time
+func TestQueryOrders_Filters(t *testing.T) {
+	exchange := createTestExchange()
+
+	exchange.traders["trader1"].Holdings["2"] = 10
+	exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 100.0, 5))
+	exchange.PlaceOrder(createTestOrder("buy2", "trader1", "2", models.Buy, 200.0, 5))
+	exchange.PlaceOrder(createTestOrder("sell1", "trader1", "2", models.Sell, 400.0, 5))
+	exchange.PlaceOrder(createTestOrder("other", "trader2", "1", models.Buy, 100.0, 5))
+	exchange.CancelOrder("buy2")
+
+	tests := []struct {
+		name     string
+		query    storage.OrderQuery
+		expected []string
+	}{
+		{"All", storage.OrderQuery{TraderID: "trader1"}, []string{"sell1", "buy2", "buy1"}},
+		{"Status", storage.OrderQuery{TraderID: "trader1", Statuses: []models.OrderStatus{models.Open}}, []string{"sell1", "buy1"}},
+		{"Stock", storage.OrderQuery{TraderID: "trader1", StockID: "2"}, []string{"sell1", "buy2"}},
+		{"Side", storage.OrderQuery{TraderID: "trader1", Side: models.Buy}, []string{"buy2", "buy1"}},
+		{"Future", storage.OrderQuery{TraderID: "trader1", From: time.Now().Add(time.Hour)}, []string{}},
+		{"Past", storage.OrderQuery{TraderID: "trader1", To: time.Now().Add(-time.Hour)}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := exchange.QueryOrders(tt.query)
+			if err != nil {
+				t.Fatalf("QueryOrders failed: %v", err)
+			}
+
+			if len(page.Orders) != len(tt.expected) {
+				t.Fatalf("Expected %d orders, got %d", len(tt.expected), len(page.Orders))
+			}
+
+			for i, order := range page.Orders {
+				if order.ID != tt.expected[i] {
+					t.Errorf("Expected order %d to be %s, got %s", i, tt.expected[i], order.ID)
+				}
+			}
+		})
+	}
+}
+
+// Test QueryOrders - cursor pagination walks the whole history exactly once
+func TestQueryOrders_Pagination(t *testing.T) {
+	exchange := createTestExchange()
+
+	for i := 0; i < 5; i++ {
+		exchange.PlaceOrder(createTestOrder(fmt.Sprintf("buy%d", i), "trader1", "1", models.Buy, 100.0, 1))
+	}
+
+	seen := make([]string, 0)
+	query := storage.OrderQuery{TraderID: "trader1", Limit: 2}
+	for {
+		page, err := exchange.QueryOrders(query)
+		if err != nil {
+			t.Fatalf("QueryOrders failed: %v", err)
+		}
+		for _, order := range page.Orders {
+			seen = append(seen, order.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	expected := []string{"buy4", "buy3", "buy2", "buy1", "buy0"}
+	if fmt.Sprint(seen) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, seen)
+	}
+
+	if _, err := exchange.QueryOrders(storage.OrderQuery{TraderID: "trader1", Cursor: "bogus"}); err == nil {
+		t.Error("Expected error for an invalid cursor")
+	}
+}
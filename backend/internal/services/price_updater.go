@@ -46,9 +46,12 @@ func (pu *PriceUpdater) Stop() {
 }
 
 func (pu *PriceUpdater) updatePrices() {
-	pu.exchange.mu.RLock()
-	stocks := pu.exchange.stocks
-	pu.exchange.mu.RUnlock()
+	// Prices only move while the market is open
+	if !pu.exchange.IsMarketOpen() {
+		return
+	}
+
+	stocks := pu.exchange.GetAllStocks()
 
 	log.Printf("🔄 Starting price update for %d stocks...", len(stocks))
 	changedCount := 0
@@ -57,16 +60,13 @@ func (pu *PriceUpdater) updatePrices() {
 		// Random price change between -2% and +2%
 		change := (pu.rng.Float64() - 0.5) * 0.04
 		currentPrice := stock.GetPrice()
-		newPrice := currentPrice * (1 + change)
-
-		// Round to 2 decimal places
-		newPrice = float64(int(newPrice*100+0.5)) / 100
+		newPrice := stock.RoundToTick(currentPrice.MulFloat(1 + change))
 
 		// Only update if there's actually a change
 		if newPrice != currentPrice {
-			stock.SetPrice(newPrice)
+			pu.exchange.UpdatePrice(stock.ID, newPrice)
 			changedCount++
-			log.Printf("💹 Price updated: %s %.2f -> %.2f (%.2f%%)", stock.ID, currentPrice, newPrice, change*100)
+			log.Printf("💹 Price updated: %s %s -> %s (%.2f%%)", stock.ID, currentPrice, newPrice, change*100)
 		}
 	}
 
@@ -0,0 +1,483 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"stock-exchange/internal/models"
+	"stock-exchange/internal/storage"
+	"time"
+)
+
+// Everything an operation changes on a stock is collected while it holds
+// the stock's lock and committed as the lock is released: journaled as one
+// entry, so entries for a stock are in the order its changes were made and a
+// replay never sees half an operation, and queued for the store as one batch
+// that is saved once the lock is released.
+// Orders and the stock are journaled as they are after the operation, fills
+// as the transactions they created. Trader registrations and settings and
+// market phase changes are journaled and stored as they happen.
+//
+// Replaying the entries after the latest snapshot rebuilds the open orders,
+// traders' cash and holdings, stock prices and their price history and the
+// valuations taken on fills and at the Valuator's cadence, and saves the
+// orders and transactions to the store again. Books are rebuilt from the open orders in
+// the order they queued, and reservations from what those orders hold back.
+
+// changeSet collects what operations on a stock changed since the last
+// commit, guarded by the stock's lock
+type changeSet struct {
+	orders       []*models.Order
+	touched      map[*models.Order]bool
+	accepted     map[*models.Order]bool
+	transactions []models.Transaction
+	stock        models.StockState // As last committed
+}
+
+func newChangeSet() *changeSet {
+	return &changeSet{
+		touched:  make(map[*models.Order]bool),
+		accepted: make(map[*models.Order]bool),
+	}
+}
+
+// Recover restores the exchange from its latest snapshot and the journal
+// entries after it, and journals every change from then on. On first boot,
+// with no snapshot and an empty journal, the exchange is loaded from
+// configFile instead. The market calendar is read from configFile either way.
+// Without a snapshot store the whole journal is replayed and no snapshots can
+// be taken.
+//
+// The store is brought back in step with the journal once the snapshot is
+// restored: anything saved for entries a crash kept out of the journal is
+// dropped, and what was lost is saved again from the restored exchange.
+func (e *Exchange) Recover(configFile string, journal *Journal, snapshots *SnapshotStore) error {
+	var snapshot *Snapshot
+	if snapshots != nil {
+		var err error
+		if snapshot, err = snapshots.Latest(); err != nil {
+			return err
+		}
+	}
+	e.snapshots = snapshots
+	if snapshot == nil && journal.Seq() == 0 {
+		if err := e.store.Rewind(0); err != nil {
+			return fmt.Errorf("failed to rewind the store: %v", err)
+		}
+		e.journal = journal
+		return e.LoadConfig(configFile)
+	}
+
+	config, err := readConfig(configFile)
+	if err != nil {
+		return err
+	}
+	if err := e.setCalendar(config.Market); err != nil {
+		return err
+	}
+
+	var after uint64
+	if snapshot != nil {
+		e.restoreSnapshot(snapshot)
+		after = snapshot.Seq
+		journal.continueFrom(snapshot.Seq)
+	}
+	// The journal may have been truncated up to the snapshot, so rows the
+	// snapshot covers are kept
+	if err := e.store.Rewind(journal.Seq()); err != nil {
+		return fmt.Errorf("failed to rewind the store: %v", err)
+	}
+	if err := journal.Replay(after, e.replay); err != nil {
+		return err
+	}
+	e.rebuild()
+	if err := e.store.Save(e.resync(journal.Seq())); err != nil {
+		return fmt.Errorf("failed to save the restored exchange: %v", err)
+	}
+	if err := e.loadOrderIDs(); err != nil {
+		return fmt.Errorf("failed to read order IDs: %v", err)
+	}
+	e.journal = journal
+	log.Printf("Exchange recovered from journal entries %d to %d", after+1, journal.Seq())
+
+	e.resumeHalts()
+	e.AdvanceSession(time.Now())
+	return nil
+}
+
+// lockStock takes a stock's lock for an operation that may change it. The
+// returned unlock commits the changes and releases the lock.
+func (e *Exchange) lockStock(stockID string) (unlock func()) {
+	mu := e.stockLocks[stockID]
+	mu.Lock()
+	return func() {
+		e.commit(stockID)
+		mu.Unlock()
+		e.writer.write()
+	}
+}
+
+// touch marks orders as changed by the current operation. The caller must
+// hold the orders' stock lock.
+func (e *Exchange) touch(orders ...*models.Order) {
+	for _, order := range orders {
+		changes := e.changes[order.StockID]
+		if !changes.touched[order] {
+			changes.touched[order] = true
+			changes.orders = append(changes.orders, order)
+		}
+	}
+}
+
+// touchAccepted marks an order as accepted by the current operation
+func (e *Exchange) touchAccepted(order *models.Order) {
+	e.touch(order)
+	e.changes[order.StockID].accepted[order] = true
+}
+
+// commit journals what has changed on a stock and queues it for the store,
+// and forgets the orders that are done. The caller must hold the stock's lock.
+func (e *Exchange) commit(stockID string) {
+	changes := e.changes[stockID]
+	stock := e.stocks[stockID]
+	state := stock.State()
+	stockChanged := !state.Equal(changes.stock)
+	if len(changes.orders) == 0 && len(changes.transactions) == 0 && !stockChanged {
+		return
+	}
+
+	batch := storage.Batch{
+		Seq:          e.journalChanges(changes, state, stockChanged),
+		Orders:       make([]models.Order, 0, len(changes.orders)),
+		Transactions: changes.transactions,
+	}
+	for _, order := range changes.orders {
+		batch.Orders = append(batch.Orders, *order)
+	}
+	if stockChanged {
+		batch.Stocks = []*models.Stock{stock.Clone()}
+	}
+
+	// Fills are the only changes to traders' cash and holdings
+	settled := make(map[string]bool)
+	for _, transaction := range changes.transactions {
+		for _, traderID := range []string{transaction.BuyerID, transaction.SellerID} {
+			if trader, exists := e.trader(traderID); exists && !settled[traderID] {
+				settled[traderID] = true
+				account := e.accountLock(traderID)
+				account.RLock()
+				batch.Traders = append(batch.Traders, cloneTrader(trader))
+				account.RUnlock()
+			}
+		}
+	}
+	e.recordValuations(changes.transactions)
+	e.save(batch)
+	e.recordTicks(stockID, changes.transactions, state.CurrentPrice != changes.stock.CurrentPrice, state.CurrentPrice, time.Now())
+
+	for _, order := range changes.orders {
+		if !order.IsActive() && order.Status != models.Pending {
+			e.forget(order)
+		}
+	}
+
+	changes.orders = changes.orders[:0]
+	changes.transactions = nil
+	clear(changes.touched)
+	clear(changes.accepted)
+	changes.stock = state
+}
+
+// journalChanges journals a stock's changes as one entry and returns its
+// sequence number, or 0 without a journal
+func (e *Exchange) journalChanges(changes *changeSet, state models.StockState, stockChanged bool) uint64 {
+	if e.journal == nil {
+		return 0
+	}
+
+	events := make([]Event, 0, len(changes.orders)+len(changes.transactions)+1)
+	for _, order := range changes.orders {
+		if changes.accepted[order] {
+			events = append(events, Event{Type: EventOrderAccepted, Order: newOrderRecord(order)})
+		}
+	}
+	for i := range changes.transactions {
+		events = append(events, Event{Type: EventFill, Transaction: &changes.transactions[i]})
+	}
+	for _, order := range changes.orders {
+		if changes.accepted[order] {
+			continue
+		}
+		eventType := EventOrderUpdated
+		if order.Status == models.Cancelled || order.Status == models.Expired {
+			eventType = EventOrderCancelled
+		}
+		events = append(events, Event{Type: eventType, Order: newOrderRecord(order)})
+	}
+	if stockChanged {
+		eventType := EventStockStatus
+		if state.CurrentPrice != changes.stock.CurrentPrice {
+			eventType = EventPriceUpdate
+		}
+		events = append(events, Event{Type: eventType, StockState: &state})
+	}
+
+	return e.journalEvent(events...)
+}
+
+// journalEvent journals events as one entry and returns its sequence
+// number, or 0 without a journal
+func (e *Exchange) journalEvent(events ...Event) uint64 {
+	if e.journal == nil {
+		return 0
+	}
+	seq, err := e.journal.Append(events...)
+	if err != nil {
+		log.Printf("Failed to journal %s: %v", events[0].Type, err)
+	}
+	return seq
+}
+
+// save queues a batch for the store
+func (e *Exchange) save(batch storage.Batch) {
+	e.writer.queue(batch)
+}
+
+// FlushStore saves every change still queued for the store, for shutdown
+func (e *Exchange) FlushStore() {
+	e.writer.flush()
+}
+
+// recordListing journals and stores a newly listed stock
+func (e *Exchange) recordListing(stock *models.Stock) {
+	seq := e.journalEvent(Event{Type: EventStockListed, Stock: stock})
+	e.save(storage.Batch{Seq: seq, Stocks: []*models.Stock{stock.Clone()}})
+}
+
+// recordTrader journals and stores a trader's account after it was opened or
+// its settings changed. The caller must hold the trader's account lock, or
+// e.tradersMu while adding them.
+func (e *Exchange) recordTrader(eventType EventType, trader *models.Trader) {
+	seq := e.journalEvent(Event{Type: eventType, Trader: newTraderRecord(trader)})
+	e.save(storage.Batch{Seq: seq, Traders: []*models.Trader{cloneTrader(trader)}})
+}
+
+func newTraderRecord(trader *models.Trader) *TraderRecord {
+	return &TraderRecord{
+		ID:                  trader.ID,
+		Name:                trader.Name,
+		InitialMoney:        trader.InitialMoney,
+		SelfTradePrevention: trader.SelfTradePrevention,
+	}
+}
+
+// replay applies a journal entry to the exchange as it is being restored,
+// saving its orders and transactions to the store again
+func (e *Exchange) replay(entry JournalEntry) error {
+	batch := storage.Batch{Seq: entry.Seq}
+	var moved *models.StockState
+	for _, event := range entry.Events {
+		if err := e.apply(event, &batch); err != nil {
+			return fmt.Errorf("%s: %v", event.Type, err)
+		}
+		if event.Type == EventPriceUpdate {
+			moved = event.StockState
+		}
+	}
+
+	e.recordValuations(batch.Transactions)
+
+	// An entry with fills or a price move is one operation on one stock
+	switch {
+	case moved != nil:
+		e.recordTicks(moved.ID, batch.Transactions, true, moved.CurrentPrice, entry.Time)
+	case len(batch.Transactions) > 0:
+		e.recordTicks(batch.Transactions[0].StockID, batch.Transactions, false, 0, entry.Time)
+	}
+	return e.store.Save(batch)
+}
+
+func (e *Exchange) apply(event Event, batch *storage.Batch) error {
+	switch event.Type {
+	case EventStockListed:
+		if event.Stock == nil {
+			return fmt.Errorf("missing stock")
+		}
+		e.addStock(event.Stock)
+
+	case EventPriceUpdate, EventStockStatus:
+		if event.StockState == nil {
+			return fmt.Errorf("missing stock state")
+		}
+		stock, exists := e.stocks[event.StockState.ID]
+		if !exists {
+			return fmt.Errorf("stock %s not listed", event.StockState.ID)
+		}
+		stock.Restore(*event.StockState)
+
+	case EventMarketPhase:
+		e.phase = event.Phase
+
+	case EventTraderRegistered, EventTraderUpdated:
+		record := event.Trader
+		if record == nil {
+			return fmt.Errorf("missing trader")
+		}
+		trader, exists := e.traders[record.ID]
+		if !exists {
+			trader = models.NewTrader(record.ID, record.Name, record.InitialMoney)
+			e.traders[record.ID] = trader
+		}
+		trader.SelfTradePrevention = record.SelfTradePrevention
+
+	case EventOrderAccepted, EventOrderUpdated, EventOrderCancelled:
+		if event.Order == nil {
+			return fmt.Errorf("missing order")
+		}
+		order := e.restoreOrder(event.Order)
+		batch.Orders = append(batch.Orders, *order)
+
+	case EventFill:
+		if event.Transaction == nil {
+			return fmt.Errorf("missing transaction")
+		}
+		e.restoreFill(*event.Transaction)
+		batch.Transactions = append(batch.Transactions, *event.Transaction)
+
+	case EventValuation:
+		if event.ValuedAt == nil {
+			return fmt.Errorf("missing valuation time")
+		}
+		e.valueTraders(*event.ValuedAt)
+
+	default:
+		return fmt.Errorf("unknown event")
+	}
+	return nil
+}
+
+// restoreOrder puts an order back among the open orders as journaled, or
+// takes it out once it is done
+func (e *Exchange) restoreOrder(record *OrderRecord) *models.Order {
+	order, exists := e.orders.get(record.ID)
+	if !exists {
+		order = &models.Order{}
+	}
+	*order = record.Order
+	order.SetArrival(record.ArrivalSeq)
+	order.SetQueued(record.QueueSeq)
+	if !exists {
+		e.orders.restore(order)
+	}
+
+	if !order.IsActive() && order.Status != models.Pending {
+		e.forget(order)
+	}
+	return order
+}
+
+// restoreFill settles a journaled transaction between the traders. The
+// exchange's own side is part of the journaled stock amount.
+func (e *Exchange) restoreFill(transaction models.Transaction) {
+	e.lastTxID = max(e.lastTxID, transaction.Number())
+
+	value := transaction.Price.Mul(transaction.Quantity)
+	if buyer, exists := e.traders[transaction.BuyerID]; exists {
+		buyer.Money -= value
+		buyer.Holdings[transaction.StockID] += transaction.Quantity
+	}
+	if seller, exists := e.traders[transaction.SellerID]; exists {
+		seller.Money += value
+		seller.Holdings[transaction.StockID] -= transaction.Quantity
+	}
+}
+
+// rebuild derives what is not journaled from the replayed state: links
+// between orders, the books, reservations and the order of traders' open
+// orders
+func (e *Exchange) rebuild() {
+	find := func(orderID string) *models.Order {
+		order, _ := e.orders.get(orderID)
+		return order
+	}
+	waiting := make(map[string][]*models.Order)
+	for _, order := range e.orders.all() {
+		order.Relink(find)
+		if order.IsActive() {
+			waiting[order.StockID] = append(waiting[order.StockID], order)
+		}
+	}
+
+	// Re-queueing in the original order rebuilds the same priority
+	for stockID, orders := range waiting {
+		sort.Slice(orders, func(i, j int) bool { return orders[i].Queued() < orders[j].Queued() })
+		for _, order := range orders {
+			if order.IsStop() && !order.IsTriggered() {
+				e.stopBooks[stockID].Add(order)
+			} else {
+				e.books[stockID].Add(order)
+			}
+		}
+	}
+
+	for _, trader := range e.traders {
+		trader.ReservedCash = 0
+		trader.ReservedShares = make(map[string]int)
+	}
+	for _, orders := range waiting {
+		for _, order := range orders {
+			if trader, exists := e.traders[order.TraderID]; exists {
+				trader.ReservedCash += order.ReservedCash
+				trader.ReservedShares[order.StockID] += order.ReservedShares
+			}
+		}
+	}
+
+	for stockID, stock := range e.stocks {
+		e.changes[stockID].stock = stock.State()
+	}
+}
+
+// loadOrderIDs takes the IDs of every order in the store, closed ones
+// included, so they stay taken
+func (e *Exchange) loadOrderIDs() error {
+	orderIDs, err := e.store.OrderIDs()
+	if err != nil {
+		return err
+	}
+	for _, orderID := range orderIDs {
+		e.orders.take(orderID)
+	}
+	return nil
+}
+
+// resync returns the restored stocks, traders and open orders as a batch, to
+// save over whatever the store lost or had ahead of the journal
+func (e *Exchange) resync(seq uint64) storage.Batch {
+	batch := storage.Batch{Seq: seq}
+	for _, stock := range e.stocks {
+		batch.Stocks = append(batch.Stocks, stock.Clone())
+	}
+	for _, trader := range e.traders {
+		batch.Traders = append(batch.Traders, cloneTrader(trader))
+	}
+	for _, order := range e.orders.all() {
+		batch.Orders = append(batch.Orders, *order)
+	}
+	return batch
+}
+
+// resumeHalts schedules the reopening of stocks that were halted when the
+// exchange stopped
+func (e *Exchange) resumeHalts() {
+	for stockID, stock := range e.stocks {
+		state := stock.State()
+		if state.Status != models.Halted || state.HaltedUntil == nil {
+			continue
+		}
+		stockID := stockID
+		time.AfterFunc(time.Until(*state.HaltedUntil), func() {
+			e.resume(stockID, time.Now())
+		})
+	}
+}
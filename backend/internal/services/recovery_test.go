@@ -0,0 +1,352 @@
+package services
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"stock-exchange/internal/models"
+	"stock-exchange/internal/storage"
+	"strings"
+	"testing"
+	"time"
+)
+
+const recoveryConfig = `{
+	"shares": [
+		{ "id": "1", "name": "Apple Inc.", "currentPrice": 150.0, "amount": 1000 },
+		{ "id": "2", "name": "Microsoft Corp.", "currentPrice": 300.0, "amount": 500, "supplyPeak": 100 }
+	],
+	"traders": [
+		{ "id": "trader1", "name": "John Doe", "money": 10000.0 },
+		{ "id": "trader2", "name": "Jane Smith", "money": 15000.0 }
+	]
+}`
+
+// openJournaledExchange recovers an exchange journaling and snapshotting to
+// dir, loading the config on first boot
+func openJournaledExchange(t *testing.T, dir string) (*Exchange, *Journal) {
+	t.Helper()
+	return openJournaledStore(t, dir, storage.NewMemoryStore())
+}
+
+// openJournaledStore is openJournaledExchange with the given store
+func openJournaledStore(t *testing.T, dir string, store storage.Store) (*Exchange, *Journal) {
+	t.Helper()
+	config := filepath.Join(dir, "config.json")
+	if _, err := os.Stat(config); err != nil {
+		if err := os.WriteFile(config, []byte(recoveryConfig), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	journal, err := OpenJournal(filepath.Join(dir, "journal.jsonl"), time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	snapshots, err := OpenSnapshotStore(filepath.Join(dir, "snapshots"))
+	if err != nil {
+		t.Fatalf("Failed to open snapshot store: %v", err)
+	}
+	exchange := NewExchangeWithStore(store)
+	if err := exchange.Recover(config, journal, snapshots); err != nil {
+		t.Fatalf("Failed to recover exchange: %v", err)
+	}
+	return exchange, journal
+}
+
+// dumpState describes everything the journal restores, for comparing exchanges
+func dumpState(t *testing.T, e *Exchange) string {
+	t.Helper()
+	type traderState struct {
+		Money          models.Money
+		Holdings       map[string]int
+		ReservedCash   models.Money
+		ReservedShares map[string]int
+		Mode           models.SelfTradePrevention
+	}
+	nonZero := func(counts map[string]int) map[string]int {
+		kept := make(map[string]int)
+		for key, count := range counts {
+			if count != 0 {
+				kept[key] = count
+			}
+		}
+		return kept
+	}
+	ids := func(orders []*models.Order) []string {
+		list := make([]string, 0, len(orders))
+		for _, order := range orders {
+			list = append(list, order.ID)
+		}
+		return list
+	}
+
+	state := map[string]any{
+		"phase":    e.phase,
+		"records":  e.store.(*storage.MemoryStore).Dump(math.MaxUint64, 0),
+		"lastTxID": e.lastTxID,
+		"arrivals": e.orders.arrivals.Load(),
+	}
+	orders := make(map[string]any)
+	traderOrders := make(map[string][]string)
+	for _, order := range e.orders.all() {
+		orders[order.ID] = []any{order, order.Arrival(), order.OCOLeg() != nil, len(order.Exits())}
+		traderOrders[order.TraderID] = ids(e.orders.traderOrders(order.TraderID))
+	}
+	state["traderOrders"] = traderOrders
+	traders := make(map[string]traderState)
+	for id, trader := range e.traders {
+		traders[id] = traderState{trader.Money, nonZero(trader.Holdings), trader.ReservedCash, nonZero(trader.ReservedShares), trader.SelfTradePrevention}
+	}
+	state["traders"] = traders
+	stocks := make(map[string]any)
+	for id, stock := range e.stocks {
+		stocks[id] = []any{stock.State(), ids(e.books[id].Bids()), ids(e.books[id].Asks()), ids(e.stopBooks[id].Orders())}
+	}
+	state["stocks"] = stocks
+
+	data, err := json.MarshalIndent(state, "", " ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// dumpHistory describes the daily candles of every stock and traders'
+// equity curves, for comparing exchanges. Finer candles can differ by where a
+// price move's tick falls, as it is recorded at its journal entry's time on
+// replay.
+func dumpHistory(t *testing.T, e *Exchange) string {
+	t.Helper()
+	history := make(map[string][]models.Candle)
+	for id := range e.stocks {
+		candles, err := e.GetStockHistory(id, Interval1d, time.Time{}, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		history[id] = candles
+	}
+	data, err := json.Marshal(map[string]any{"candles": history, "equity": e.equity.dump()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// Test recovery - replaying the journal rebuilds the exchange exactly
+func TestRecover_RebuildsState(t *testing.T) {
+	dir := t.TempDir()
+	exchange, journal := openJournaledExchange(t, dir)
+
+	// Fills against the exchange's supply, an iceberg order and an order queued behind it
+	exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 150.0, 10))
+	exchange.PlaceOrder(createTestOrder("buy2", "trader2", "1", models.Buy, 150.0, 20))
+	iceberg := createTestOrder("ice1", "trader2", "1", models.Sell, 148.0, 10)
+	iceberg.DisplayQuantity = 5
+	exchange.PlaceOrder(iceberg)
+	exchange.PlaceOrder(createTestOrder("sell1", "trader1", "1", models.Sell, 148.0, 5))
+	exchange.PlaceOrder(createTestOrder("buy3", "trader1", "1", models.Buy, 148.0, 3))
+
+	// A trailing stop, a one-cancels-other pair and a cancelled order
+	stop := createTestOrder("trail1", "trader2", "1", models.Sell, 0, 5)
+	stop.Kind = models.Stop
+	stop.TrailAmount = money(2.0)
+	exchange.PlaceOrder(stop)
+	takeProfit := createTestOrder("tp1", "trader1", "1", models.Sell, 170.0, 2)
+	stopLoss := createTestOrder("sl1", "trader1", "1", models.Sell, 0, 2)
+	stopLoss.Kind = models.Stop
+	stopLoss.StopPrice = money(140.0)
+	if err := exchange.PlaceOCO(takeProfit, stopLoss); err != nil {
+		t.Fatalf("Failed to place OCO orders: %v", err)
+	}
+	exchange.PlaceOrder(createTestOrder("buy4", "trader1", "2", models.Buy, 290.0, 1))
+	exchange.CancelOrder("buy4")
+
+	exchange.UpdatePrice("2", money(310.0))
+	exchange.RegisterTrader("trader3", "New Trader", money(5000.0))
+	exchange.SetSelfTradePrevention("trader1", models.CancelOldest)
+	exchange.RecordValuations()
+
+	before := dumpState(t, exchange)
+	history := dumpHistory(t, exchange)
+	if err := journal.Close(); err != nil {
+		t.Fatalf("Failed to close journal: %v", err)
+	}
+
+	// The config is only used on first boot
+	os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"shares": [], "traders": []}`), 0o644)
+
+	restored, journal := openJournaledExchange(t, dir)
+	defer journal.Close()
+	if after := dumpState(t, restored); after != before {
+		t.Fatalf("Expected the restored exchange to match\nbefore: %s\nafter: %s", before, after)
+	}
+	if after := dumpHistory(t, restored); after != history {
+		t.Errorf("Expected the price history and equity curves to be restored\nbefore: %s\nafter: %s", history, after)
+	}
+
+	// Closed orders' IDs stay taken
+	if err := restored.PlaceOrder(createTestOrder("buy4", "trader1", "2", models.Buy, 290.0, 1)); err == nil {
+		t.Error("Expected the cancelled order's ID to be rejected after a restart")
+	}
+
+	// Trading carries on from the restored book, in the same queue order
+	restored.PlaceOrder(createTestOrder("buy5", "trader3", "1", models.Buy, 148.0, 2))
+	if ice1, _ := restored.GetOrder("ice1"); ice1.FilledQuantity != 5 {
+		t.Errorf("Expected the iceberg order's peak to trade first, got %d filled", ice1.FilledQuantity)
+	}
+	if sell1, _ := restored.GetOrder("sell1"); sell1.FilledQuantity != 0 {
+		t.Errorf("Expected the order queued behind the iceberg order not to trade, got %d filled", sell1.FilledQuantity)
+	}
+
+	// Linked orders are linked again
+	restored.CancelOrder("sl1")
+	if tp1, _ := restored.GetOrder("tp1"); tp1.Status != models.Cancelled {
+		t.Errorf("Expected cancelling the stop-loss to cancel the take-profit, got %v", tp1.Status)
+	}
+}
+
+// Test snapshots - recovery loads the snapshot and replays the journal after it
+func TestTakeSnapshot_TruncatesJournal(t *testing.T) {
+	dir := t.TempDir()
+	exchange, journal := openJournaledExchange(t, dir)
+
+	exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 150.0, 10))
+	exchange.PlaceOrder(createTestOrder("sell1", "trader1", "1", models.Sell, 155.0, 5))
+	exchange.PlaceOrder(createTestOrder("buy2", "trader2", "2", models.Buy, 290.0, 4))
+	info, err := exchange.TakeSnapshot()
+	if err != nil {
+		t.Fatalf("Failed to take snapshot: %v", err)
+	}
+	if info.Seq != journal.Seq() {
+		t.Errorf("Expected the snapshot to cover entry %d, got %d", journal.Seq(), info.Seq)
+	}
+	if again, _ := exchange.TakeSnapshot(); again.Path != info.Path {
+		t.Errorf("Expected no new snapshot without new entries, got %s", again.Path)
+	}
+
+	// Changes after the snapshot come from the journal
+	exchange.PlaceOrder(createTestOrder("buy3", "trader2", "1", models.Buy, 155.0, 3))
+	exchange.CancelOrder("buy2")
+	exchange.RegisterTrader("trader3", "New Trader", money(500.0))
+	before := dumpState(t, exchange)
+	history := dumpHistory(t, exchange)
+	journal.Close()
+
+	// The entries the snapshot covers are gone
+	var replayed []uint64
+	collect := func(entry JournalEntry) error {
+		replayed = append(replayed, entry.Seq)
+		return nil
+	}
+	if err := journal.Replay(0, collect); err == nil {
+		t.Error("Expected replaying from the start to report missing entries")
+	}
+	replayed = nil
+	if err := journal.Replay(info.Seq, collect); err != nil || len(replayed) == 0 || replayed[0] != info.Seq+1 {
+		t.Errorf("Expected the journal to start after entry %d, got %v (%v)", info.Seq, replayed, err)
+	}
+
+	restored, journal := openJournaledExchange(t, dir)
+	defer journal.Close()
+	if after := dumpState(t, restored); after != before {
+		t.Fatalf("Expected the restored exchange to match\nbefore: %s\nafter: %s", before, after)
+	}
+	if after := dumpHistory(t, restored); after != history {
+		t.Errorf("Expected the price history and equity curves to be restored\nbefore: %s\nafter: %s", history, after)
+	}
+
+	// Sequence numbers carry on after a snapshot covering the whole journal
+	seq := journal.Seq()
+	if _, err := restored.TakeSnapshot(); err != nil {
+		t.Fatalf("Failed to take snapshot: %v", err)
+	}
+	restored.UpdatePrice("1", money(151.0))
+	if journal.Seq() != seq+1 {
+		t.Errorf("Expected entry %d next, got %d", seq+1, journal.Seq())
+	}
+	names, _ := filepath.Glob(filepath.Join(dir, "snapshots", "*"))
+	if len(names) != 1 {
+		t.Errorf("Expected only the latest snapshot to be kept, got %v", names)
+	}
+}
+
+// durableStore hides MemoryStore's Dump and Load, so it outlives a restart
+// the way a database does instead of coming back from the snapshot
+type durableStore struct {
+	storage.Store
+}
+
+// Test snapshots - a store that outlives restarts keeps the rows the snapshot covers
+func TestRecover_KeepsDurableStoreAfterSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	store := durableStore{storage.NewMemoryStore()}
+	exchange, journal := openJournaledStore(t, dir, store)
+
+	exchange.PlaceOrder(createTestOrder("buy1", "trader2", "1", models.Buy, 150.0, 5))
+	exchange.PlaceOrder(createTestOrder("buy2", "trader1", "1", models.Buy, 140.0, 5))
+	exchange.CancelOrder("buy2")
+	if _, err := exchange.TakeSnapshot(); err != nil {
+		t.Fatalf("Failed to take snapshot: %v", err)
+	}
+	journal.Close()
+
+	restored, journal := openJournaledStore(t, dir, store)
+	defer journal.Close()
+	if order, exists := restored.GetOrder("buy1"); !exists || order.Status != models.Filled {
+		t.Errorf("Expected buy1 to be kept as filled, got %v (%v)", order.Status, exists)
+	}
+	if order, exists := restored.GetOrder("buy2"); !exists || order.Status != models.Cancelled {
+		t.Errorf("Expected buy2 to be kept as cancelled, got %v (%v)", order.Status, exists)
+	}
+	if transactions, _ := store.TraderTransactions("trader2", 0); len(transactions) != 1 {
+		t.Errorf("Expected the trade to be kept, got %d transactions", len(transactions))
+	}
+	if err := restored.PlaceOrder(createTestOrder("buy1", "trader2", "1", models.Buy, 150.0, 5)); err == nil {
+		t.Error("Expected buy1's ID to stay taken after the restart")
+	}
+}
+
+// Test journal - a torn last entry is dropped, a bad entry before others is corruption
+func TestJournal_TornEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	journal, err := OpenJournal(path, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	journal.Append(Event{Type: EventMarketPhase, Phase: models.PhasePreOpen})
+	journal.Append(Event{Type: EventMarketPhase, Phase: models.PhaseOpen})
+	journal.Close()
+
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	file.WriteString(`{"seq":3,"time":"2024-01-`)
+	file.Close()
+
+	journal, err = OpenJournal(path, time.Hour)
+	if err != nil {
+		t.Fatalf("Expected a torn entry to be dropped: %v", err)
+	}
+	if journal.Seq() != 2 {
+		t.Errorf("Expected 2 entries, got %d", journal.Seq())
+	}
+	journal.Append(Event{Type: EventMarketPhase, Phase: models.PhaseClosing})
+	journal.Close()
+
+	var phases []models.MarketPhase
+	journal.Replay(1, func(entry JournalEntry) error {
+		phases = append(phases, entry.Events[0].Phase)
+		return nil
+	})
+	if len(phases) != 2 || phases[0] != models.PhaseOpen || phases[1] != models.PhaseClosing {
+		t.Errorf("Expected to replay the entries after the first, got %v", phases)
+	}
+
+	data, _ := os.ReadFile(path)
+	lines := strings.SplitAfter(string(data), "\n")
+	lines[0] = "not json\n"
+	os.WriteFile(path, []byte(strings.Join(lines, "")), 0o644)
+	if _, err := OpenJournal(path, time.Hour); err == nil {
+		t.Error("Expected a bad entry followed by others to be reported")
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"stock-exchange/internal/models"
+)
+
+// Every open order holds back what it needs from its trader: a buy order
+// reserves the cash it may spend and a sell order the shares it may deliver.
+// Reservations are taken when an order is accepted, consumed as it fills and
+// released when it is cancelled, expires or finishes with cash left over.
+// The exchange's own orders are not limited and reserve nothing. The two
+// legs of a one-cancels-other pair on the same side share one reservation,
+// since only one of them can fill.
+//
+// Reservations change trader state shared by every stock, so reserve,
+// release and consume must be called with the trader's account lock held,
+// together with the order's stock lock.
+
+// requiredCash returns what a buy order has to reserve for its remaining quantity
+func (e *Exchange) requiredCash(order *models.Order) models.Money {
+	if !order.IsMarket() {
+		return order.Price.Mul(order.Quantity)
+	}
+	if order.IsStop() && !order.IsTriggered() {
+		// Untriggered stops are priced at their stop
+		return order.StopPrice.Mul(order.Quantity)
+	}
+	return e.estimateMarketCost(order)
+}
+
+// sharedLeg returns the other leg of an order's one-cancels-other pair if
+// the two share a reservation: they are on the same side and both open.
+// Whichever leg reserves second only holds back what the first does not
+// already cover.
+func sharedLeg(order *models.Order) *models.Order {
+	leg := order.OCOLeg()
+	if leg == nil || leg.Type != order.Type || !leg.IsActive() {
+		return nil
+	}
+	return leg
+}
+
+// reserve commits the trader's cash or shares to an accepted order
+func (e *Exchange) reserve(order *models.Order) {
+	trader, _ := e.trader(order.TraderID)
+	if trader == nil {
+		return
+	}
+	leg := sharedLeg(order)
+
+	if order.Type == models.Buy {
+		order.ReservedCash = e.requiredCash(order)
+		if leg != nil {
+			order.ReservedCash -= leg.ReservedCash
+			if order.ReservedCash < 0 {
+				order.ReservedCash = 0
+			}
+		}
+		trader.ReservedCash += order.ReservedCash
+		return
+	}
+
+	if trader.ReservedShares == nil {
+		trader.ReservedShares = make(map[string]int)
+	}
+	order.ReservedShares = order.Quantity
+	if leg != nil {
+		order.ReservedShares -= min(leg.ReservedShares, order.Quantity)
+	}
+	trader.ReservedShares[order.StockID] += order.ReservedShares
+}
+
+// release gives back whatever an order still has reserved. It is safe to
+// call more than once.
+func (e *Exchange) release(order *models.Order) {
+	trader, _ := e.trader(order.TraderID)
+	if trader == nil {
+		return
+	}
+
+	if order.Type == models.Buy {
+		trader.ReservedCash -= order.ReservedCash
+		order.ReservedCash = 0
+		return
+	}
+
+	if order.ReservedShares == 0 {
+		return
+	}
+	trader.ReservedShares[order.StockID] -= order.ReservedShares
+	if trader.ReservedShares[order.StockID] <= 0 {
+		delete(trader.ReservedShares, order.StockID)
+	}
+	order.ReservedShares = 0
+}
+
+// consume takes a fill of quantity shares out of the order's reservation. It
+// must run before the fill is applied to the order's remaining quantity. A
+// buy order uses up its reservation pro rata and the last fill takes whatever
+// is left, so cash saved by filling below the limit is freed once it is done.
+func (e *Exchange) consume(order *models.Order, quantity int) {
+	trader, _ := e.trader(order.TraderID)
+	if trader == nil {
+		return
+	}
+
+	if order.Type == models.Buy {
+		portion := order.ReservedCash.Mul(quantity).Div(order.Quantity)
+		if quantity == order.Quantity {
+			portion = order.ReservedCash // Whatever rounding left over goes with the last fill
+		}
+		order.ReservedCash -= portion
+		trader.ReservedCash -= portion
+		return
+	}
+
+	if order.ReservedShares == 0 {
+		return
+	}
+	order.ReservedShares -= quantity
+	trader.ReservedShares[order.StockID] -= quantity
+	if trader.ReservedShares[order.StockID] <= 0 {
+		delete(trader.ReservedShares, order.StockID)
+	}
+}
+
+// closeOrder moves an order that will no longer trade to its final status,
+// releases anything it still had reserved and settles the orders linked to
+// it. The caller must hold the order's stock lock but no account lock.
+func (e *Exchange) closeOrder(order *models.Order, status models.OrderStatus) {
+	account := e.accountLock(order.TraderID)
+	account.Lock()
+	e.release(order)
+	account.Unlock()
+	order.SetStatus(status)
+	e.touch(order)
+	e.closeLinked(order)
+}
+
+// TraderBalance is a trader's cash and shares split into what is free to
+// trade and what is reserved by open orders
+type TraderBalance struct {
+	AvailableCash   models.Money   `json:"availableCash"`
+	ReservedCash    models.Money   `json:"reservedCash"`
+	AvailableShares map[string]int `json:"availableShares"`
+	ReservedShares  map[string]int `json:"reservedShares"`
+}
+
+// GetTraderBalance returns a snapshot of a trader's available and reserved funds
+func (e *Exchange) GetTraderBalance(traderID string) (TraderBalance, bool) {
+	trader, exists := e.trader(traderID)
+	if !exists {
+		return TraderBalance{}, false
+	}
+	account := e.accountLock(traderID)
+	account.RLock()
+	defer account.RUnlock()
+
+	balance := TraderBalance{
+		AvailableCash:   trader.AvailableCash(),
+		ReservedCash:    trader.ReservedCash,
+		AvailableShares: make(map[string]int),
+		ReservedShares:  make(map[string]int),
+	}
+	for stockID := range trader.Holdings {
+		balance.AvailableShares[stockID] = trader.AvailableShares(stockID)
+	}
+	for stockID, quantity := range trader.ReservedShares {
+		balance.ReservedShares[stockID] = quantity
+	}
+
+	return balance, true
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"stock-exchange/internal/models"
+	"testing"
+	"time"
+)
+
+// Test reservations - buy orders cannot commit more cash than the trader has
+func TestReserveCash_PreventsOvercommit(t *testing.T) {
+	exchange := createTestExchange()
+
+	// trader1 has 10000: the first buy reserves 6000, leaving 4000
+	if err := exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 100.0, 60)); err != nil {
+		t.Fatalf("First buy failed: %v", err)
+	}
+
+	err := exchange.PlaceOrder(createTestOrder("buy2", "trader1", "2", models.Buy, 100.0, 50))
+	if err == nil || err.Error() != "insufficient funds" {
+		t.Errorf("Expected insufficient funds for the second buy, got %v", err)
+	}
+
+	trader := exchange.traders["trader1"]
+	if trader.ReservedCash != money(6000.0) {
+		t.Errorf("Expected 6000 reserved, got %s", trader.ReservedCash)
+	}
+	if trader.AvailableCash() != money(4000.0) {
+		t.Errorf("Expected 4000 available, got %s", trader.AvailableCash())
+	}
+
+	// Cancelling releases the reservation
+	exchange.CancelOrder("buy1")
+	if trader.ReservedCash != 0 {
+		t.Errorf("Expected no cash reserved after cancel, got %s", trader.ReservedCash)
+	}
+	if err := exchange.PlaceOrder(createTestOrder("buy3", "trader1", "2", models.Buy, 100.0, 50)); err != nil {
+		t.Errorf("Expected buy to succeed after cancel, got %v", err)
+	}
+}
+
+// Test reservations - fills consume the reservation and cash never goes negative
+func TestReserveCash_ConsumedOnFill(t *testing.T) {
+	exchange := createTestExchange()
+
+	exchange.traders["trader2"].Holdings["1"] = 100
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 90.0, 40))
+
+	// Buy 100 at 100: 40 fill at 90, the rest rests with 60 * 100 reserved
+	exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 100.0, 100))
+
+	trader := exchange.traders["trader1"]
+	if trader.Money != money(10000.0-3600.0) {
+		t.Errorf("Expected money 6400, got %s", trader.Money)
+	}
+	if trader.ReservedCash != money(6000.0) {
+		t.Errorf("Expected 6000 reserved for the remainder, got %s", trader.ReservedCash)
+	}
+
+	// Filling the rest below the limit frees the saved cash
+	exchange.PlaceOrder(createTestOrder("sell2", "trader2", "1", models.Sell, 95.0, 60))
+
+	order, _ := exchange.GetOrder("buy1")
+	if order.Status != models.Filled {
+		t.Fatalf("Expected buy1 filled, got %v", order.Status)
+	}
+	if trader.ReservedCash != 0 {
+		t.Errorf("Expected no cash reserved once filled, got %s", trader.ReservedCash)
+	}
+	if trader.Money != money(10000.0-3600.0-6000.0) {
+		t.Errorf("Expected money 400, got %s", trader.Money)
+	}
+	if trader.Money < 0 {
+		t.Error("Money went negative")
+	}
+}
+
+// Test reservations - sell orders reserve shares until filled, cancelled or expired
+func TestReserveShares(t *testing.T) {
+	exchange := createTestExchange()
+
+	trader := exchange.traders["trader1"]
+	trader.Holdings["1"] = 100
+
+	exchange.PlaceOrder(createTestOrder("sell1", "trader1", "1", models.Sell, 200.0, 60))
+	if trader.ReservedShares["1"] != 60 || trader.AvailableShares("1") != 40 {
+		t.Errorf("Expected 60 reserved and 40 available, got %d and %d",
+			trader.ReservedShares["1"], trader.AvailableShares("1"))
+	}
+
+	// Partial fill consumes the reservation
+	exchange.PlaceOrder(createTestOrder("buy1", "trader2", "1", models.Buy, 200.0, 20))
+	if trader.Holdings["1"] != 80 || trader.ReservedShares["1"] != 40 {
+		t.Errorf("Expected 80 held and 40 reserved, got %d and %d", trader.Holdings["1"], trader.ReservedShares["1"])
+	}
+
+	// Expiry releases what is left
+	gtd := createTestOrder("sell2", "trader1", "1", models.Sell, 250.0, 40)
+	expiresAt := time.Now().Add(time.Hour)
+	gtd.TimeInForce = models.GTD
+	gtd.ExpiresAt = &expiresAt
+	if err := exchange.PlaceOrder(gtd); err != nil {
+		t.Fatalf("GTD sell failed: %v", err)
+	}
+	exchange.CancelOrder("sell1")
+	exchange.ExpireOrders(expiresAt.Add(time.Second))
+
+	if trader.ReservedShares["1"] != 0 || trader.AvailableShares("1") != 80 {
+		t.Errorf("Expected nothing reserved and 80 available, got %d and %d",
+			trader.ReservedShares["1"], trader.AvailableShares("1"))
+	}
+}
+
+// Test reservations - amending moves the reservation with the order
+func TestReserveCash_Amend(t *testing.T) {
+	exchange := createTestExchange()
+
+	exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 100.0, 50))
+
+	// Raising the order to 90 shares fits the 10000 only because its own 5000 is released first
+	if _, err := exchange.AmendOrder("buy1", 0, 90); err != nil {
+		t.Fatalf("Amend failed: %v", err)
+	}
+	trader := exchange.traders["trader1"]
+	if trader.ReservedCash != money(9000.0) {
+		t.Errorf("Expected 9000 reserved, got %s", trader.ReservedCash)
+	}
+
+	// A rejected amend keeps the original reservation
+	if _, err := exchange.AmendOrder("buy1", 0, 200); err == nil {
+		t.Error("Expected amend beyond available cash to fail")
+	}
+	if trader.ReservedCash != money(9000.0) {
+		t.Errorf("Expected reservation unchanged at 9000, got %s", trader.ReservedCash)
+	}
+}
+
+// Test GetTraderBalance
+func TestGetTraderBalance(t *testing.T) {
+	exchange := createTestExchange()
+
+	exchange.traders["trader1"].Holdings["2"] = 10
+	exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 100.0, 10))
+	exchange.PlaceOrder(createTestOrder("sell1", "trader1", "2", models.Sell, 400.0, 4))
+
+	balance, exists := exchange.GetTraderBalance("trader1")
+	if !exists {
+		t.Fatal("Expected trader balance")
+	}
+
+	if balance.AvailableCash != money(9000.0) || balance.ReservedCash != money(1000.0) {
+		t.Errorf("Expected 9000 available and 1000 reserved, got %s and %s", balance.AvailableCash, balance.ReservedCash)
+	}
+	if balance.AvailableShares["2"] != 6 || balance.ReservedShares["2"] != 4 {
+		t.Errorf("Expected 6 available and 4 reserved shares, got %d and %d",
+			balance.AvailableShares["2"], balance.ReservedShares["2"])
+	}
+
+	if _, exists := exchange.GetTraderBalance("missing"); exists {
+		t.Error("Expected no balance for an unknown trader")
+	}
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"log"
+	"stock-exchange/internal/models"
+	"time"
+)
+
+// The market moves through its daily phases on the calendar loaded from
+// config.json; without one it stays open around the clock. Each phase change
+// is applied to every stock under its own lock:
+//
+//   - pre-open: stocks with an opening auction collect orders that can rest
+//     without matching them, the others only take cancellations
+//   - open: the collected orders are uncrossed in the opening auction and
+//     continuous trading starts
+//   - closing: stocks with a closing auction collect orders again, the
+//     others only take cancellations
+//   - closed: the closing auction is uncrossed and each stock's last price
+//     becomes its closing price. Nothing is accepted but cancellations, and
+//     DAY orders expire.
+
+// MarketStatus is the market's current phase and what comes next
+type MarketStatus struct {
+	Phase       models.MarketPhase `json:"phase"`
+	Time        time.Time          `json:"time"`
+	TimeZone    string             `json:"timeZone"`
+	NextPhase   models.MarketPhase `json:"nextPhase,omitempty"`
+	NextPhaseAt *time.Time         `json:"nextPhaseAt,omitempty"`
+}
+
+// MarketPhase returns the phase the market is in
+func (e *Exchange) MarketPhase() models.MarketPhase {
+	e.sessionMu.RLock()
+	defer e.sessionMu.RUnlock()
+	return e.phase
+}
+
+// IsMarketOpen reports whether the market is in continuous trading
+func (e *Exchange) IsMarketOpen() bool {
+	return e.MarketPhase() == models.PhaseOpen
+}
+
+// GetMarketStatus describes the market phase at now
+func (e *Exchange) GetMarketStatus(now time.Time) MarketStatus {
+	status := MarketStatus{Phase: e.MarketPhase(), Time: now, TimeZone: now.Location().String()}
+	if e.calendar == nil {
+		return status
+	}
+
+	status.Time = now.In(e.calendar.Location())
+	status.TimeZone = e.calendar.Location().String()
+	if next, at, ok := e.calendar.NextChange(now); ok {
+		status.NextPhase = next
+		status.NextPhaseAt = &at
+	}
+	return status
+}
+
+// AdvanceSession moves the market to the phase its calendar gives for now
+// and applies the change to every stock. It reports whether the phase changed.
+func (e *Exchange) AdvanceSession(now time.Time) bool {
+	phase := models.PhaseOpen
+	if e.calendar != nil {
+		phase = e.calendar.PhaseAt(now)
+	}
+
+	e.sessionMu.Lock()
+	changed := phase != e.phase
+	e.phase = phase
+	if changed {
+		e.journalEvent(Event{Type: EventMarketPhase, Phase: phase})
+	}
+	e.sessionMu.Unlock()
+	if !changed {
+		return false
+	}
+
+	log.Printf("🔔 Market is now %s", phase)
+	for stockID := range e.stocks {
+		e.enterPhase(stockID, phase)
+	}
+	if phase == models.PhaseClosed {
+		e.ExpireOrders(now)
+	}
+
+	e.broadcast(Update{Type: "marketStatus", Data: e.GetMarketStatus(now)})
+	return true
+}
+
+// enterPhase moves one stock to the trading status of a market phase
+func (e *Exchange) enterPhase(stockID string, phase models.MarketPhase) {
+	defer e.lockStock(stockID)()
+
+	stock := e.stocks[stockID]
+	switch phase {
+	case models.PhasePreOpen:
+		if stock.Auctions.Opening {
+			stock.SetStatus(models.OpeningAuction)
+			e.publishIndicative(stockID)
+		} else {
+			stock.SetStatus(models.CancelOnly)
+		}
+	case models.PhaseOpen:
+		// A halt that ran into the close can leave a crossed book behind
+		// even without an opening auction
+		if stock.GetStatus() == models.OpeningAuction || e.books[stockID].Crossed() {
+			e.uncross(stockID, AuctionOpening)
+		}
+		stock.SetStatus(models.Trading)
+		e.processTriggers(stockID)
+	case models.PhaseClosing:
+		if stock.Auctions.Closing {
+			stock.SetStatus(models.ClosingAuction)
+			e.publishIndicative(stockID)
+		} else {
+			stock.SetStatus(models.CancelOnly)
+		}
+	case models.PhaseClosed:
+		if stock.GetStatus() == models.ClosingAuction {
+			e.uncross(stockID, AuctionClosing)
+		}
+		stock.SetClosingPrice(stock.GetPrice())
+		stock.SetStatus(models.Closed)
+	}
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"stock-exchange/internal/models"
+	"testing"
+	"time"
+)
+
+// Test market sessions - orders accepted per phase through a trading day
+func TestAdvanceSession_TradingDay(t *testing.T) {
+	exchange := createTestExchange()
+	exchange.calendar = createTestCalendar(t)
+	exchange.traders["trader2"].Holdings["1"] = 100
+	exchange.stocks["1"].Auctions.Opening = true
+	newYork := exchange.calendar.Location()
+
+	// DAY orders take their expiry from the real clock, so run through the
+	// trading day they will expire on
+	close, _ := exchange.calendar.NextClose(time.Now())
+	year, month, date := close.Date()
+	day := func(hour, minute int) time.Time { return time.Date(year, month, date, hour, minute, 0, 0, newYork) }
+
+	sub := exchange.Subscribe()
+	defer exchange.Unsubscribe(sub)
+
+	if !exchange.AdvanceSession(day(9, 5)) || exchange.MarketPhase() != models.PhasePreOpen {
+		t.Fatalf("Expected the market to move to pre-open, got %s", exchange.MarketPhase())
+	}
+	if exchange.AdvanceSession(day(9, 6)) {
+		t.Error("Expected no change within the same phase")
+	}
+
+	// Pre-open collects resting orders without matching them, for stocks
+	// with an opening auction
+	if err := exchange.PlaceOrder(createTestOrder("buy0", "trader1", "2", models.Buy, 300.0, 1)); err == nil {
+		t.Error("Expected a stock without an opening auction to reject orders in pre-open")
+	}
+	market := createTestOrder("market1", "trader1", "1", models.Buy, 0, 5)
+	market.Kind = models.Market
+	if err := exchange.PlaceOrder(market); err == nil {
+		t.Error("Expected a market order to be rejected in pre-open")
+	}
+	day1 := createTestOrder("buy1", "trader1", "1", models.Buy, 151.0, 10)
+	day1.TimeInForce = models.Day
+	exchange.PlaceOrder(day1)
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 149.0, 4))
+	if len(transactionLog(exchange)) != 0 {
+		t.Fatal("Expected no trades during pre-open")
+	}
+	if !day1.ExpiresAt.Equal(day(16, 0)) {
+		t.Errorf("Expected the DAY order to expire at the calendar close, got %s", day1.ExpiresAt)
+	}
+
+	// The open uncrosses the collected orders
+	exchange.AdvanceSession(day(9, 30))
+	if day1.FilledQuantity != 4 || len(transactionLog(exchange)) != 1 {
+		t.Errorf("Expected 4 shares to trade at the open, got %d", day1.FilledQuantity)
+	}
+	if transactionLog(exchange)[0].Price != money(149.0) {
+		t.Errorf("Expected the opening auction to clear at 149.00, got %s", transactionLog(exchange)[0].Price)
+	}
+	if err := exchange.PlaceOrder(createTestOrder("buy2", "trader1", "1", models.Buy, 140.0, 1)); err != nil {
+		t.Errorf("Expected orders to be accepted while open: %v", err)
+	}
+
+	// Closing only takes cancellations
+	exchange.AdvanceSession(day(15, 50))
+	if err := exchange.PlaceOrder(createTestOrder("buy3", "trader1", "1", models.Buy, 140.0, 1)); err == nil {
+		t.Error("Expected new orders to be rejected while closing")
+	}
+	if _, err := exchange.AmendOrder("buy2", money(141.0), 0); err == nil {
+		t.Error("Expected amendments to be rejected while closing")
+	}
+	if err := exchange.CancelOrder("buy2"); err != nil {
+		t.Errorf("Expected cancellations to be accepted while closing: %v", err)
+	}
+
+	// The close expires DAY orders
+	exchange.AdvanceSession(day(16, 0))
+	if day1.Status != models.Expired {
+		t.Errorf("Expected the DAY order to expire at the close, got %v", day1.Status)
+	}
+	if err := exchange.PlaceOrder(createTestOrder("buy4", "trader1", "1", models.Buy, 140.0, 1)); err == nil {
+		t.Error("Expected orders to be rejected while closed")
+	}
+
+	phases := 0
+	for len(sub.GetChannel()) > 0 {
+		if update := <-sub.GetChannel(); update.Type == "marketStatus" {
+			phases++
+		}
+	}
+	if phases != 4 {
+		t.Errorf("Expected 4 marketStatus updates, got %d", phases)
+	}
+}
+
+// Test market sessions - the closing auction sets the closing price
+func TestAdvanceSession_ClosingAuction(t *testing.T) {
+	exchange := createTestExchange()
+	exchange.calendar = createTestCalendar(t)
+	exchange.traders["trader2"].Holdings["1"] = 100
+	exchange.stocks["1"].Auctions.Closing = true
+	day := func(hour, minute int) time.Time {
+		return time.Date(2026, 10, 16, hour, minute, 0, 0, exchange.calendar.Location())
+	}
+
+	exchange.AdvanceSession(day(9, 30))
+	exchange.PlaceOrder(createTestOrder("sell0", "trader2", "1", models.Sell, 151.0, 1))
+	exchange.PlaceOrder(createTestOrder("buy0", "trader1", "1", models.Buy, 151.0, 1))
+
+	exchange.AdvanceSession(day(15, 50))
+	if status := exchange.stocks["1"].GetStatus(); status != models.ClosingAuction {
+		t.Fatalf("Expected stock 1 to collect orders for the closing auction, got %s", status)
+	}
+	if err := exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 152.0, 10)); err != nil {
+		t.Fatalf("Expected orders to be collected for the closing auction: %v", err)
+	}
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 149.0, 4))
+	if len(transactionLog(exchange)) != 1 {
+		t.Fatal("Expected no trades while collecting orders for the close")
+	}
+
+	// 149 and 152 both execute 4 shares; 149 is closer to the 150 reference
+	exchange.AdvanceSession(day(16, 0))
+	buy1, _ := exchange.GetOrder("buy1")
+	if buy1.FilledQuantity != 4 || buy1.AvgFillPrice != money(149.0) {
+		t.Errorf("Expected 4 shares to trade at 149.00 in the closing auction, got %d at %s", buy1.FilledQuantity, buy1.AvgFillPrice)
+	}
+	if price := exchange.stocks["1"].GetClosingPrice(); price != money(149.0) {
+		t.Errorf("Expected a closing price of 149.00, got %s", price)
+	}
+	if price := exchange.stocks["2"].GetClosingPrice(); price != money(300.0) {
+		t.Errorf("Expected stock 2 to close at its last price of 300.00, got %s", price)
+	}
+}
+
+// Test market sessions - without a calendar the market stays open
+func TestAdvanceSession_NoCalendar(t *testing.T) {
+	exchange := createTestExchange()
+
+	if exchange.AdvanceSession(time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC)) {
+		t.Error("Expected no phase change without a calendar")
+	}
+	if !exchange.IsMarketOpen() {
+		t.Errorf("Expected the market to be open, got %s", exchange.MarketPhase())
+	}
+
+	status := exchange.GetMarketStatus(time.Now())
+	if status.Phase != models.PhaseOpen || status.NextPhaseAt != nil {
+		t.Errorf("Expected an open market with no next phase, got %+v", status)
+	}
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"stock-exchange/internal/models"
+	"stock-exchange/internal/storage"
+	"sync"
+	"time"
+)
+
+// A snapshot is the exchange's state as of a journal entry. It is taken
+// holding every stock's lock, so no operation on a stock is half done and
+// everything journaled under a stock's lock up to the snapshot's sequence
+// number is in it. Trader and market phase changes are not made under a
+// stock's lock and can land in a snapshot as well as in a journal entry after
+// it; replaying those entries again leaves the same state.
+//
+// Snapshots are written atomically, and once one is on disk the journal
+// entries it covers are dropped. Recovery loads the latest snapshot and
+// replays only the entries after it. A store that keeps its records in
+// memory has its open and pending orders carried in the snapshot, with the
+// latest snapshotRecords closed orders and transactions; older history is
+// dropped. Any other store keeps its own. Price history and traders'
+// valuations are always carried in the snapshot.
+
+// snapshotRecords is how many of a volatile store's latest closed orders and
+// transactions a snapshot carries
+const snapshotRecords = 10000
+
+// Snapshot is the exchange's state as of journal entry Seq
+type Snapshot struct {
+	Seq      uint64             `json:"seq"`
+	Time     time.Time          `json:"time"`
+	Phase    models.MarketPhase `json:"phase"`
+	Stocks   []*models.Stock    `json:"stocks"`
+	Traders  []*models.Trader   `json:"traders"`
+	Orders   []*OrderRecord     `json:"orders"` // Open and pending, in arrival order
+	Arrivals uint64             `json:"arrivals"`
+	LastTxID int64              `json:"lastTxId"`
+	Records  *storage.Records   `json:"records,omitempty"` // A volatile store's latest records
+
+	History map[string]map[CandleInterval][]models.Candle `json:"history"` // Candles by stock and interval
+	Equity  map[string][]models.PerformanceData           `json:"equity"`  // Valuations by trader
+}
+
+// SnapshotInfo describes a snapshot on disk
+type SnapshotInfo struct {
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+	Path string    `json:"path"`
+}
+
+// SnapshotStore keeps the latest snapshot in a directory
+type SnapshotStore struct {
+	dir    string
+	latest *SnapshotInfo
+	mu     sync.Mutex // Held while a snapshot is taken and written
+}
+
+// OpenSnapshotStore opens the snapshot directory at dir, creating it if needed
+func OpenSnapshotStore(dir string) (*SnapshotStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &SnapshotStore{dir: dir}, nil
+}
+
+func (s *SnapshotStore) path(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("snapshot-%020d.json", seq))
+}
+
+// Latest loads the latest snapshot, or returns nil if there is none
+func (s *SnapshotStore) Latest() (*Snapshot, error) {
+	names, err := filepath.Glob(filepath.Join(s.dir, "snapshot-*.json"))
+	if err != nil || len(names) == 0 {
+		return nil, err
+	}
+	// Zero-padded sequence numbers sort by name
+	sort.Strings(names)
+	path := names[len(names)-1]
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("snapshot %s: %v", filepath.Base(path), err)
+	}
+
+	s.mu.Lock()
+	s.latest = &SnapshotInfo{Seq: snapshot.Seq, Time: snapshot.Time, Path: path}
+	s.mu.Unlock()
+	return &snapshot, nil
+}
+
+// write stores a snapshot atomically and removes the ones before it
+func (s *SnapshotStore) write(snapshot *Snapshot) (SnapshotInfo, error) {
+	path := s.path(snapshot.Seq)
+	if err := writeFileAtomic(path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(snapshot)
+	}); err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	names, _ := filepath.Glob(filepath.Join(s.dir, "snapshot-*.json"))
+	for _, name := range names {
+		if name < path {
+			os.Remove(name)
+		}
+	}
+
+	info := SnapshotInfo{Seq: snapshot.Seq, Time: snapshot.Time, Path: path}
+	s.latest = &info
+	return info, nil
+}
+
+// TakeSnapshot writes a snapshot of the exchange and drops the journal
+// entries it covers. If nothing has been journaled since the latest
+// snapshot, that one is returned instead.
+func (e *Exchange) TakeSnapshot() (SnapshotInfo, error) {
+	if e.journal == nil || e.snapshots == nil {
+		return SnapshotInfo{}, fmt.Errorf("snapshots are not enabled")
+	}
+	store := e.snapshots
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.latest != nil && store.latest.Seq == e.journal.Seq() {
+		return *store.latest, nil
+	}
+
+	snapshot := e.captureSnapshot()
+
+	// The snapshot must never be ahead of what the journal has on disk
+	if err := e.journal.Sync(); err != nil {
+		return SnapshotInfo{}, err
+	}
+	info, err := store.write(snapshot)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	if err := e.journal.Truncate(snapshot.Seq); err != nil {
+		log.Printf("Failed to truncate the journal after snapshot %d: %v", snapshot.Seq, err)
+	}
+	log.Printf("📸 Snapshot written at journal entry %d", snapshot.Seq)
+	return info, nil
+}
+
+// lockAllStocks takes every stock's lock, in order of stock ID, so no
+// operation on any stock is half done. It returns the stock IDs in that order
+// and a function that releases the locks.
+func (e *Exchange) lockAllStocks() (stockIDs []string, unlock func()) {
+	stockIDs = make([]string, 0, len(e.stocks))
+	for stockID := range e.stocks {
+		stockIDs = append(stockIDs, stockID)
+	}
+	sort.Strings(stockIDs)
+	for _, stockID := range stockIDs {
+		e.stockLocks[stockID].Lock()
+	}
+	return stockIDs, func() {
+		for _, stockID := range stockIDs {
+			e.stockLocks[stockID].Unlock()
+		}
+	}
+}
+
+// captureSnapshot copies the exchange's state holding every stock's lock. A
+// volatile store's records are read after the locks are released, as of the
+// snapshot's journal entry.
+func (e *Exchange) captureSnapshot() *Snapshot {
+	snapshot := e.captureState()
+	if store, ok := e.store.(storage.Volatile); ok {
+		e.writer.flush()
+		records := store.Dump(snapshot.Seq, snapshotRecords)
+		snapshot.Records = &records
+	}
+	return snapshot
+}
+
+// captureState copies everything but the store's records
+func (e *Exchange) captureState() *Snapshot {
+	stockIDs, unlock := e.lockAllStocks()
+	defer unlock()
+
+	snapshot := &Snapshot{Seq: e.journal.Seq(), Time: time.Now()}
+	for _, stockID := range stockIDs {
+		snapshot.Stocks = append(snapshot.Stocks, e.stocks[stockID].Clone())
+	}
+
+	e.sessionMu.RLock()
+	snapshot.Phase = e.phase
+	e.sessionMu.RUnlock()
+
+	for _, trader := range e.allTraders() {
+		account := e.accountLock(trader.ID)
+		account.RLock()
+		snapshot.Traders = append(snapshot.Traders, cloneTrader(trader))
+		account.RUnlock()
+	}
+	sort.Slice(snapshot.Traders, func(i, j int) bool { return snapshot.Traders[i].ID < snapshot.Traders[j].ID })
+
+	for _, order := range e.orders.all() {
+		snapshot.Orders = append(snapshot.Orders, newOrderRecord(order))
+	}
+	snapshot.Arrivals = e.orders.arrivals.Load()
+
+	e.txMu.Lock()
+	snapshot.LastTxID = e.lastTxID
+	e.txMu.Unlock()
+
+	snapshot.History = e.ticks.dump()
+	snapshot.Equity = e.equity.dump()
+	return snapshot
+}
+
+// restoreSnapshot loads a snapshot into an empty exchange. Books and
+// reservations are left for rebuild.
+func (e *Exchange) restoreSnapshot(snapshot *Snapshot) {
+	if store, ok := e.store.(storage.Volatile); ok && snapshot.Records != nil {
+		store.Load(*snapshot.Records)
+	}
+	e.ticks.load(snapshot.History)
+	e.equity.load(snapshot.Equity)
+
+	e.phase = snapshot.Phase
+	e.orders.arrivals.Store(snapshot.Arrivals)
+	e.lastTxID = snapshot.LastTxID
+	for _, stock := range snapshot.Stocks {
+		e.addStock(stock)
+	}
+	for _, trader := range snapshot.Traders {
+		if trader.Holdings == nil {
+			trader.Holdings = make(map[string]int)
+		}
+		e.traders[trader.ID] = trader
+	}
+	for _, record := range snapshot.Orders {
+		e.restoreOrder(record)
+	}
+}
+
+// cloneTrader copies a trader's account. The caller must hold the trader's
+// account lock.
+func cloneTrader(trader *models.Trader) *models.Trader {
+	clone := &models.Trader{
+		ID:                  trader.ID,
+		Name:                trader.Name,
+		Money:               trader.Money,
+		InitialMoney:        trader.InitialMoney,
+		Holdings:            make(map[string]int, len(trader.Holdings)),
+		ReservedCash:        trader.ReservedCash,
+		ReservedShares:      make(map[string]int, len(trader.ReservedShares)),
+		SelfTradePrevention: trader.SelfTradePrevention,
+	}
+	for stockID, quantity := range trader.Holdings {
+		clone.Holdings[stockID] = quantity
+	}
+	for stockID, quantity := range trader.ReservedShares {
+		clone.ReservedShares[stockID] = quantity
+	}
+	return clone
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"log"
+	"time"
+)
+
+// Snapshotter periodically snapshots the exchange so recovery only has to
+// replay the journal entries since the last snapshot
+type Snapshotter struct {
+	exchange *Exchange
+	ticker   *time.Ticker
+	done     chan bool
+}
+
+func NewSnapshotter(exchange *Exchange, interval time.Duration) *Snapshotter {
+	return &Snapshotter{
+		exchange: exchange,
+		ticker:   time.NewTicker(interval),
+		done:     make(chan bool),
+	}
+}
+
+func (s *Snapshotter) Start() {
+	go func() {
+		for {
+			select {
+			case <-s.ticker.C:
+				if _, err := s.exchange.TakeSnapshot(); err != nil {
+					log.Printf("Failed to take a snapshot: %v", err)
+				}
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+func (s *Snapshotter) Stop() {
+	s.ticker.Stop()
+	s.done <- true
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"sort"
+	"stock-exchange/internal/models"
+)
+
+type stopEntry struct {
+	order *models.Order
+	seq   uint64
+}
+
+// StopBook holds the untriggered stop and stop-limit orders of a single stock.
+// Buy stops trigger when the last price rises to their stop price, sell stops
+// when it falls to it. Buy stops are kept lowest stop first and sell stops
+// highest stop first, so the front of each side is always the next to trigger;
+// orders with the same stop price keep arrival order.
+type StopBook struct {
+	StockID string
+	buys    []stopEntry
+	sells   []stopEntry
+	seq     uint64
+}
+
+func NewStopBook(stockID string) *StopBook {
+	return &StopBook{
+		StockID: stockID,
+		buys:    make([]stopEntry, 0),
+		sells:   make([]stopEntry, 0),
+	}
+}
+
+// Add queues a stop order behind any others with the same stop price
+func (b *StopBook) Add(order *models.Order) {
+	b.seq++
+	order.SetQueued(b.seq)
+	entry := stopEntry{order: order, seq: b.seq}
+
+	side := &b.sells
+	if order.Type == models.Buy {
+		side = &b.buys
+	}
+
+	i := sort.Search(len(*side), func(i int) bool {
+		return triggersFirst(order.Type, order.StopPrice, (*side)[i].order.StopPrice)
+	})
+	*side = append(*side, stopEntry{})
+	copy((*side)[i+1:], (*side)[i:])
+	(*side)[i] = entry
+}
+
+// triggersFirst reports whether stop price a triggers before stop price c
+// on the given side
+func triggersFirst(side models.OrderType, a, c models.Money) bool {
+	if side == models.Buy {
+		return a < c
+	}
+	return a > c
+}
+
+// Trail moves the stop prices of trailing stop orders after the stock's last
+// price changed to price, keeping each side in trigger order. It returns the
+// orders whose stop price moved.
+func (b *StopBook) Trail(stock *models.Stock, price models.Money) []*models.Order {
+	var moved []*models.Order
+	for _, side := range []*[]stopEntry{&b.buys, &b.sells} {
+		count := len(moved)
+		for _, entry := range *side {
+			if entry.order.IsTrailing() && trail(stock, entry.order, price) {
+				moved = append(moved, entry.order)
+			}
+		}
+		if len(moved) == count {
+			continue
+		}
+
+		entries := *side
+		sort.Slice(entries, func(i, j int) bool {
+			a, c := entries[i], entries[j]
+			if a.order.StopPrice != c.order.StopPrice {
+				return triggersFirst(a.order.Type, a.order.StopPrice, c.order.StopPrice)
+			}
+			return a.seq < c.seq
+		})
+	}
+	return moved
+}
+
+// Remove takes an untriggered stop order out of the book, or returns nil
+func (b *StopBook) Remove(orderID string) *models.Order {
+	for _, side := range []*[]stopEntry{&b.buys, &b.sells} {
+		for i, entry := range *side {
+			if entry.order.ID == orderID {
+				*side = append((*side)[:i], (*side)[i+1:]...)
+				return entry.order
+			}
+		}
+	}
+	return nil
+}
+
+// PopTriggered removes and returns the next stop order triggered by lastPrice,
+// or nil if none is. When both sides have a triggered order the one that was
+// placed first wins, so cascades are processed in a deterministic order.
+func (b *StopBook) PopTriggered(lastPrice models.Money) *models.Order {
+	buyTriggered := len(b.buys) > 0 && lastPrice >= b.buys[0].order.StopPrice
+	sellTriggered := len(b.sells) > 0 && lastPrice <= b.sells[0].order.StopPrice
+
+	if buyTriggered && (!sellTriggered || b.buys[0].seq < b.sells[0].seq) {
+		order := b.buys[0].order
+		b.buys = b.buys[1:]
+		return order
+	}
+	if sellTriggered {
+		order := b.sells[0].order
+		b.sells = b.sells[1:]
+		return order
+	}
+	return nil
+}
+
+// Orders returns all untriggered stop orders, buys then sells, in trigger order
+func (b *StopBook) Orders() []*models.Order {
+	orders := make([]*models.Order, 0, len(b.buys)+len(b.sells))
+	for _, entry := range b.buys {
+		orders = append(orders, entry.order)
+	}
+	for _, entry := range b.sells {
+		orders = append(orders, entry.order)
+	}
+	return orders
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"stock-exchange/internal/models"
+	"testing"
+)
+
+func createTestStop(id string, orderType models.OrderType, stopPrice float64) *models.Order {
+	order := createTestOrder(id, "trader1", "1", orderType, 0, 1)
+	order.Kind = models.Stop
+	order.StopPrice = money(stopPrice)
+	return order
+}
+
+// Test StopBook trigger order - nearest stop first, FIFO at the same stop
+func TestStopBook_TriggerOrder(t *testing.T) {
+	book := NewStopBook("1")
+
+	book.Add(createTestStop("b1", models.Buy, 105.0))
+	book.Add(createTestStop("b2", models.Buy, 102.0))
+	book.Add(createTestStop("b3", models.Buy, 102.0))
+	book.Add(createTestStop("s1", models.Sell, 95.0))
+
+	if order := book.PopTriggered(money(101.0)); order != nil {
+		t.Fatalf("Expected nothing to trigger at 101, got %s", order.ID)
+	}
+
+	expected := []string{"b2", "b3", "b1"}
+	for _, id := range expected {
+		order := book.PopTriggered(money(110.0))
+		if order == nil || order.ID != id {
+			t.Fatalf("Expected %s to trigger next", id)
+		}
+	}
+
+	if order := book.PopTriggered(money(110.0)); order != nil {
+		t.Errorf("Expected sell stop not to trigger on a rise, got %s", order.ID)
+	}
+
+	if order := book.PopTriggered(money(95.0)); order == nil || order.ID != "s1" {
+		t.Error("Expected s1 to trigger at 95")
+	}
+}
+
+// Test StopBook - when both sides trigger the earlier order goes first
+func TestStopBook_BothSidesTriggered(t *testing.T) {
+	book := NewStopBook("1")
+
+	book.Add(createTestStop("s1", models.Sell, 100.0))
+	book.Add(createTestStop("b1", models.Buy, 100.0))
+
+	if order := book.PopTriggered(money(100.0)); order == nil || order.ID != "s1" {
+		t.Fatal("Expected s1 (placed first) to trigger first")
+	}
+
+	if order := book.PopTriggered(money(100.0)); order == nil || order.ID != "b1" {
+		t.Fatal("Expected b1 to trigger second")
+	}
+
+	if book.Remove("b1") != nil {
+		t.Error("Expected triggered order to be gone from the stop book")
+	}
+}
+
+// Test StopBook - trailing stops move and are re-sorted into trigger order
+func TestStopBook_Trail(t *testing.T) {
+	book := NewStopBook("1")
+	stock := &models.Stock{ID: "1", CurrentPrice: money(150.0)}
+
+	trailing := createTestStop("s1", models.Sell, 0)
+	trailing.TrailAmount = money(5.0)
+	startTrail(stock, trailing)
+	book.Add(trailing)
+	book.Add(createTestStop("s2", models.Sell, 147.0))
+
+	book.Trail(stock, money(160.0))
+	if trailing.StopPrice != money(155.0) {
+		t.Fatalf("Expected the trailing stop at 155.00, got %s", trailing.StopPrice)
+	}
+
+	if order := book.PopTriggered(money(155.0)); order == nil || order.ID != "s1" {
+		t.Error("Expected the trailing stop to trigger ahead of the fixed stop")
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"log"
+	"stock-exchange/internal/storage"
+	"sync"
+)
+
+// storeWriter saves batches to the store in the order they were queued.
+// Operations queue their batch while they hold the stock's lock, so a
+// stock's batches keep their order, and write after releasing it: whichever
+// caller gets there first saves everything queued so far, holding no
+// exchange lock. Reads from the store flush first to see every operation
+// that has finished.
+type storeWriter struct {
+	store   storage.Store
+	pending []storage.Batch
+	queued  uint64 // Batches queued so far
+	saved   uint64 // Batches saved, or that failed to save
+	saving  bool
+	mu      sync.Mutex
+	cond    *sync.Cond
+}
+
+func newStoreWriter(store storage.Store) *storeWriter {
+	w := &storeWriter{store: store}
+	w.cond = sync.NewCond(&w.mu)
+	return w
+}
+
+// queue adds a batch to be saved by the next write or flush
+func (w *storeWriter) queue(batch storage.Batch) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, batch)
+	w.queued++
+}
+
+// write saves the queued batches, unless another caller already is
+func (w *storeWriter) write() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.saving {
+		w.drain()
+	}
+}
+
+// flush waits until every batch queued so far is saved, saving them itself
+// if no other caller is
+func (w *storeWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	target := w.queued
+	for w.saved < target {
+		if !w.saving {
+			w.drain()
+			continue
+		}
+		w.cond.Wait()
+	}
+}
+
+// drain saves batches until none are left. The caller must hold w.mu, which
+// is released while saving.
+func (w *storeWriter) drain() {
+	w.saving = true
+	for len(w.pending) > 0 {
+		batches := w.pending
+		w.pending = nil
+
+		w.mu.Unlock()
+		for _, batch := range batches {
+			if err := w.store.Save(batch); err != nil {
+				log.Printf("Failed to store changes: %v", err)
+			}
+		}
+		w.mu.Lock()
+
+		w.saved += uint64(len(batches))
+		w.cond.Broadcast()
+	}
+	w.saving = false
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"stock-exchange/internal/models"
+	"stock-exchange/internal/storage"
+	"testing"
+)
+
+// lockCheckingStore fails a test if the exchange uses the store while
+// holding a stock lock, an account lock or an open orders' lock
+type lockCheckingStore struct {
+	*storage.MemoryStore
+	t        *testing.T
+	exchange *Exchange
+}
+
+func (s *lockCheckingStore) checkUnlocked(use string) {
+	for stockID, mu := range s.exchange.stockLocks {
+		if !mu.TryLock() {
+			s.t.Errorf("Expected no stock lock to be held to %s, stock %s was locked", use, stockID)
+			continue
+		}
+		mu.Unlock()
+	}
+	for i := range s.exchange.accountLocks {
+		mu := &s.exchange.accountLocks[i]
+		if !mu.TryLock() {
+			s.t.Errorf("Expected no account lock to be held to %s", use)
+			return
+		}
+		mu.Unlock()
+	}
+	for i := range s.exchange.orders.shards {
+		mu := &s.exchange.orders.shards[i].mu
+		if !mu.TryLock() {
+			s.t.Errorf("Expected no open orders' lock to be held to %s", use)
+			return
+		}
+		mu.Unlock()
+	}
+}
+
+func (s *lockCheckingStore) Save(batch storage.Batch) error {
+	s.checkUnlocked("save")
+	return s.MemoryStore.Save(batch)
+}
+
+func (s *lockCheckingStore) Order(orderID string) (models.Order, bool, error) {
+	s.checkUnlocked("read an order")
+	return s.MemoryStore.Order(orderID)
+}
+
+// Test store writes - saved outside the exchange's locks and read back as
+// soon as an operation finishes
+func TestStoreWriter_SavesOutsideLocks(t *testing.T) {
+	store := &lockCheckingStore{MemoryStore: storage.NewMemoryStore(), t: t}
+	exchange := NewExchangeWithStore(store)
+	store.exchange = exchange
+	exchange.addStock(&models.Stock{ID: "1", Name: "Apple Inc.", CurrentPrice: money(150.0)})
+	exchange.RegisterTrader("trader1", "John Doe", money(10000.0))
+	exchange.RegisterTrader("trader2", "Jane Smith", money(15000.0))
+	exchange.traders["trader2"].Holdings["1"] = 100
+
+	exchange.PlaceOrder(createTestOrder("buy0", "trader1", "1", models.Buy, 140.0, 10))
+	exchange.CancelOrder("buy0")
+	if order, exists := exchange.GetOrder("buy0"); !exists || order.Status != models.Cancelled {
+		t.Errorf("Expected the cancelled order to be read back, got %v (%v)", order.Status, exists)
+	}
+
+	// A closed order's ID stays taken without asking the store
+	if err := exchange.PlaceOrder(createTestOrder("buy0", "trader1", "1", models.Buy, 140.0, 10)); err == nil {
+		t.Error("Expected a closed order's ID to be rejected")
+	}
+
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 149.0, 10))
+	exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 149.0, 4))
+	if transactions := exchange.GetLastTransactions("1", 10); len(transactions) != 1 || transactions[0].Quantity != 4 {
+		t.Errorf("Expected the trade to be read back, got %v", transactions)
+	}
+	if buy1, _ := exchange.GetOrder("buy1"); buy1.Status != models.Filled {
+		t.Errorf("Expected the filled order to be read back, got %v", buy1.Status)
+	}
+}
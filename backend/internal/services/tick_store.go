@@ -0,0 +1,179 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"stock-exchange/internal/models"
+	"sync"
+	"time"
+)
+
+// CandleInterval is the length of the candles a price history is read in
+type CandleInterval string
+
+const (
+	Interval1m CandleInterval = "1m"
+	Interval5m CandleInterval = "5m"
+	Interval1h CandleInterval = "1h"
+	Interval1d CandleInterval = "1d"
+)
+
+// DefaultHistoryCandles is how many candles a history query without a start
+// time reaches back
+const DefaultHistoryCandles = 100
+
+// candleSeries is how long each interval's candles are and how long they are
+// kept. Finer candles are dropped sooner.
+var candleSeries = []struct {
+	interval  CandleInterval
+	length    time.Duration
+	retention time.Duration
+}{
+	{Interval1m, time.Minute, 24 * time.Hour},
+	{Interval5m, 5 * time.Minute, 7 * 24 * time.Hour},
+	{Interval1h, time.Hour, 90 * 24 * time.Hour},
+	{Interval1d, 24 * time.Hour, 5 * 365 * 24 * time.Hour},
+}
+
+// Length returns how long the interval's candles are
+func (i CandleInterval) Length() (time.Duration, error) {
+	for _, series := range candleSeries {
+		if series.interval == i {
+			return series.length, nil
+		}
+	}
+	return 0, fmt.Errorf("interval must be one of 1m, 5m, 1h or 1d")
+}
+
+// TickStore records each stock's ticks as they happen and keeps them as
+// candles at every interval. Candles are aligned to UTC, so a day's candle
+// runs from midnight to midnight UTC.
+type TickStore struct {
+	candles map[string]map[CandleInterval][]models.Candle // By stock, oldest first
+	mu      sync.RWMutex
+}
+
+func NewTickStore() *TickStore {
+	return &TickStore{candles: make(map[string]map[CandleInterval][]models.Candle)}
+}
+
+// Record adds a tick to a stock's candles, starting new ones as intervals
+// pass and dropping those past their retention. A tick from before the
+// latest candle, after the clock stepped back, goes into that candle.
+func (s *TickStore) Record(stockID string, tick models.Tick) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	series, exists := s.candles[stockID]
+	if !exists {
+		series = make(map[CandleInterval][]models.Candle)
+		s.candles[stockID] = series
+	}
+
+	for _, config := range candleSeries {
+		candles := series[config.interval]
+		start := tick.Time.UTC().Truncate(config.length)
+
+		if last := len(candles) - 1; last >= 0 && !start.After(candles[last].Time) {
+			candle := &candles[last]
+			if tick.Price > candle.High {
+				candle.High = tick.Price
+			}
+			if tick.Price < candle.Low {
+				candle.Low = tick.Price
+			}
+			candle.Close = tick.Price
+			candle.Volume += tick.Quantity
+			continue
+		}
+
+		candles = append(candles, models.Candle{
+			Time:   start,
+			Open:   tick.Price,
+			High:   tick.Price,
+			Low:    tick.Price,
+			Close:  tick.Price,
+			Volume: tick.Quantity,
+		})
+
+		cutoff := start.Add(-config.retention)
+		expired := sort.Search(len(candles), func(i int) bool { return candles[i].Time.After(cutoff) })
+		if expired > 0 {
+			candles = append(candles[:0:0], candles[expired:]...)
+		}
+		series[config.interval] = candles
+	}
+}
+
+// Candles returns a stock's candles that start in [from, to), oldest first
+func (s *TickStore) Candles(stockID string, interval CandleInterval, from, to time.Time) []models.Candle {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	candles := s.candles[stockID][interval]
+	first := sort.Search(len(candles), func(i int) bool { return !candles[i].Time.Before(from) })
+	last := sort.Search(len(candles), func(i int) bool { return !candles[i].Time.Before(to) })
+	if first >= last {
+		return []models.Candle{}
+	}
+	return append([]models.Candle(nil), candles[first:last]...)
+}
+
+// dump copies every stock's candles, for a snapshot
+func (s *TickStore) dump() map[string]map[CandleInterval][]models.Candle {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	dump := make(map[string]map[CandleInterval][]models.Candle, len(s.candles))
+	for stockID, series := range s.candles {
+		dump[stockID] = make(map[CandleInterval][]models.Candle, len(series))
+		for interval, candles := range series {
+			dump[stockID][interval] = append([]models.Candle(nil), candles...)
+		}
+	}
+	return dump
+}
+
+// load replaces the candles with ones from a snapshot
+func (s *TickStore) load(candles map[string]map[CandleInterval][]models.Candle) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.candles = make(map[string]map[CandleInterval][]models.Candle, len(candles))
+	for stockID, series := range candles {
+		s.candles[stockID] = series
+	}
+}
+
+// recordTicks adds an operation's trades on a stock to its price history,
+// and the price it moved to if that was not the price it last traded at.
+// Recovery replays journal entries through here too, so the history is
+// rebuilt the same way it was recorded.
+func (e *Exchange) recordTicks(stockID string, transactions []models.Transaction, moved bool, price models.Money, at time.Time) {
+	for _, transaction := range transactions {
+		e.ticks.Record(stockID, models.Tick{Time: transaction.ExecutedAt, Price: transaction.Price, Quantity: transaction.Quantity})
+	}
+	if moved && (len(transactions) == 0 || transactions[len(transactions)-1].Price != price) {
+		e.ticks.Record(stockID, models.Tick{Time: at, Price: price})
+	}
+}
+
+// GetStockHistory returns a stock's candles that start in [from, to), oldest
+// first. A zero to means now, and a zero from reaches DefaultHistoryCandles
+// candles back from to.
+func (e *Exchange) GetStockHistory(stockID string, interval CandleInterval, from, to time.Time) ([]models.Candle, error) {
+	length, err := interval.Length()
+	if err != nil {
+		return nil, err
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-DefaultHistoryCandles * length)
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("from must be before to")
+	}
+	return e.ticks.Candles(stockID, interval, from, to), nil
+}
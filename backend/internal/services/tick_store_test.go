@@ -0,0 +1,92 @@
+package services
+
+import (
+	"stock-exchange/internal/models"
+	"testing"
+	"time"
+)
+
+// Test tick store - ticks aggregate into OHLCV candles at every interval
+func TestTickStore_Record(t *testing.T) {
+	store := NewTickStore()
+	start := time.Date(2024, 1, 2, 14, 30, 0, 0, time.UTC)
+
+	store.Record("1", models.Tick{Time: start, Price: money(100.0), Quantity: 10})
+	store.Record("1", models.Tick{Time: start.Add(20 * time.Second), Price: money(104.0), Quantity: 5})
+	store.Record("1", models.Tick{Time: start.Add(40 * time.Second), Price: money(98.0)})
+	store.Record("1", models.Tick{Time: start.Add(90 * time.Second), Price: money(101.0), Quantity: 3})
+
+	minutes := store.Candles("1", Interval1m, start, start.Add(time.Hour))
+	if len(minutes) != 2 {
+		t.Fatalf("Expected 2 one-minute candles, got %d", len(minutes))
+	}
+	first := minutes[0]
+	if first.Open != money(100.0) || first.High != money(104.0) || first.Low != money(98.0) || first.Close != money(98.0) || first.Volume != 15 {
+		t.Errorf("Expected 100/104/98/98 with 15 traded, got %s/%s/%s/%s with %d", first.Open, first.High, first.Low, first.Close, first.Volume)
+	}
+	if !minutes[1].Time.Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected the second candle to start a minute later, got %v", minutes[1].Time)
+	}
+
+	days := store.Candles("1", Interval1d, start.Truncate(24*time.Hour), start.Add(24*time.Hour))
+	if len(days) != 1 || days[0].Close != money(101.0) || days[0].Volume != 18 {
+		t.Errorf("Expected one daily candle closing at 101.00 with 18 traded, got %v", days)
+	}
+
+	// Candles are read from their start time
+	if candles := store.Candles("1", Interval1m, start.Add(time.Second), start.Add(time.Hour)); len(candles) != 1 {
+		t.Errorf("Expected only the candle starting after from, got %d", len(candles))
+	}
+
+	// A tick from before the latest candle goes into it
+	store.Record("1", models.Tick{Time: start, Price: money(95.0), Quantity: 1})
+	minutes = store.Candles("1", Interval1m, start, start.Add(time.Hour))
+	if len(minutes) != 2 || minutes[1].Low != money(95.0) || minutes[0].Volume != 15 {
+		t.Errorf("Expected the late tick in the latest candle, got %v", minutes)
+	}
+}
+
+// Test tick store - candles past their interval's retention are dropped
+func TestTickStore_Retention(t *testing.T) {
+	store := NewTickStore()
+	start := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	store.Record("1", models.Tick{Time: start, Price: money(100.0), Quantity: 1})
+	store.Record("1", models.Tick{Time: start.Add(25 * time.Hour), Price: money(101.0), Quantity: 1})
+
+	if minutes := store.Candles("1", Interval1m, start, start.Add(48*time.Hour)); len(minutes) != 1 {
+		t.Errorf("Expected one-minute candles older than a day to be dropped, got %d", len(minutes))
+	}
+	if hours := store.Candles("1", Interval1h, start, start.Add(48*time.Hour)); len(hours) != 2 {
+		t.Errorf("Expected hourly candles to be kept, got %d", len(hours))
+	}
+}
+
+// Test stock history - trades and price moves are recorded as ticks
+func TestGetStockHistory(t *testing.T) {
+	exchange := createTestExchange()
+	exchange.traders["trader2"].Holdings["1"] = 100
+
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 149.0, 10))
+	exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 149.0, 4))
+	exchange.UpdatePrice("1", money(152.0))
+
+	candles, err := exchange.GetStockHistory("1", Interval1d, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if len(candles) != 1 {
+		t.Fatalf("Expected one daily candle, got %d", len(candles))
+	}
+	if candles[0].Open != money(149.0) || candles[0].Close != money(152.0) || candles[0].Volume != 4 {
+		t.Errorf("Expected 149.00 to 152.00 with 4 traded, got %s to %s with %d", candles[0].Open, candles[0].Close, candles[0].Volume)
+	}
+
+	if _, err := exchange.GetStockHistory("1", "2m", time.Time{}, time.Time{}); err == nil {
+		t.Error("Expected an unknown interval to be rejected")
+	}
+	now := time.Now()
+	if _, err := exchange.GetStockHistory("1", Interval1h, now, now.Add(-time.Hour)); err == nil {
+		t.Error("Expected from after to to be rejected")
+	}
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"fmt"
+	"stock-exchange/internal/models"
+)
+
+// A trailing stop is a stop order placed with a trail instead of a stop
+// price. Its stop price starts at the trail's distance from the stock's last
+// price when it is placed and then follows every better price: every trade
+// and every price update moves a sell stop up as the price rises and a buy
+// stop down as it falls, but never back. Once the price turns by the trail
+// the stop triggers like any other.
+
+// checkTrail checks a trailing stop order trails by either an amount or a
+// fraction of the price, and leaves its stop price to the market
+func checkTrail(order *models.Order) error {
+	if order.Kind != models.Stop {
+		return fmt.Errorf("only stop orders can trail")
+	}
+	if order.TrailAmount > 0 && order.TrailPercent > 0 {
+		return fmt.Errorf("a trailing stop trails by trailAmount or trailPercent, not both")
+	}
+	if order.TrailPercent >= 1 {
+		return fmt.Errorf("trail percent must be less than 1")
+	}
+	if order.StopPrice != 0 {
+		return fmt.Errorf("the stop price of a trailing stop is set from the market")
+	}
+	return nil
+}
+
+// startTrail sets a trailing stop order's stop price from the stock's last
+// price, when the order is placed or, as a bracket exit, activated
+func startTrail(stock *models.Stock, order *models.Order) {
+	order.TrailPeak = 0
+	trail(stock, order, stock.GetPrice())
+}
+
+// trail moves a trailing stop order's peak to price if that is better for
+// the order, and reports whether its stop price moved. The caller must hold
+// the stock's lock.
+func trail(stock *models.Stock, order *models.Order, price models.Money) bool {
+	if order.TrailPeak != 0 && (order.Type == models.Sell && price <= order.TrailPeak ||
+		order.Type == models.Buy && price >= order.TrailPeak) {
+		return false
+	}
+	order.TrailPeak = price
+
+	stop := stock.RoundToTick(order.TrailFrom(price))
+	if stop == order.StopPrice {
+		return false
+	}
+	order.StopPrice = stop
+	return true
+}
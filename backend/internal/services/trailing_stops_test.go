@@ -0,0 +1,120 @@
+package services
+
+import (
+	"stock-exchange/internal/models"
+	"stock-exchange/internal/storage"
+	"testing"
+)
+
+func createTrailingStop(id, traderID string, orderType models.OrderType, quantity int) *models.Order {
+	order := createTestOrder(id, traderID, "1", orderType, 0, quantity)
+	order.Kind = models.Stop
+	return order
+}
+
+// Test trailing stops - a sell stop follows trades and price updates up, never down
+func TestTrailingStop_SellFollowsPrice(t *testing.T) {
+	exchange := createTestExchange()
+	exchange.traders["trader1"].Holdings["1"] = 10
+	exchange.traders["trader2"].Holdings["1"] = 10
+
+	stop := createTrailingStop("trail1", "trader1", models.Sell, 10)
+	stop.TrailAmount = money(5.0)
+	if err := exchange.PlaceOrder(stop); err != nil {
+		t.Fatalf("Failed to place trailing stop: %v", err)
+	}
+	if stop.StopPrice != money(145.0) || stop.TrailPeak != money(150.0) {
+		t.Errorf("Expected the stop at 145.00 below the last price, got %s", stop.StopPrice)
+	}
+
+	// A trade moves the stop up
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 158.0, 1))
+	exchange.PlaceOrder(createTestOrder("buy1", "trader1", "1", models.Buy, 158.0, 1))
+	if stop.StopPrice != money(153.0) {
+		t.Errorf("Expected the trade at 158.00 to move the stop to 153.00, got %s", stop.StopPrice)
+	}
+
+	// A lower price does not move it back
+	exchange.UpdatePrice("1", money(156.0))
+	if stop.StopPrice != money(153.0) {
+		t.Errorf("Expected the stop to stay at 153.00, got %s", stop.StopPrice)
+	}
+
+	// The trader's open orders show the current trigger level
+	page, _ := exchange.QueryOrders(storage.OrderQuery{TraderID: "trader1", Statuses: []models.OrderStatus{models.Open}})
+	if len(page.Orders) != 1 || page.Orders[0].StopPrice != money(153.0) || page.Orders[0].TrailPeak != money(158.0) {
+		t.Errorf("Expected the open trailing stop at 153.00 with a peak of 158.00, got %+v", page.Orders)
+	}
+
+	exchange.PlaceOrder(createTestOrder("buy2", "trader2", "1", models.Buy, 153.0, 10))
+	exchange.UpdatePrice("1", money(153.0))
+	if stop.Status != models.Filled || stop.AvgFillPrice != money(153.0) {
+		t.Errorf("Expected the stop to trigger and fill at 153.00, got %v at %s", stop.Status, stop.AvgFillPrice)
+	}
+}
+
+// Test trailing stops - a buy stop trails the lowest price by a percentage
+func TestTrailingStop_BuyPercent(t *testing.T) {
+	exchange := createTestExchange()
+	exchange.traders["trader2"].Holdings["1"] = 10
+
+	stop := createTrailingStop("trail1", "trader1", models.Buy, 10)
+	stop.TrailPercent = 0.10
+	if err := exchange.PlaceOrder(stop); err != nil {
+		t.Fatalf("Failed to place trailing stop: %v", err)
+	}
+	if stop.StopPrice != money(165.0) {
+		t.Errorf("Expected the stop at 165.00, got %s", stop.StopPrice)
+	}
+
+	exchange.UpdatePrice("1", money(140.0))
+	exchange.UpdatePrice("1", money(145.0))
+	if stop.StopPrice != money(154.0) || stop.TrailPeak != money(140.0) {
+		t.Errorf("Expected the stop at 154.00 above the low of 140.00, got %s", stop.StopPrice)
+	}
+
+	exchange.PlaceOrder(createTestOrder("sell1", "trader2", "1", models.Sell, 154.0, 10))
+	exchange.UpdatePrice("1", money(154.0))
+	if stop.Status != models.Filled {
+		t.Errorf("Expected the stop to trigger and fill, got %v", stop.Status)
+	}
+}
+
+// Test trailing stops - validation
+func TestTrailingStop_Validation(t *testing.T) {
+	exchange := createTestExchange()
+
+	limit := createTestOrder("limit1", "trader1", "1", models.Buy, 150.0, 1)
+	limit.TrailAmount = money(5.0)
+	if err := exchange.PlaceOrder(limit); err == nil {
+		t.Error("Expected a trailing limit order to be rejected")
+	}
+
+	stopLimit := createTrailingStop("stoplimit1", "trader1", models.Buy, 1)
+	stopLimit.Kind = models.StopLimit
+	stopLimit.Price = money(160.0)
+	stopLimit.TrailAmount = money(5.0)
+	if err := exchange.PlaceOrder(stopLimit); err == nil {
+		t.Error("Expected a trailing stop-limit order to be rejected")
+	}
+
+	both := createTrailingStop("both1", "trader1", models.Buy, 1)
+	both.TrailAmount = money(5.0)
+	both.TrailPercent = 0.05
+	if err := exchange.PlaceOrder(both); err == nil {
+		t.Error("Expected a trail by both an amount and a percentage to be rejected")
+	}
+
+	fixed := createTrailingStop("fixed1", "trader1", models.Buy, 1)
+	fixed.TrailPercent = 0.05
+	fixed.StopPrice = money(160.0)
+	if err := exchange.PlaceOrder(fixed); err == nil {
+		t.Error("Expected a trailing stop with its own stop price to be rejected")
+	}
+
+	wide := createTrailingStop("wide1", "trader1", models.Buy, 1)
+	wide.TrailPercent = 1.5
+	if err := exchange.PlaceOrder(wide); err == nil {
+		t.Error("Expected a trail of 100% or more to be rejected")
+	}
+}
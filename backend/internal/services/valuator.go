@@ -0,0 +1,38 @@
+package services
+
+import "time"
+
+// Valuator values every trader at a fixed cadence, so equity curves move with
+// prices between a trader's own fills
+type Valuator struct {
+	exchange *Exchange
+	ticker   *time.Ticker
+	done     chan bool
+}
+
+func NewValuator(exchange *Exchange, interval time.Duration) *Valuator {
+	return &Valuator{
+		exchange: exchange,
+		ticker:   time.NewTicker(interval),
+		done:     make(chan bool),
+	}
+}
+
+func (v *Valuator) Start() {
+	v.exchange.RecordValuations()
+	go func() {
+		for {
+			select {
+			case <-v.ticker.C:
+				v.exchange.RecordValuations()
+			case <-v.done:
+				return
+			}
+		}
+	}()
+}
+
+func (v *Valuator) Stop() {
+	v.ticker.Stop()
+	v.done <- true
+}
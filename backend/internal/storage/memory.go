@@ -0,0 +1,286 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"stock-exchange/internal/models"
+	"strconv"
+	"sync"
+)
+
+// MemoryStore keeps orders and transactions in memory, indexed for the
+// queries the exchange makes. Nothing reads stocks and traders back, so it
+// does not keep them. It is volatile: snapshots carry its records across
+// restarts.
+type MemoryStore struct {
+	orders       map[string]*orderRow
+	traderOrders map[string][]*orderRow // In arrival order
+
+	// Transactions in execution order, by stock and by trader
+	transactions       []*transactionRow
+	stockTransactions  map[string][]*transactionRow
+	traderTransactions map[string][]*transactionRow
+
+	mu sync.RWMutex
+}
+
+type orderRow struct {
+	order models.Order
+	seq   uint64
+}
+
+type transactionRow struct {
+	transaction models.Transaction
+	number      int64
+	seq         uint64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		orders:             make(map[string]*orderRow),
+		traderOrders:       make(map[string][]*orderRow),
+		stockTransactions:  make(map[string][]*transactionRow),
+		traderTransactions: make(map[string][]*transactionRow),
+	}
+}
+
+// Save stores a batch's orders and transactions
+func (s *MemoryStore) Save(batch Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, order := range batch.Orders {
+		s.saveOrder(order, batch.Seq)
+	}
+	for _, transaction := range batch.Transactions {
+		s.addTransaction(transaction, batch.Seq)
+	}
+	return nil
+}
+
+func (s *MemoryStore) saveOrder(order models.Order, seq uint64) {
+	if row, exists := s.orders[order.ID]; exists {
+		row.order, row.seq = order, seq
+		return
+	}
+	row := &orderRow{order: order, seq: seq}
+	s.orders[order.ID] = row
+	s.traderOrders[order.TraderID] = insertByArrival(s.traderOrders[order.TraderID], row)
+}
+
+// insertByArrival adds an order to a list in arrival order. Orders nearly
+// always arrive last, so the search starts from the end.
+func insertByArrival(rows []*orderRow, row *orderRow) []*orderRow {
+	i := len(rows)
+	for i > 0 && rows[i-1].order.Arrival() > row.order.Arrival() {
+		i--
+	}
+	rows = append(rows, nil)
+	copy(rows[i+1:], rows[i:])
+	rows[i] = row
+	return rows
+}
+
+func (s *MemoryStore) addTransaction(transaction models.Transaction, seq uint64) {
+	row := &transactionRow{transaction: transaction, number: transaction.Number(), seq: seq}
+	s.transactions = insertByNumber(s.transactions, row)
+	s.stockTransactions[transaction.StockID] = insertByNumber(s.stockTransactions[transaction.StockID], row)
+	s.traderTransactions[transaction.BuyerID] = insertByNumber(s.traderTransactions[transaction.BuyerID], row)
+	if transaction.SellerID != transaction.BuyerID {
+		s.traderTransactions[transaction.SellerID] = insertByNumber(s.traderTransactions[transaction.SellerID], row)
+	}
+}
+
+// insertByNumber adds a transaction to a list in execution order.
+// Operations on different stocks can finish in a different order from the
+// one their trades were executed in, so a transaction may go a little
+// before the end.
+func insertByNumber(rows []*transactionRow, row *transactionRow) []*transactionRow {
+	i := len(rows)
+	for i > 0 && rows[i-1].number > row.number {
+		i--
+	}
+	rows = append(rows, nil)
+	copy(rows[i+1:], rows[i:])
+	rows[i] = row
+	return rows
+}
+
+// Rewind drops orders and transactions saved after seq
+func (s *MemoryStore) Rewind(seq uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.transactions[:0]
+	for _, row := range s.transactions {
+		if row.seq <= seq {
+			kept = append(kept, row)
+		}
+	}
+	s.transactions = kept
+	clear(s.stockTransactions)
+	clear(s.traderTransactions)
+	for _, row := range kept {
+		transaction := row.transaction
+		s.stockTransactions[transaction.StockID] = append(s.stockTransactions[transaction.StockID], row)
+		s.traderTransactions[transaction.BuyerID] = append(s.traderTransactions[transaction.BuyerID], row)
+		if transaction.SellerID != transaction.BuyerID {
+			s.traderTransactions[transaction.SellerID] = append(s.traderTransactions[transaction.SellerID], row)
+		}
+	}
+
+	for id, row := range s.orders {
+		if row.seq > seq {
+			delete(s.orders, id)
+		}
+	}
+	for traderID, rows := range s.traderOrders {
+		kept := rows[:0]
+		for _, row := range rows {
+			if row.seq <= seq {
+				kept = append(kept, row)
+			}
+		}
+		s.traderOrders[traderID] = kept
+	}
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+func (s *MemoryStore) Order(orderID string) (models.Order, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	row, exists := s.orders[orderID]
+	if !exists {
+		return models.Order{}, false, nil
+	}
+	return row.order, true, nil
+}
+
+func (s *MemoryStore) OrderIDs() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	orderIDs := make([]string, 0, len(s.orders))
+	for orderID := range s.orders {
+		orderIDs = append(orderIDs, orderID)
+	}
+	return orderIDs, nil
+}
+
+// QueryOrders returns a page of a trader's order history, newest first. The
+// cursor is the arrival number of the order to continue from.
+func (s *MemoryStore) QueryOrders(query OrderQuery) (OrderPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	history := s.traderOrders[query.TraderID]
+	start := len(history) - 1
+	if query.Cursor != "" {
+		arrival, err := strconv.ParseUint(query.Cursor, 10, 64)
+		position := sort.Search(len(history), func(i int) bool { return history[i].order.Arrival() >= arrival })
+		if err != nil || position == len(history) || history[position].order.Arrival() != arrival {
+			return OrderPage{}, fmt.Errorf("invalid cursor")
+		}
+		start = position
+	}
+
+	// The next page starts at the first match after a full one, if any
+	page := OrderPage{Orders: make([]models.Order, 0)}
+	for i := start; i >= 0; i-- {
+		order := history[i].order
+		if !query.matches(&order) {
+			continue
+		}
+		if query.Limit > 0 && len(page.Orders) == query.Limit {
+			page.NextCursor = strconv.FormatUint(order.Arrival(), 10)
+			break
+		}
+		page.Orders = append(page.Orders, order)
+	}
+	return page, nil
+}
+
+func (s *MemoryStore) StockTransactions(stockID string, limit int) ([]models.Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return newestFirst(s.stockTransactions[stockID], limit), nil
+}
+
+func (s *MemoryStore) TraderTransactions(traderID string, limit int) ([]models.Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return newestFirst(s.traderTransactions[traderID], limit), nil
+}
+
+func newestFirst(rows []*transactionRow, limit int) []models.Transaction {
+	if limit <= 0 || limit > len(rows) {
+		limit = len(rows)
+	}
+	transactions := make([]models.Transaction, 0, limit)
+	for i := len(rows) - 1; i >= 0 && len(transactions) < limit; i-- {
+		transactions = append(transactions, rows[i].transaction)
+	}
+	return transactions
+}
+
+// Dump returns the records saved through journal entry seq: every open and
+// pending order, and the latest limit closed orders and transactions, or all
+// of them if limit is 0
+func (s *MemoryStore) Dump(seq uint64, limit int) Records {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records := Records{
+		Orders:       make([]OrderRecord, 0),
+		Transactions: make([]models.Transaction, 0),
+	}
+	var closed []*orderRow
+	for _, row := range s.orders {
+		switch {
+		case row.seq > seq:
+		case row.order.IsActive() || row.order.Status == models.Pending:
+			records.Orders = append(records.Orders, OrderRecord{Order: row.order, ArrivalSeq: row.order.Arrival()})
+		default:
+			closed = append(closed, row)
+		}
+	}
+	sort.Slice(closed, func(i, j int) bool { return closed[i].order.Arrival() > closed[j].order.Arrival() })
+	if limit > 0 && len(closed) > limit {
+		closed = closed[:limit]
+	}
+	for _, row := range closed {
+		records.Orders = append(records.Orders, OrderRecord{Order: row.order, ArrivalSeq: row.order.Arrival()})
+	}
+	sort.Slice(records.Orders, func(i, j int) bool { return records.Orders[i].ArrivalSeq < records.Orders[j].ArrivalSeq })
+
+	var kept []*transactionRow
+	for i := len(s.transactions) - 1; i >= 0 && (limit == 0 || len(kept) < limit); i-- {
+		if row := s.transactions[i]; row.seq <= seq {
+			kept = append(kept, row)
+		}
+	}
+	for i := len(kept) - 1; i >= 0; i-- {
+		records.Transactions = append(records.Transactions, kept[i].transaction)
+	}
+	return records
+}
+
+// Load adds dumped records to the store
+func (s *MemoryStore) Load(records Records) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range records.Orders {
+		order := record.Order
+		order.SetArrival(record.ArrivalSeq)
+		s.saveOrder(order, 0)
+	}
+	for _, transaction := range records.Transactions {
+		s.addTransaction(transaction, 0)
+	}
+}
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"math"
+	"stock-exchange/internal/models"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testOrder(id string, arrival uint64, side models.OrderType, status models.OrderStatus) models.Order {
+	order := models.Order{
+		ID:        id,
+		TraderID:  "trader1",
+		StockID:   "1",
+		Type:      side,
+		Status:    status,
+		CreatedAt: time.Date(2024, 1, 1, 10, 0, int(arrival), 0, time.UTC),
+	}
+	order.SetArrival(arrival)
+	return order
+}
+
+func testTransaction(number int, stockID, buyerID, sellerID string) models.Transaction {
+	return models.Transaction{
+		ID:       "tx-" + strconv.Itoa(number),
+		StockID:  stockID,
+		BuyerID:  buyerID,
+		SellerID: sellerID,
+		Quantity: 1,
+	}
+}
+
+func ids(orders []models.Order) []string {
+	list := make([]string, 0, len(orders))
+	for _, order := range orders {
+		list = append(list, order.ID)
+	}
+	return list
+}
+
+// Test order history - newest first, filtered and paged by cursor
+func TestMemoryStore_QueryOrders(t *testing.T) {
+	store := NewMemoryStore()
+	store.Save(Batch{Seq: 1, Orders: []models.Order{
+		testOrder("o1", 1, models.Buy, models.Filled),
+		testOrder("o2", 2, models.Sell, models.Open),
+		testOrder("o3", 3, models.Buy, models.Cancelled),
+		testOrder("o4", 4, models.Buy, models.Open),
+	}})
+
+	// Updating an order keeps its place in the history
+	updated := testOrder("o2", 2, models.Sell, models.Filled)
+	store.Save(Batch{Seq: 2, Orders: []models.Order{updated}})
+	if order, exists, _ := store.Order("o2"); !exists || order.Status != models.Filled || order.Arrival() != 2 {
+		t.Errorf("Expected o2 to be updated in place, got %v (%v)", order.Status, exists)
+	}
+
+	page, err := store.QueryOrders(OrderQuery{TraderID: "trader1", Limit: 2})
+	if err != nil || len(page.Orders) != 2 || page.Orders[0].ID != "o4" || page.Orders[1].ID != "o3" {
+		t.Fatalf("Expected o4 and o3 first, got %v (%v)", ids(page.Orders), err)
+	}
+	page, err = store.QueryOrders(OrderQuery{TraderID: "trader1", Limit: 2, Cursor: page.NextCursor})
+	if err != nil || len(page.Orders) != 2 || page.Orders[0].ID != "o2" || page.NextCursor != "" {
+		t.Errorf("Expected o2 and o1 on the last page, got %v (%v, cursor %q)", ids(page.Orders), err, page.NextCursor)
+	}
+
+	page, _ = store.QueryOrders(OrderQuery{TraderID: "trader1", Side: models.Buy, Statuses: []models.OrderStatus{models.Open, models.Filled}})
+	if len(page.Orders) != 2 || page.Orders[0].ID != "o4" || page.Orders[1].ID != "o1" {
+		t.Errorf("Expected the open and filled buys, got %v", ids(page.Orders))
+	}
+
+	// Pages skip orders the filter excludes, and end when none after them match
+	page, _ = store.QueryOrders(OrderQuery{TraderID: "trader1", Side: models.Buy, Limit: 2})
+	if len(page.Orders) != 2 || page.Orders[1].ID != "o3" || page.NextCursor == "" {
+		t.Fatalf("Expected o4 and o3 with more to come, got %v (cursor %q)", ids(page.Orders), page.NextCursor)
+	}
+	page, _ = store.QueryOrders(OrderQuery{TraderID: "trader1", Side: models.Buy, Limit: 2, Cursor: page.NextCursor})
+	if len(page.Orders) != 1 || page.Orders[0].ID != "o1" || page.NextCursor != "" {
+		t.Errorf("Expected only o1 on the last page, got %v (cursor %q)", ids(page.Orders), page.NextCursor)
+	}
+	page, _ = store.QueryOrders(OrderQuery{TraderID: "trader1", Side: models.Sell, Limit: 1})
+	if len(page.Orders) != 1 || page.Orders[0].ID != "o2" || page.NextCursor != "" {
+		t.Errorf("Expected o2 alone without a next page, got %v (cursor %q)", ids(page.Orders), page.NextCursor)
+	}
+
+	if _, err := store.QueryOrders(OrderQuery{TraderID: "trader1", Cursor: "9"}); err == nil {
+		t.Error("Expected a cursor for an unknown order to be rejected")
+	}
+}
+
+// Test transactions - newest first by number, even when saved out of order
+func TestMemoryStore_Transactions(t *testing.T) {
+	store := NewMemoryStore()
+	store.Save(Batch{Seq: 1, Transactions: []models.Transaction{testTransaction(1, "1", "trader1", "trader2")}})
+	store.Save(Batch{Seq: 2, Transactions: []models.Transaction{testTransaction(3, "1", "trader2", "trader1")}})
+	store.Save(Batch{Seq: 3, Transactions: []models.Transaction{testTransaction(2, "2", "trader1", "trader1")}})
+
+	transactions, _ := store.StockTransactions("1", 0)
+	if len(transactions) != 2 || transactions[0].ID != "tx-3" || transactions[1].ID != "tx-1" {
+		t.Errorf("Expected stock 1's transactions newest first, got %v", transactions)
+	}
+
+	transactions, _ = store.TraderTransactions("trader1", 2)
+	if len(transactions) != 2 || transactions[0].ID != "tx-3" || transactions[1].ID != "tx-2" {
+		t.Errorf("Expected trader1's latest two transactions, got %v", transactions)
+	}
+
+	// A self-trade is listed once
+	transactions, _ = store.TraderTransactions("trader1", 0)
+	if len(transactions) != 3 {
+		t.Errorf("Expected 3 transactions for trader1, got %d", len(transactions))
+	}
+}
+
+// Test rewind - records saved after the sequence number are dropped
+func TestMemoryStore_Rewind(t *testing.T) {
+	store := NewMemoryStore()
+	store.Save(Batch{Seq: 1, Orders: []models.Order{testOrder("o1", 1, models.Buy, models.Open)},
+		Transactions: []models.Transaction{testTransaction(1, "1", "trader1", "trader2")}})
+	store.Save(Batch{Seq: 2, Orders: []models.Order{testOrder("o2", 2, models.Sell, models.Open)},
+		Transactions: []models.Transaction{testTransaction(2, "1", "trader2", "trader1")}})
+
+	store.Rewind(1)
+
+	if _, exists, _ := store.Order("o2"); exists {
+		t.Error("Expected o2 to be dropped")
+	}
+	if orderIDs, _ := store.OrderIDs(); len(orderIDs) != 1 || orderIDs[0] != "o1" {
+		t.Errorf("Expected only o1's ID to be listed, got %v", orderIDs)
+	}
+	page, _ := store.QueryOrders(OrderQuery{TraderID: "trader1"})
+	if len(page.Orders) != 1 || page.Orders[0].ID != "o1" {
+		t.Errorf("Expected only o1 in the history, got %v", ids(page.Orders))
+	}
+	transactions, _ := store.TraderTransactions("trader2", 0)
+	if len(transactions) != 1 || transactions[0].ID != "tx-1" {
+		t.Errorf("Expected only tx-1 to be kept, got %v", transactions)
+	}
+
+	// Dumped records load into an empty store
+	restored := NewMemoryStore()
+	restored.Load(store.Dump(math.MaxUint64, 0))
+	if order, exists, _ := restored.Order("o1"); !exists || order.Arrival() != 1 {
+		t.Errorf("Expected o1 to be loaded with its arrival, got %v (%d)", exists, order.Arrival())
+	}
+}
+
+// Test dump - open and pending orders, and the latest closed orders and transactions
+func TestMemoryStore_Dump(t *testing.T) {
+	store := NewMemoryStore()
+	store.Save(Batch{Seq: 1, Orders: []models.Order{
+		testOrder("o1", 1, models.Buy, models.Open),
+		testOrder("o2", 2, models.Buy, models.Filled),
+		testOrder("o3", 3, models.Sell, models.Cancelled),
+		testOrder("o4", 4, models.Sell, models.Pending),
+		testOrder("o5", 5, models.Buy, models.Expired),
+	}, Transactions: []models.Transaction{
+		testTransaction(1, "1", "trader1", "trader2"),
+		testTransaction(2, "1", "trader1", "trader2"),
+		testTransaction(3, "1", "trader1", "trader2"),
+	}})
+	store.Save(Batch{Seq: 2, Orders: []models.Order{testOrder("o6", 6, models.Buy, models.Open)},
+		Transactions: []models.Transaction{testTransaction(4, "1", "trader1", "trader2")}})
+
+	records := store.Dump(1, 2)
+	orders := make([]string, 0)
+	for _, record := range records.Orders {
+		orders = append(orders, record.ID)
+	}
+	if strings.Join(orders, ",") != "o1,o3,o4,o5" {
+		t.Errorf("Expected the open, pending and latest two closed orders through entry 1, got %v", orders)
+	}
+	if len(records.Transactions) != 2 || records.Transactions[0].ID != "tx-2" || records.Transactions[1].ID != "tx-3" {
+		t.Errorf("Expected tx-2 and tx-3 in order, got %v", records.Transactions)
+	}
+}
@@ -0,0 +1,331 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"stock-exchange/internal/models"
+	"strconv"
+	"strings"
+)
+
+// PostgresDriver is the database/sql driver PostgresStore connects with. It
+// is registered by building the server with -tags postgres.
+const PostgresDriver = "pgx"
+
+// PostgresStore keeps records in PostgreSQL. Each table has the columns the
+// queries filter and sort on, and the record itself as JSON. The stocks and
+// traders tables are a write-behind mirror of the exchange for other readers
+// of the database. Every row is
+// tagged with the journal entry that saved it, so Rewind can drop what the
+// journal lost.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS stocks (
+	id   TEXT PRIMARY KEY,
+	data JSONB NOT NULL,
+	seq  BIGINT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS traders (
+	id   TEXT PRIMARY KEY,
+	data JSONB NOT NULL,
+	seq  BIGINT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS orders (
+	id         TEXT PRIMARY KEY,
+	trader_id  TEXT NOT NULL,
+	stock_id   TEXT NOT NULL,
+	side       TEXT NOT NULL,
+	status     TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	arrival    BIGINT NOT NULL,
+	data       JSONB NOT NULL,
+	seq        BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS orders_trader_arrival ON orders (trader_id, arrival);
+CREATE TABLE IF NOT EXISTS transactions (
+	id        TEXT PRIMARY KEY,
+	number    BIGINT NOT NULL,
+	stock_id  TEXT NOT NULL,
+	buyer_id  TEXT NOT NULL,
+	seller_id TEXT NOT NULL,
+	data      JSONB NOT NULL,
+	seq       BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS transactions_stock ON transactions (stock_id, number);
+CREATE INDEX IF NOT EXISTS transactions_buyer ON transactions (buyer_id, number);
+CREATE INDEX IF NOT EXISTS transactions_seller ON transactions (seller_id, number);
+`
+
+// OpenPostgres connects to the database at dsn and creates the tables the
+// store needs
+func OpenPostgres(dsn string) (*PostgresStore, error) {
+	registered := false
+	for _, driver := range sql.Drivers() {
+		registered = registered || driver == PostgresDriver
+	}
+	if !registered {
+		return nil, fmt.Errorf("postgres storage needs the server built with -tags postgres")
+	}
+
+	db, err := sql.Open(PostgresDriver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	store, err := NewPostgresStore(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// NewPostgresStore uses an open database, creating the tables the store needs
+func NewPostgresStore(db *sql.DB) (*PostgresStore, error) {
+	if _, err := db.Exec(postgresSchema); err != nil {
+		return nil, fmt.Errorf("failed to create tables: %v", err)
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+func (s *PostgresStore) Save(batch Batch) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stock := range batch.Stocks {
+		data, err := json.Marshal(stock)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO stocks (id, data, seq) VALUES ($1, $2, $3)
+			ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data, seq = EXCLUDED.seq`,
+			stock.ID, string(data), int64(batch.Seq)); err != nil {
+			return err
+		}
+	}
+
+	for _, trader := range batch.Traders {
+		data, err := json.Marshal(trader)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO traders (id, data, seq) VALUES ($1, $2, $3)
+			ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data, seq = EXCLUDED.seq`,
+			trader.ID, string(data), int64(batch.Seq)); err != nil {
+			return err
+		}
+	}
+
+	for i := range batch.Orders {
+		order := &batch.Orders[i]
+		data, err := json.Marshal(order)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO orders (id, trader_id, stock_id, side, status, created_at, arrival, data, seq)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (id) DO UPDATE SET status = EXCLUDED.status, data = EXCLUDED.data, seq = EXCLUDED.seq`,
+			order.ID, order.TraderID, order.StockID, string(order.Type), string(order.Status), order.CreatedAt,
+			int64(order.Arrival()), string(data), int64(batch.Seq)); err != nil {
+			return err
+		}
+	}
+
+	// A replayed journal entry can save a transaction again
+	for _, transaction := range batch.Transactions {
+		data, err := json.Marshal(transaction)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO transactions (id, number, stock_id, buyer_id, seller_id, data, seq)
+			VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (id) DO NOTHING`,
+			transaction.ID, transaction.Number(), transaction.StockID, transaction.BuyerID, transaction.SellerID,
+			string(data), int64(batch.Seq)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresStore) Rewind(seq uint64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"stocks", "traders", "orders", "transactions"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE seq > $1", int64(seq)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *PostgresStore) Order(orderID string) (models.Order, bool, error) {
+	var data string
+	var arrival int64
+	err := s.db.QueryRow(`SELECT data, arrival FROM orders WHERE id = $1`, orderID).Scan(&data, &arrival)
+	if err == sql.ErrNoRows {
+		return models.Order{}, false, nil
+	}
+	if err != nil {
+		return models.Order{}, false, err
+	}
+
+	order, err := decodeOrder(data, arrival)
+	if err != nil {
+		return models.Order{}, false, err
+	}
+	return order, true, nil
+}
+
+func (s *PostgresStore) OrderIDs() ([]string, error) {
+	rows, err := s.db.Query(`SELECT id FROM orders`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orderIDs := make([]string, 0)
+	for rows.Next() {
+		var orderID string
+		if err := rows.Scan(&orderID); err != nil {
+			return nil, err
+		}
+		orderIDs = append(orderIDs, orderID)
+	}
+	return orderIDs, rows.Err()
+}
+
+func decodeOrder(data string, arrival int64) (models.Order, error) {
+	var order models.Order
+	if err := json.Unmarshal([]byte(data), &order); err != nil {
+		return models.Order{}, err
+	}
+	order.SetArrival(uint64(arrival))
+	return order, nil
+}
+
+// QueryOrders returns a page of a trader's order history, newest first. The
+// cursor is the arrival number of the order to continue from; one extra
+// order is read to find it.
+func (s *PostgresStore) QueryOrders(query OrderQuery) (OrderPage, error) {
+	where := []string{"trader_id = $1"}
+	args := []any{query.TraderID}
+	arg := func(value any) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if query.Cursor != "" {
+		arrival, err := strconv.ParseInt(query.Cursor, 10, 64)
+		if err != nil {
+			return OrderPage{}, fmt.Errorf("invalid cursor")
+		}
+		var exists bool
+		if err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM orders WHERE trader_id = $1 AND arrival = $2)`,
+			query.TraderID, arrival).Scan(&exists); err != nil {
+			return OrderPage{}, err
+		}
+		if !exists {
+			return OrderPage{}, fmt.Errorf("invalid cursor")
+		}
+		where = append(where, "arrival <= "+arg(arrival))
+	}
+	if query.StockID != "" {
+		where = append(where, "stock_id = "+arg(query.StockID))
+	}
+	if query.Side != "" {
+		where = append(where, "side = "+arg(string(query.Side)))
+	}
+	if !query.From.IsZero() {
+		where = append(where, "created_at >= "+arg(query.From))
+	}
+	if !query.To.IsZero() {
+		where = append(where, "created_at < "+arg(query.To))
+	}
+	if len(query.Statuses) > 0 {
+		statuses := make([]string, len(query.Statuses))
+		for i, status := range query.Statuses {
+			statuses[i] = arg(string(status))
+		}
+		where = append(where, "status IN ("+strings.Join(statuses, ", ")+")")
+	}
+
+	statement := "SELECT data, arrival FROM orders WHERE " + strings.Join(where, " AND ") + " ORDER BY arrival DESC"
+	if query.Limit > 0 {
+		statement += " LIMIT " + arg(query.Limit+1)
+	}
+	rows, err := s.db.Query(statement, args...)
+	if err != nil {
+		return OrderPage{}, err
+	}
+	defer rows.Close()
+
+	page := OrderPage{Orders: make([]models.Order, 0)}
+	for rows.Next() {
+		var data string
+		var arrival int64
+		if err := rows.Scan(&data, &arrival); err != nil {
+			return OrderPage{}, err
+		}
+		if query.Limit > 0 && len(page.Orders) == query.Limit {
+			page.NextCursor = strconv.FormatInt(arrival, 10)
+			break
+		}
+		order, err := decodeOrder(data, arrival)
+		if err != nil {
+			return OrderPage{}, err
+		}
+		page.Orders = append(page.Orders, order)
+	}
+	return page, rows.Err()
+}
+
+func (s *PostgresStore) StockTransactions(stockID string, limit int) ([]models.Transaction, error) {
+	return s.transactions(`stock_id = $1`, stockID, limit)
+}
+
+func (s *PostgresStore) TraderTransactions(traderID string, limit int) ([]models.Transaction, error) {
+	return s.transactions(`buyer_id = $1 OR seller_id = $1`, traderID, limit)
+}
+
+func (s *PostgresStore) transactions(where string, id string, limit int) ([]models.Transaction, error) {
+	statement := "SELECT data FROM transactions WHERE " + where + " ORDER BY number DESC"
+	args := []any{id}
+	if limit > 0 {
+		statement += " LIMIT $2"
+		args = append(args, limit)
+	}
+	rows, err := s.db.Query(statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := make([]models.Transaction, 0)
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var transaction models.Transaction
+		if err := json.Unmarshal([]byte(data), &transaction); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, rows.Err()
+}
@@ -0,0 +1,147 @@
+//go:build postgres
+
+package storage
+
+import (
+	"database/sql"
+	"os"
+	"stock-exchange/internal/models"
+	"testing"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// testPostgresDSN names the environment variable with the connection string
+// of a database the PostgreSQL tests may empty. They are skipped without it.
+const testPostgresDSN = "STOCK_EXCHANGE_TEST_DSN"
+
+// openTestPostgres opens a PostgresStore on empty tables
+func openTestPostgres(t *testing.T) *PostgresStore {
+	dsn := os.Getenv(testPostgresDSN)
+	if dsn == "" {
+		t.Skipf("Set %s to run the PostgreSQL tests", testPostgresDSN)
+	}
+
+	db, err := sql.Open(PostgresDriver, dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if _, err := db.Exec(`DROP TABLE IF EXISTS stocks, traders, orders, transactions`); err != nil {
+		db.Close()
+		t.Fatalf("Failed to drop tables: %v", err)
+	}
+	store, err := NewPostgresStore(db)
+	if err != nil {
+		db.Close()
+		t.Fatalf("Failed to create tables: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// Test order history - newest first, filtered and paged by cursor
+func TestPostgresStore_QueryOrders(t *testing.T) {
+	store := openTestPostgres(t)
+	if err := store.Save(Batch{Seq: 1, Orders: []models.Order{
+		testOrder("o1", 1, models.Buy, models.Filled),
+		testOrder("o2", 2, models.Sell, models.Open),
+		testOrder("o3", 3, models.Buy, models.Cancelled),
+		testOrder("o4", 4, models.Buy, models.Open),
+	}}); err != nil {
+		t.Fatalf("Failed to save orders: %v", err)
+	}
+
+	// Updating an order keeps its place in the history
+	updated := testOrder("o2", 2, models.Sell, models.Filled)
+	store.Save(Batch{Seq: 2, Orders: []models.Order{updated}})
+	if order, exists, _ := store.Order("o2"); !exists || order.Status != models.Filled || order.Arrival() != 2 {
+		t.Errorf("Expected o2 to be updated in place, got %v (%v)", order.Status, exists)
+	}
+
+	page, err := store.QueryOrders(OrderQuery{TraderID: "trader1", Limit: 2})
+	if err != nil || len(page.Orders) != 2 || page.Orders[0].ID != "o4" || page.Orders[1].ID != "o3" {
+		t.Fatalf("Expected o4 and o3 first, got %v (%v)", ids(page.Orders), err)
+	}
+	page, err = store.QueryOrders(OrderQuery{TraderID: "trader1", Limit: 2, Cursor: page.NextCursor})
+	if err != nil || len(page.Orders) != 2 || page.Orders[0].ID != "o2" || page.NextCursor != "" {
+		t.Errorf("Expected o2 and o1 on the last page, got %v (%v, cursor %q)", ids(page.Orders), err, page.NextCursor)
+	}
+
+	page, _ = store.QueryOrders(OrderQuery{TraderID: "trader1", Side: models.Buy, Statuses: []models.OrderStatus{models.Open, models.Filled}})
+	if len(page.Orders) != 2 || page.Orders[0].ID != "o4" || page.Orders[1].ID != "o1" {
+		t.Errorf("Expected the open and filled buys, got %v", ids(page.Orders))
+	}
+
+	// Pages skip orders the filter excludes, and end when none after them match
+	page, _ = store.QueryOrders(OrderQuery{TraderID: "trader1", Side: models.Buy, Limit: 2})
+	if len(page.Orders) != 2 || page.Orders[1].ID != "o3" || page.NextCursor == "" {
+		t.Fatalf("Expected o4 and o3 with more to come, got %v (cursor %q)", ids(page.Orders), page.NextCursor)
+	}
+	page, _ = store.QueryOrders(OrderQuery{TraderID: "trader1", Side: models.Buy, Limit: 2, Cursor: page.NextCursor})
+	if len(page.Orders) != 1 || page.Orders[0].ID != "o1" || page.NextCursor != "" {
+		t.Errorf("Expected only o1 on the last page, got %v (cursor %q)", ids(page.Orders), page.NextCursor)
+	}
+	page, _ = store.QueryOrders(OrderQuery{TraderID: "trader1", Side: models.Sell, Limit: 1})
+	if len(page.Orders) != 1 || page.Orders[0].ID != "o2" || page.NextCursor != "" {
+		t.Errorf("Expected o2 alone without a next page, got %v (cursor %q)", ids(page.Orders), page.NextCursor)
+	}
+
+	if _, err := store.QueryOrders(OrderQuery{TraderID: "trader1", Cursor: "9"}); err == nil {
+		t.Error("Expected a cursor for an unknown order to be rejected")
+	}
+}
+
+// Test transactions - newest first by number, saved once even when replayed
+func TestPostgresStore_Transactions(t *testing.T) {
+	store := openTestPostgres(t)
+	store.Save(Batch{Seq: 1, Transactions: []models.Transaction{testTransaction(1, "1", "trader1", "trader2")}})
+	store.Save(Batch{Seq: 2, Transactions: []models.Transaction{testTransaction(3, "1", "trader2", "trader1")}})
+	store.Save(Batch{Seq: 3, Transactions: []models.Transaction{testTransaction(2, "2", "trader1", "trader1")}})
+	if err := store.Save(Batch{Seq: 1, Transactions: []models.Transaction{testTransaction(1, "1", "trader1", "trader2")}}); err != nil {
+		t.Errorf("Expected a replayed transaction to be ignored, got %v", err)
+	}
+
+	transactions, _ := store.StockTransactions("1", 0)
+	if len(transactions) != 2 || transactions[0].ID != "tx-3" || transactions[1].ID != "tx-1" {
+		t.Errorf("Expected stock 1's transactions newest first, got %v", transactions)
+	}
+
+	transactions, _ = store.TraderTransactions("trader1", 2)
+	if len(transactions) != 2 || transactions[0].ID != "tx-3" || transactions[1].ID != "tx-2" {
+		t.Errorf("Expected trader1's latest two transactions, got %v", transactions)
+	}
+
+	// A self-trade is listed once
+	transactions, _ = store.TraderTransactions("trader1", 0)
+	if len(transactions) != 3 {
+		t.Errorf("Expected 3 transactions for trader1, got %d", len(transactions))
+	}
+}
+
+// Test rewind - records saved after the sequence number are dropped
+func TestPostgresStore_Rewind(t *testing.T) {
+	store := openTestPostgres(t)
+	store.Save(Batch{Seq: 1, Orders: []models.Order{testOrder("o1", 1, models.Buy, models.Open)},
+		Transactions: []models.Transaction{testTransaction(1, "1", "trader1", "trader2")}})
+	store.Save(Batch{Seq: 2, Orders: []models.Order{testOrder("o2", 2, models.Sell, models.Open)},
+		Transactions: []models.Transaction{testTransaction(2, "1", "trader2", "trader1")}})
+
+	if err := store.Rewind(1); err != nil {
+		t.Fatalf("Failed to rewind: %v", err)
+	}
+
+	if _, exists, _ := store.Order("o2"); exists {
+		t.Error("Expected o2 to be dropped")
+	}
+	if orderIDs, _ := store.OrderIDs(); len(orderIDs) != 1 || orderIDs[0] != "o1" {
+		t.Errorf("Expected only o1's ID to be listed, got %v", orderIDs)
+	}
+	page, _ := store.QueryOrders(OrderQuery{TraderID: "trader1"})
+	if len(page.Orders) != 1 || page.Orders[0].ID != "o1" {
+		t.Errorf("Expected only o1 in the history, got %v", ids(page.Orders))
+	}
+	transactions, _ := store.TraderTransactions("trader2", 0)
+	if len(transactions) != 1 || transactions[0].ID != "tx-1" {
+		t.Errorf("Expected only tx-1 to be kept, got %v", transactions)
+	}
+}
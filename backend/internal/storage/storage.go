@@ -0,0 +1,139 @@
+// Package storage keeps the exchange's records of stocks, traders, orders
+// and transactions, and answers queries over them.
+//
+// The exchange matches from its own in-memory state: the books, the open
+// orders on them and traders' accounts with their reservations. Each
+// operation's changes are saved to the store as one Batch when it finishes,
+// so a store never holds half an operation. Order history and transactions
+// are read back from the store. Stocks and traders are only written: the
+// store mirrors them behind the exchange for whoever reads the database,
+// while the exchange restores its own from the journal and snapshots.
+package storage
+
+import (
+	"fmt"
+	"stock-exchange/internal/models"
+	"time"
+)
+
+// Store is where the exchange's records live
+type Store interface {
+	OrderRepository
+	TransactionRepository
+
+	// Save writes everything an operation changed, all or nothing
+	Save(batch Batch) error
+	// Rewind drops what was saved with a sequence number after seq, for
+	// changes the journal lost in a crash
+	Rewind(seq uint64) error
+	Close() error
+}
+
+// OrderRepository reads orders, open or closed
+type OrderRepository interface {
+	Order(orderID string) (models.Order, bool, error)
+	// OrderIDs lists every saved order's ID, for the exchange to keep
+	// rejecting taken IDs after a restart
+	OrderIDs() ([]string, error)
+	QueryOrders(query OrderQuery) (OrderPage, error)
+}
+
+// TransactionRepository reads transactions, newest first. A limit of 0 reads
+// them all.
+type TransactionRepository interface {
+	StockTransactions(stockID string, limit int) ([]models.Transaction, error)
+	TraderTransactions(traderID string, limit int) ([]models.Transaction, error)
+}
+
+// Batch is what one operation changed. Orders are saved as they are after
+// it, new or updated; transactions are only ever added. Traders are saved
+// without their reservations, which follow from the open orders.
+type Batch struct {
+	Seq          uint64 // Journal entry of the operation, 0 without a journal
+	Stocks       []*models.Stock
+	Traders      []*models.Trader
+	Orders       []models.Order
+	Transactions []models.Transaction
+}
+
+// Volatile is implemented by stores that lose their records on restart.
+// Snapshots carry the latest records of a volatile store so they survive.
+type Volatile interface {
+	// Dump returns the records saved through journal entry seq: every open
+	// and pending order, and the latest limit closed orders and
+	// transactions, or all of them if limit is 0
+	Dump(seq uint64, limit int) Records
+	Load(records Records)
+}
+
+// Records are the orders and transactions a volatile store holds
+type Records struct {
+	Orders       []OrderRecord        `json:"orders"`
+	Transactions []models.Transaction `json:"transactions"`
+}
+
+// OrderRecord is an order with its place in the order history
+type OrderRecord struct {
+	models.Order
+	ArrivalSeq uint64 `json:"arrival"`
+}
+
+// OrderQuery filters a trader's order history. Zero values match everything.
+type OrderQuery struct {
+	TraderID string
+	Statuses []models.OrderStatus
+	StockID  string
+	Side     models.OrderType
+	From     time.Time // Inclusive lower bound on CreatedAt
+	To       time.Time // Exclusive upper bound on CreatedAt
+	Cursor   string    // NextCursor of the previous page
+	Limit    int
+}
+
+// OrderPage is one page of order history, newest first
+type OrderPage struct {
+	Orders     []models.Order `json:"orders"`
+	NextCursor string         `json:"nextCursor,omitempty"` // Empty on the last page
+}
+
+func (q OrderQuery) matches(order *models.Order) bool {
+	if q.StockID != "" && order.StockID != q.StockID {
+		return false
+	}
+	if q.Side != "" && order.Type != q.Side {
+		return false
+	}
+	if !q.From.IsZero() && order.CreatedAt.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !order.CreatedAt.Before(q.To) {
+		return false
+	}
+	if len(q.Statuses) == 0 {
+		return true
+	}
+	for _, status := range q.Statuses {
+		if order.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Config selects the store in config.json
+type Config struct {
+	Type string `json:"type"` // "memory" (the default) or "postgres"
+	DSN  string `json:"dsn"`  // Connection string for postgres
+}
+
+// Open opens the store a config selects
+func Open(config Config) (Store, error) {
+	switch config.Type {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "postgres":
+		return OpenPostgres(config.DSN)
+	default:
+		return nil, fmt.Errorf("unknown storage type: %s", config.Type)
+	}
+}
@@ -1,5 +1,6 @@
 export type OrderType = 'buy' | 'sell'
-export type OrderStatus = 'open' | 'filled' | 'cancelled'
+export type OrderStatus = 'open' | 'partially_filled' | 'filled' | 'cancelled' | 'expired' | 'pending'
+export type PostOnly = 'reject' | 'reprice'
 
 export interface Order {
   id: string
@@ -10,6 +11,21 @@ export interface Order {
   quantity: number
   status: OrderStatus
   createdAt: Date
+  updatedAt: Date
+  originalQuantity: number
+  filledQuantity: number
+  avgFillPrice: number
+  displayQuantity?: number
+  displayed?: number
+  postOnly?: PostOnly
+  hidden?: boolean
+  stopPrice?: number
+  trailAmount?: number
+  trailPercent?: number
+  trailPeak?: number
+  ocoLegId?: string
+  parentId?: string
+  exitIds?: string[]
 }
 
 export interface CreateOrderRequest {
@@ -18,4 +34,7 @@ export interface CreateOrderRequest {
   type: OrderType
   price: number
   quantity: number
+  displayQuantity?: number
+  postOnly?: PostOnly
+  hidden?: boolean
 }
@@ -6,6 +6,37 @@ export interface Stock {
   name: string
   currentPrice: number
   amount: number
+  supplyPeak?: number
+  tickSize?: number
+  tickTable?: TickBand[]
+  lotSize?: number
+  minQuantity?: number
+  maxQuantity?: number
+  priceBand?: number
+  haltSeconds?: number
+  auctions?: AuctionSchedule
+  referencePrice?: number
+  closingPrice?: number
+  status?: 'trading' | 'halted' | 'opening-auction' | 'closing-auction' | 'cancel-only' | 'closed'
+  haltedUntil?: string
+}
+
+export interface AuctionSchedule {
+  opening: boolean
+  closing: boolean
+}
+
+export interface AuctionResult {
+  stockId: string
+  kind: 'opening' | 'closing' | 'reopening'
+  clearingPrice: number
+  volume: number
+  imbalance: number
+}
+
+export interface TickBand {
+  minPrice: number
+  tickSize: number
 }
 
 export interface StockDetails {
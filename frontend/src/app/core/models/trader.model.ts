@@ -14,6 +14,10 @@ export interface TraderInfo {
 }
 
 export interface TraderDetails extends Trader {
+  availableCash?: number
+  reservedCash?: number
+  availableShares?: { [stockId: string]: number }
+  reservedShares?: { [stockId: string]: number }
   openOrders: Order[] | null
 }
 
@@ -1,5 +1,5 @@
 import { Injectable } from '@angular/core'
-import { HttpClient } from '@angular/common/http'
+import { HttpClient, HttpParams } from '@angular/common/http'
 import { Observable } from 'rxjs'
 import { Stock, StockDetails } from '../../models/stock.model'
 import { Order } from '../../models/order.model'
@@ -24,22 +24,33 @@ export class StockService {
 
   getStockHistory (
     id: string,
-    days: number = 30
+    interval: CandleInterval = '1d',
+    from?: string,
+    to?: string
   ): Observable<StockHistoryResponse> {
+    let params = new HttpParams().set('interval', interval)
+    if (from) params = params.set('from', from)
+    if (to) params = params.set('to', to)
     return this.http.get<StockHistoryResponse>(
-      `${this.apiUrl}/${id}/history?days=${days}`
+      `${this.apiUrl}/${id}/history`,
+      { params }
     )
   }
 }
 
-export interface PriceQuote {
-  timestamp: string
-  price: number
+export type CandleInterval = '1m' | '5m' | '1h' | '1d'
+
+export interface Candle {
+  time: string
+  open: number
+  high: number
+  low: number
+  close: number
   volume: number
 }
 
 export interface StockHistoryResponse {
   stockId: string
-  days: number
-  history: PriceQuote[]
+  interval: CandleInterval
+  candles: Candle[]
 }
@@ -42,6 +42,7 @@ export interface PerformanceData {
   portfolioValue: number;
   profitLoss: number;
   cashBalance: number;
+  holdingsValue: number;
 }
 
 export interface PortfolioHolding {
@@ -56,6 +57,7 @@ export interface PortfolioData {
   holdings: PortfolioHolding[];
   totalValue: number;
   cashBalance: number;
+  holdingsValue: number;
 }
 
 export interface ActivityLog {
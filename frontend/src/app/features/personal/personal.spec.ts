@@ -94,7 +94,7 @@ describe('PersonalComponent', () => {
         traderId: 'trader123',
         days: 30,
         performance: [
-          { date: '2024-01-01', portfolioValue: 10000, profitLoss: 0, cashBalance: 5000 }
+          { date: '2024-01-01', portfolioValue: 10000, profitLoss: 0, cashBalance: 5000, holdingsValue: 5000 }
         ],
         portfolio: { totalValue: 10000, cashBalance: 5000, holdings: [] },
         activity: []
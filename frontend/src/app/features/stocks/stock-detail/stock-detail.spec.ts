@@ -92,10 +92,10 @@ describe('StockDetailComponent', () => {
     mockStockService.getStockHistory.and.returnValue(
       of({
         stockId: '1',
-        days: 30,
-        history: [
-          { timestamp: '2024-01-01T00:00:00Z', price: 100, volume: 1000 },
-          { timestamp: '2024-01-02T00:00:00Z', price: 105, volume: 1200 }
+        interval: '1d',
+        candles: [
+          { time: '2024-01-01T00:00:00Z', open: 98, high: 101, low: 97, close: 100, volume: 1000 },
+          { time: '2024-01-02T00:00:00Z', open: 100, high: 106, low: 99, close: 105, volume: 1200 }
         ]
       })
     )
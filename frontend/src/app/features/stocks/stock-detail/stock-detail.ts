@@ -134,6 +134,7 @@ export class StockDetailComponent implements OnInit {
   }
 
   canCancelOrder (order: any): boolean {
-    return order.traderId === this.trader?.id && order.status === 'open'
+    return order.traderId === this.trader?.id &&
+      (order.status === 'open' || order.status === 'partially_filled')
   }
 }
@@ -3,7 +3,7 @@ import { ElementRef } from '@angular/core'
 import { of } from 'rxjs'
 
 import { StockChartComponent } from './stock-chart.component'
-import { StockService, StockHistoryResponse, PriceQuote } from '../../../core/services/stock/stock.service'
+import { StockService, StockHistoryResponse, Candle } from '../../../core/services/stock/stock.service'
 
 // Mock Chart.js to avoid importing the full library in tests
 const mockChart = {
@@ -24,16 +24,16 @@ describe('StockChartComponent', () => {
   let fixture: ComponentFixture<StockChartComponent>
   let mockStockService: jasmine.SpyObj<StockService>
 
-  const mockPriceQuotes: PriceQuote[] = [
-    { timestamp: '2024-01-01T00:00:00Z', price: 140, volume: 1000 },
-    { timestamp: '2024-01-02T00:00:00Z', price: 145, volume: 1200 },
-    { timestamp: '2024-01-03T00:00:00Z', price: 150, volume: 900 }
+  const mockCandles: Candle[] = [
+    { time: '2024-01-01T00:00:00Z', open: 138, high: 142, low: 137, close: 140, volume: 1000 },
+    { time: '2024-01-02T00:00:00Z', open: 140, high: 146, low: 139, close: 145, volume: 1200 },
+    { time: '2024-01-03T00:00:00Z', open: 145, high: 151, low: 144, close: 150, volume: 900 }
   ]
 
   const mockStockHistoryResponse: StockHistoryResponse = {
     stockId: '1',
-    days: 30,
-    history: mockPriceQuotes
+    interval: '1d',
+    candles: mockCandles
   }
 
   beforeEach(async () => {
@@ -98,7 +98,7 @@ describe('StockChartComponent', () => {
     component.stockId = '1'
     component.ngOnInit()
 
-    expect(mockStockService.getStockHistory).toHaveBeenCalledWith('1', 30)
+    expect(mockStockService.getStockHistory).toHaveBeenCalledWith('1', '1d')
   })
 
   it('should load chart data with custom data when stockData is provided', () => {
@@ -135,8 +135,8 @@ describe('StockChartComponent', () => {
   it('should handle empty stock history data', () => {
     const emptyResponse: StockHistoryResponse = {
       stockId: '1',
-      days: 30,
-      history: []
+      interval: '1d',
+      candles: []
     }
     
     mockStockService.getStockHistory.and.returnValue(of(emptyResponse))
@@ -144,7 +144,7 @@ describe('StockChartComponent', () => {
     component.stockId = '1'
     component.ngOnInit()
 
-    expect(mockStockService.getStockHistory).toHaveBeenCalledWith('1', 30)
+    expect(mockStockService.getStockHistory).toHaveBeenCalledWith('1', '1d')
     // Component should not crash with empty data
     expect(component).toBeTruthy()
   })
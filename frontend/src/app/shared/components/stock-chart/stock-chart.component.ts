@@ -47,9 +47,15 @@ export class StockChartComponent implements OnInit, OnDestroy {
 
   private loadChartData () {
     if (this.stockId) {
-      this.stockService.getStockHistory(this.stockId, 30).subscribe({
+      this.stockService.getStockHistory(this.stockId, '1d').subscribe({
         next: response => {
-          this.stockData = response.history
+          this.stockData = response.candles.map(candle => ({
+            timestamp: candle.time,
+            price: candle.close,
+            high: candle.high,
+            low: candle.low,
+            volume: candle.volume
+          }))
           this.createChart()
         },
         error: error => {
@@ -287,14 +293,9 @@ export class StockChartComponent implements OnInit, OnDestroy {
     // Create a simplified candlestick-style chart using overlapping area charts
     // to show high/low price ranges
     const priceData = data.map((item: any) => item.price)
-    const highData = data.map((item: any) => {
-      const price = item.price
-      return price + (price * 0.02) // 2% higher
-    })
-    const lowData = data.map((item: any) => {
-      const price = item.price
-      return price - (price * 0.02) // 2% lower
-    })
+    // Sample data has no range, so it gets one 2% either side of the price
+    const highData = data.map((item: any) => item.high ?? item.price * 1.02)
+    const lowData = data.map((item: any) => item.low ?? item.price * 0.98)
 
     return {
       type: 'line' as ChartType,
@@ -25,9 +25,9 @@ describe('TraderChartComponent', () => {
   let mockTraderService: jasmine.SpyObj<TraderService>
 
   const mockPerformanceData: PerformanceData[] = [
-    { date: '2024-01-01', portfolioValue: 10000, profitLoss: 0, cashBalance: 5000 },
-    { date: '2024-01-02', portfolioValue: 10500, profitLoss: 500, cashBalance: 4500 },
-    { date: '2024-01-03', portfolioValue: 11000, profitLoss: 1000, cashBalance: 4000 }
+    { date: '2024-01-01', portfolioValue: 10000, profitLoss: 0, cashBalance: 5000, holdingsValue: 5000 },
+    { date: '2024-01-02', portfolioValue: 10500, profitLoss: 500, cashBalance: 4500, holdingsValue: 6000 },
+    { date: '2024-01-03', portfolioValue: 11000, profitLoss: 1000, cashBalance: 4000, holdingsValue: 7000 }
   ]
 
   const mockPortfolioData: PortfolioData = {